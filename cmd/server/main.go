@@ -2,31 +2,43 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
+	"todo-api/internal/clock"
 	"todo-api/internal/config"
 	"todo-api/internal/db/drivers"
 	"todo-api/internal/db/repository"
 	"todo-api/internal/handlers"
 	"todo-api/internal/jobs"
+	"todo-api/internal/mail"
+	"todo-api/internal/notify"
+	"todo-api/internal/problems"
+	"todo-api/internal/ratelimit"
 	"todo-api/internal/requests"
 	"todo-api/internal/services"
+	"todo-api/internal/storage"
+	"todo-api/internal/telemetry"
+	"todo-api/internal/version"
 
-	"github.com/go-playground/validator/v10"
-	"github.com/jmoiron/sqlx"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/bytes"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 )
 
-var (
-	db  *sqlx.DB
-	cfg *config.Config
-)
+// uploadPath is the route of attachment uploads, which skip the server body
+// limit
+const uploadPath = "/api1/public/tasks/:id/attachments"
 
-func init() {
+func main() {
 	// Setup logger
 	log.Logger = zerolog.New(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}).
 		Level(zerolog.TraceLevel).
@@ -34,47 +46,174 @@ func init() {
 		Timestamp().
 		Caller().
 		Logger()
+	// Contexts without a request logger fall back to the global one
+	zerolog.DefaultContextLogger = &log.Logger
 	log.Info().Msg("Logger initialized")
-	cfgPath, err := config.ParseCLI()
+
+	// Graceful shutdown on SIGINT and SIGTERM (docker stop)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		log.Error().Err(err).Msg("Server failed")
+		os.Exit(1)
+	}
+}
+
+// run starts the server and blocks until ctx is cancelled or the server
+// fails to start. It then shuts everything down within the configured
+// shutdown timeout.
+func run(ctx context.Context, args []string, stdout io.Writer) error {
 	// Parse CLI arguments
+	opts, err := config.ParseCLI(args)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to parse CLI")
+		return fmt.Errorf("parse CLI: %w", err)
 	}
 	// Read config
-	cfg, err = config.NewConfig(cfgPath)
+	cfg, err := config.Load(opts)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to read config")
+		return fmt.Errorf("read config: %w", err)
+	}
+	if opts.PrintConfig {
+		out, err := cfg.Redacted()
+		if err != nil {
+			return fmt.Errorf("print config: %w", err)
+		}
+		_, err = stdout.Write(out)
+		return err
 	}
+	level, _ := zerolog.ParseLevel(cfg.Log.Level)
+	zerolog.SetGlobalLevel(level)
 	log.Info().Msg("Config loaded")
+	log.Info().Msgf("Version %s, commit %s", version.Version, version.Commit)
+
 	// Connect to database
-	db, err = drivers.Connect(cfg.Db.Name, "internal/db/migrations")
+	db, err := drivers.Connect(cfg.Db.Name, cfg.Db.Migrations)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to connect to database")
+		return fmt.Errorf("connect to database: %w", err)
 	}
 	log.Info().Msg("Database connected. Path: " + cfg.Db.Name)
-}
 
-func main() {
+	// Setup tracing
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.Tracing.Exporter, cfg.Tracing.Endpoint, cfg.Tracing.ServiceName)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("setup tracing: %w", err)
+	}
+	log.Info().Msg("Tracing exporter: " + cfg.Tracing.Exporter)
+
 	// Setup controllers
 	taskRepo := repository.NewTaskRepo(db)
-	taskService := services.NewTaskService(taskRepo)
-	taskController := handlers.NewTaskController(taskService, time.Duration(cfg.Server.Timeout)*time.Second)
+	systemClock := clock.New()
+	reminderService := services.NewReminderService(repository.NewReminderRepo(db), repository.NewNotificationRepo(db),
+		newNotifier(cfg), systemClock, cfg.Reminders.Default)
+	projectRepo := repository.NewProjectRepo(db)
+	blobs, err := storage.NewFileStore(cfg.Attachments.Dir)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("open attachment store: %w", err)
+	}
+	maxFileSize, err := bytes.Parse(cfg.Attachments.MaxFileSize)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("parse attachments.max_file_size: %w", err)
+	}
+	maxTaskSize, err := bytes.Parse(cfg.Attachments.MaxTaskSize)
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("parse attachments.max_task_size: %w", err)
+	}
+	attachmentService := services.NewAttachmentService(repository.NewAttachmentRepo(db), taskRepo, blobs, systemClock,
+		services.AttachmentLimits{MaxFileSize: maxFileSize, MaxTaskSize: maxTaskSize})
+	taskService := services.NewTaskService(taskRepo, projectRepo, reminderService, attachmentService, systemClock)
+	taskController := handlers.NewTaskController(taskService, cfg.Server.Timeout)
+	userRepo := repository.NewUserRepo(db)
+	projectController := handlers.NewProjectController(services.NewProjectService(projectRepo, userRepo),
+		cfg.Server.Timeout)
+	assigneeController := handlers.NewAssigneeController(services.NewAssigneeService(taskRepo,
+		repository.NewAssigneeRepo(db), userRepo, projectRepo, systemClock), cfg.Server.Timeout)
+	commentController := handlers.NewCommentController(services.NewCommentService(repository.NewCommentRepo(db),
+		taskRepo, userRepo, systemClock), cfg.Server.Timeout)
+	attachmentController := handlers.NewAttachmentController(attachmentService, maxFileSize, cfg.Server.Timeout)
+	timeController := handlers.NewTimeController(services.NewTimeService(repository.NewTimeEntryRepo(db), taskRepo,
+		userRepo, systemClock), cfg.Server.Timeout)
+	statsController := handlers.NewStatsController(services.NewStatsService(repository.NewStatsRepo(db), systemClock,
+		cfg.Stats.CacheTTL), cfg.Server.Timeout)
+	sprintController := handlers.NewSprintController(services.NewSprintService(repository.NewSprintRepo(db), taskRepo,
+		projectRepo, systemClock), cfg.Server.Timeout)
+	viewController := handlers.NewViewController(services.NewViewService(repository.NewViewRepo(db), taskRepo,
+		systemClock), cfg.Server.Timeout)
+	userController := handlers.NewUserController(services.NewUserService(userRepo), cfg.Server.Timeout)
+	digestService := services.NewDigestService(userRepo, taskRepo, newMailer(cfg), systemClock, cfg.Digest.From)
 	// Setup echo
 	e := echo.New()
+	e.HideBanner = true
+	// Client addresses only come from X-Forwarded-For of trusted proxies
+	e.IPExtractor, err = ratelimit.NewIPExtractor(cfg.RateLimit.TrustedProxies)
+	if err != nil {
+		db.Close()
+		return err
+	}
+	e.Use(middleware.RequestID())
+	e.Use(handlers.ContextLogger())
+	e.Use(otelecho.Middleware(cfg.Tracing.ServiceName))
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
-		LogURI:    true,
-		LogStatus: true,
+		LogURI:       true,
+		LogStatus:    true,
+		LogRequestID: true,
 		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
 			log.Logger.Info().
+				Str("request_id", v.RequestID).
 				Str("URI", v.URI).
 				Int("status", v.Status).
 				Msg("request")
 			return nil
 		},
 	}))
-	e.Validator = &requests.CustomValidator{Validator: validator.New()}
+	e.Use(middleware.BodyLimitWithConfig(middleware.BodyLimitConfig{
+		// Uploads are limited by the attachment size limits instead
+		Skipper: func(c echo.Context) bool {
+			return c.Request().Method == http.MethodPost && c.Path() == uploadPath
+		},
+		Limit: cfg.Server.BodyLimit,
+	}))
+	e.Use(handlers.TimeZone())
+	e.Use(handlers.Caller())
+	e.Validator = requests.NewValidator()
+	e.HTTPErrorHandler = problems.HTTPErrorHandler
+
+	// Start background jobs
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	scheduler := jobs.NewScheduler(systemClock)
+	for _, job := range []jobs.Job{
+		jobs.NewOverdueJob(taskService, cfg.Worker.Interval, cfg.Worker.Jitter,
+			time.Duration(cfg.Worker.EscalateAfterDays)*24*time.Hour),
+		jobs.NewReminderJob(reminderService, cfg.Reminders.Interval),
+		jobs.NewNotificationJob(reminderService, cfg.Reminders.Interval),
+		jobs.NewDigestJob(digestService, cfg.Digest.Interval),
+		jobs.NewRebalanceJob(taskService, cfg.Board.RebalanceInterval, cfg.Board.MaxKeyLength),
+	} {
+		if err := scheduler.Register(job); err != nil {
+			db.Close()
+			return fmt.Errorf("register %s job: %w", job.Name, err)
+		}
+	}
+	scheduler.Start(workerCtx)
+
+	// Probes
+	healthController := handlers.NewHealthController(db, cfg.Db.Migrations, scheduler, systemClock, cfg.Server.Timeout)
+	e.GET("/healthz", healthController.Healthz)
+	e.GET("/readyz", healthController.Readyz)
+	e.GET("/version", healthController.Version)
+
+	rateLimitStore := ratelimit.NewMemoryStore()
+	publicLimits := cfg.RateLimit.Groups["public"]
+	publicLimiter := ratelimit.NewLimiter("public", rateLimitStore, newLimit(publicLimits.Read), newLimit(publicLimits.Write))
+	publicLimiter.IPExtractor = e.IPExtractor
+	publicLimiter.SetAPIKeys(cfg.RateLimit.APIKeys)
 
-	pg := e.Group("/api1/public")
+	pg := e.Group("/api1/public", publicLimiter.Middleware())
 
 	// Endpoints
 	pg.POST("/tasks", taskController.CreateTask)
@@ -82,33 +221,175 @@ func main() {
 	pg.GET("/tasks", taskController.GetTasks)
 	pg.PATCH("/tasks/:id/completed", taskController.SetCompleted)
 	pg.PUT("/tasks/:id", taskController.UpdateTask)
+	pg.DELETE("/tasks/:id", taskController.DeleteTask)
+	pg.POST("/tasks/:id/transition", taskController.Transition)
+	pg.POST("/tasks/:id/move", taskController.Move)
+	pg.PUT("/tasks/:id/tags", taskController.SetTags)
+	pg.GET("/boards/:project", taskController.GetBoard)
+	pg.POST("/parse-date", taskController.ParseDate)
+	pg.POST("/tasks/:id/assignees", assigneeController.Assign)
+	pg.DELETE("/tasks/:id/assignees/:user_id", assigneeController.Unassign)
+	pg.GET("/tasks/:id/comments", commentController.GetComments)
+	pg.POST("/tasks/:id/comments", commentController.CreateComment)
+	pg.PUT("/tasks/:id/comments/:comment_id", commentController.EditComment)
+	pg.DELETE("/tasks/:id/comments/:comment_id", commentController.DeleteComment)
+	pg.GET("/tasks/:id/attachments", attachmentController.GetAttachments)
+	pg.POST("/tasks/:id/attachments", attachmentController.CreateAttachment)
+	pg.GET("/tasks/:id/attachments/:attachment_id", attachmentController.DownloadAttachment)
+	pg.DELETE("/tasks/:id/attachments/:attachment_id", attachmentController.DeleteAttachment)
+	pg.POST("/tasks/:id/timer/start", timeController.StartTimer)
+	pg.POST("/tasks/:id/timer/stop", timeController.StopTimer)
+	pg.GET("/tasks/:id/time-entries", timeController.GetEntries)
+	pg.POST("/tasks/:id/time-entries", timeController.CreateEntry)
+	pg.PUT("/tasks/:id/time-entries/:entry_id", timeController.UpdateEntry)
+	pg.DELETE("/tasks/:id/time-entries/:entry_id", timeController.DeleteEntry)
+	pg.GET("/reports/time", timeController.Report)
+	pg.GET("/stats", statsController.GetStats)
+	pg.PUT("/tasks/:id/sprint", sprintController.SetTaskSprint)
+	pg.POST("/sprints", sprintController.CreateSprint)
+	pg.GET("/sprints", sprintController.GetSprints)
+	pg.GET("/sprints/:id", sprintController.GetSprint)
+	pg.GET("/sprints/:id/burndown", sprintController.Burndown)
+	pg.POST("/views", viewController.CreateView)
+	pg.GET("/views", viewController.GetViews)
+	pg.GET("/views/:id", viewController.GetView)
+	pg.PUT("/views/:id", viewController.UpdateView)
+	pg.DELETE("/views/:id", viewController.DeleteView)
+	pg.GET("/views/:id/tasks", viewController.GetViewTasks)
+	pg.POST("/projects", projectController.CreateProject)
+	pg.GET("/projects", projectController.GetProjects)
+	pg.GET("/projects/:id", projectController.GetProject)
+	pg.GET("/projects/:id/members", projectController.GetMembers)
+	pg.PUT("/projects/:id/members/:user_id", projectController.AddMember)
+	pg.DELETE("/projects/:id/members/:user_id", projectController.RemoveMember)
+	pg.POST("/users", userController.CreateUser)
+	pg.GET("/users", userController.GetUsers)
+	pg.GET("/users/:id", userController.GetUser)
+	pg.PUT("/users/:id/digest", userController.UpdateDigest)
 
-	// Graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	exitChan := make(chan os.Signal, 1)
-	signal.Notify(exitChan, os.Interrupt)
+	// Admin endpoints have no authentication, they listen on their own
+	// address that is only reachable locally by default
+	admin := echo.New()
+	admin.HideBanner = true
+	admin.HidePort = true
+	admin.Use(middleware.RequestID())
+	admin.Use(handlers.ContextLogger())
+	admin.HTTPErrorHandler = problems.HTTPErrorHandler
+	jobController := handlers.NewJobController(scheduler)
+	ag := admin.Group("/admin")
+	ag.GET("/jobs", jobController.GetJobs)
+	ag.POST("/jobs/:name/run", jobController.RunJob)
 
-	// Start overdue tasks monitor
-	dateWorker := jobs.NewDateWorker(taskService)
-	dateWorker.MonitorDueDate(ctx, time.Duration(cfg.Worker.Interval)*time.Second)
+	// Reload config on SIGHUP
+	controllers := []timeoutSetter{taskController, userController, projectController, assigneeController,
+		commentController, attachmentController, timeController, sprintController, statsController,
+		viewController}
+	reloader := newReloader(opts, cfg, controllers, scheduler, map[string]*ratelimit.Limiter{"public": publicLimiter})
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	defer signal.Stop(hupChan)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				log.Info().Msg("Got SIGHUP, reloading config")
+				reloader.reload()
+			case <-workerCtx.Done():
+				return
+			}
+		}
+	}()
 
 	// Start server
-	go e.Start(":" + cfg.Server.Port)
+	serverErr := make(chan error, 2)
+	go func() {
+		serverErr <- e.Start(":" + cfg.Server.Port)
+	}()
+	if cfg.Server.AdminAddr != "" {
+		go func() {
+			if err := admin.Start(cfg.Server.AdminAddr); !errors.Is(err, http.ErrServerClosed) {
+				serverErr <- fmt.Errorf("admin: %w", err)
+			}
+		}()
+		log.Info().Msg("Admin endpoints on " + cfg.Server.AdminAddr)
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		log.Info().Msg("Got shutdown signal")
+	case err := <-serverErr:
+		// The server never came up or died, shut down the rest
+		runErr = fmt.Errorf("start server: %w", err)
+	}
+
+	// Fail readiness first so the load balancer stops sending traffic
+	healthController.SetShuttingDown()
+	if runErr == nil {
+		time.Sleep(reloader.Config().Server.DrainDelay)
+	}
+	// Everything below shares one deadline
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), reloader.Config().Server.ShutdownTimeout)
+	defer cancel()
 
-	// Graceful shutdown
-	<-exitChan
-	log.Info().Msg("Got interrupt signal")
-	if err := e.Shutdown(context.Background()); err != nil {
-		log.Error().Err(err).Msg("Failed to shutdown server")
+	var errs []error
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown server: %w", err))
+	}
+	if err := admin.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown admin server: %w", err))
 	}
 	log.Info().Msg("Server stopped")
-	// Stop overdue tasks monitor
-	cancel()
-	// Wait for worker to finish
-	dateWorker.Wait()
-	// Close database connection after worker is done
+	// Stop background jobs and wait for running ones to finish
+	stopWorker()
+	jobsDone := make(chan struct{})
+	go func() {
+		scheduler.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		errs = append(errs, errors.New("background jobs did not stop before the shutdown deadline"))
+	}
+	// Close database connection after jobs are done
 	if err := db.Close(); err != nil {
-		log.Error().Err(err).Msg("Failed to close database connection")
+		errs = append(errs, fmt.Errorf("close database: %w", err))
 	}
 	log.Info().Msg("Database connection closed")
+	// Flush remaining spans
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown tracing: %w", err))
+	}
+
+	return errors.Join(append([]error{runErr}, errs...)...)
+}
+
+// newNotifier builds the configured reminder notifier
+func newNotifier(cfg *config.Config) notify.Notifier {
+	switch cfg.Reminders.Notifier {
+	case notify.KindWebhook:
+		return notify.Webhook{
+			URL:    cfg.Reminders.Webhook.URL,
+			Client: &http.Client{Timeout: cfg.Reminders.Webhook.Timeout},
+		}
+	case notify.KindSMTP:
+		smtp := cfg.Reminders.SMTP
+		return notify.SMTP{
+			SMTP: mail.SMTP{Addr: smtp.Addr, Username: smtp.Username, Password: smtp.Password},
+			From: smtp.From,
+			To:   smtp.To,
+		}
+	default:
+		return notify.Log{}
+	}
+}
+
+// newMailer builds the configured digest mailer
+func newMailer(cfg *config.Config) mail.Mailer {
+	if cfg.Digest.Mailer == mail.KindSMTP {
+		smtp := cfg.Digest.SMTP
+		return mail.SMTP{Addr: smtp.Addr, Username: smtp.Username, Password: smtp.Password}
+	}
+	return mail.FileDrop{Dir: cfg.Digest.Dir}
 }
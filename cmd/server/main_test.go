@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testConfig(t *testing.T, port int, adminPort int) []string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "db:\n" +
+		"  name: '" + filepath.Join(dir, "todo.db") + "'\n" +
+		"  migrations: '../../internal/db/migrations'\n" +
+		"server:\n" +
+		"  port: " + strconv.Itoa(port) + "\n" +
+		"  admin_addr: 127.0.0.1:" + strconv.Itoa(adminPort) + "\n" +
+		"  drain_delay: 0s\n" +
+		"  shutdown_timeout: 5s\n" +
+		"attachments:\n" +
+		"  dir: '" + filepath.Join(dir, "attachments") + "'\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	return []string{"-config", path}
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error finding a free port: %v", err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestRunShutsDownOnCancel(t *testing.T) {
+	port, adminPort := freePort(t), freePort(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, testConfig(t, port, adminPort), &bytes.Buffer{})
+	}()
+
+	// Wait for both servers to come up, admin endpoints are only served on
+	// the admin address
+	for _, tt := range []struct {
+		port   int
+		status int
+	}{{port, http.StatusNotFound}, {adminPort, http.StatusOK}} {
+		url := "http://127.0.0.1:" + strconv.Itoa(tt.port) + "/admin/jobs"
+		deadline := time.Now().Add(5 * time.Second)
+		res, err := http.Get(url)
+		for err != nil {
+			if time.Now().After(deadline) {
+				t.Fatalf("Server did not start: %v", err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			res, err = http.Get(url)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.status {
+			t.Errorf("Expected /admin/jobs on port %d to return %d, got %d", tt.port, tt.status, res.StatusCode)
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean shutdown, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("run did not return after cancel")
+	}
+}
+
+func TestRunFailsOnPortConflict(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Error listening: %v", err)
+	}
+	defer l.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(context.Background(), testConfig(t, l.Addr().(*net.TCPAddr).Port, freePort(t)), &bytes.Buffer{})
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "start server") {
+			t.Errorf("Expected start server error, got %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("run did not return on port conflict")
+	}
+}
+
+func TestRunRejectsBadUploadSize(t *testing.T) {
+	for _, set := range []string{"attachments.max_file_size=10MM", "attachments.max_task_size=lots"} {
+		args := append(testConfig(t, freePort(t), freePort(t)), "-set", set)
+		err := run(context.Background(), args, &bytes.Buffer{})
+		key, _, _ := strings.Cut(set, "=")
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected %s to fail startup, got %v", set, err)
+		}
+	}
+}
+
+func TestRunPrintConfig(t *testing.T) {
+	var out bytes.Buffer
+	args := append(testConfig(t, freePort(t), freePort(t)), "-print-config")
+	if err := run(context.Background(), args, &out); err != nil {
+		t.Fatalf("Error printing config: %v", err)
+	}
+	if !strings.Contains(out.String(), "shutdown_timeout: 5s") {
+		t.Errorf("Unexpected config output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+	"todo-api/internal/config"
+	"todo-api/internal/jobs"
+	"todo-api/internal/ratelimit"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// reloader re-reads the config file and applies the settings that can
+// change without a restart
+type reloader struct {
+	opts    *config.Options
+	current atomic.Pointer[config.Config]
+	// Controllers whose request timeout follows server.timeout
+	controllers []timeoutSetter
+	scheduler   jobs.IScheduler
+	limiters    map[string]*ratelimit.Limiter
+}
+
+type timeoutSetter interface {
+	SetTimeout(timeout time.Duration)
+}
+
+func newReloader(opts *config.Options, cfg *config.Config, controllers []timeoutSetter, scheduler jobs.IScheduler, limiters map[string]*ratelimit.Limiter) *reloader {
+	r := &reloader{opts: opts, controllers: controllers, scheduler: scheduler, limiters: limiters}
+	r.current.Store(cfg)
+	return r
+}
+
+// Config returns the config that is currently in effect
+func (r *reloader) Config() *config.Config {
+	return r.current.Load()
+}
+
+func (r *reloader) reload() {
+	next, err := config.Load(r.opts)
+	if err != nil {
+		// Keep running with the last good config
+		log.Error().Err(err).Msg("Config reload failed, keeping current config")
+		return
+	}
+	current := r.current.Load()
+
+	applied := []string{}
+	for _, key := range config.Changed(current, next) {
+		if config.RequiresRestart(key) {
+			log.Warn().Str("key", key).Msg("Config change requires a restart, ignoring it")
+		} else {
+			applied = append(applied, key)
+		}
+	}
+	if len(applied) == 0 {
+		log.Info().Msg("Config reloaded, nothing to apply")
+		return
+	}
+	// Settings that need a restart keep their current values
+	next.Db = current.Db
+	next.Server.Port = current.Server.Port
+	next.Server.BodyLimit = current.Server.BodyLimit
+	next.Server.AdminAddr = current.Server.AdminAddr
+	next.Tracing = current.Tracing
+	next.RateLimit.TrustedProxies = current.RateLimit.TrustedProxies
+	reminderInterval := next.Reminders.Interval
+	next.Reminders = current.Reminders
+	next.Reminders.Interval = reminderInterval
+	digestInterval := next.Digest.Interval
+	next.Digest = current.Digest
+	next.Digest.Interval = digestInterval
+	next.Board.MaxKeyLength = current.Board.MaxKeyLength
+	next.Attachments = current.Attachments
+	next.Stats = current.Stats
+
+	level, _ := zerolog.ParseLevel(next.Log.Level)
+	zerolog.SetGlobalLevel(level)
+	for _, controller := range r.controllers {
+		controller.SetTimeout(next.Server.Timeout)
+	}
+	if next.Worker.Interval != current.Worker.Interval {
+		r.scheduler.SetSchedule(jobs.OverdueJobName, jobs.Every(next.Worker.Interval))
+	}
+	if next.Reminders.Interval != current.Reminders.Interval {
+		r.scheduler.SetSchedule(jobs.ReminderJobName, jobs.Every(next.Reminders.Interval))
+		r.scheduler.SetSchedule(jobs.NotificationJobName, jobs.Every(next.Reminders.Interval))
+	}
+	if next.Digest.Interval != current.Digest.Interval {
+		r.scheduler.SetSchedule(jobs.DigestJobName, jobs.Every(next.Digest.Interval))
+	}
+	if next.Board.RebalanceInterval != current.Board.RebalanceInterval {
+		r.scheduler.SetSchedule(jobs.RebalanceJobName, jobs.Every(next.Board.RebalanceInterval))
+	}
+	for name, limiter := range r.limiters {
+		group := next.RateLimit.Groups[name]
+		limiter.SetLimits(newLimit(group.Read), newLimit(group.Write))
+		limiter.SetAPIKeys(next.RateLimit.APIKeys)
+	}
+
+	r.current.Store(next)
+	log.Info().Strs("changed", applied).Msg("Config reloaded")
+}
+
+func newLimit(rule config.RateLimitRule) ratelimit.Limit {
+	return ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/config"
+	"todo-api/internal/ratelimit"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+type recordingController struct {
+	timeouts []time.Duration
+}
+
+func (c *recordingController) SetTimeout(timeout time.Duration) {
+	c.timeouts = append(c.timeouts, timeout)
+}
+
+func TestReloadKeepsConfigOnBadFile(t *testing.T) {
+	var logs bytes.Buffer
+	logger, level := log.Logger, zerolog.GlobalLevel()
+	log.Logger = zerolog.New(&logs)
+	t.Cleanup(func() {
+		log.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	})
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Error writing config: %v", err)
+		}
+	}
+	write("server:\n  timeout: 2s\n")
+	opts := &config.Options{Path: path}
+	cfg, err := config.Load(opts)
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	controller := &recordingController{}
+	r := newReloader(opts, cfg, []timeoutSetter{controller}, nil, map[string]*ratelimit.Limiter{})
+
+	write("server:\n  timeout: 3s\n")
+	r.reload()
+	good := r.Config()
+	if good.Server.Timeout != 3*time.Second || len(controller.timeouts) != 1 {
+		t.Fatalf("Expected a good file to be applied, got timeout %s and %v", good.Server.Timeout, controller.timeouts)
+	}
+
+	for _, content := range []string{
+		"server:\n  timeout: [5s\n",
+		"server:\n  timeout: 5\n",
+		"server:\n  timeout: -1s\n",
+	} {
+		logs.Reset()
+		write(content)
+		r.reload()
+		if r.Config() != good {
+			t.Errorf("Expected %q to keep the current config, got %+v", content, r.Config().Server)
+		}
+		if len(controller.timeouts) != 1 {
+			t.Errorf("Expected %q to leave the controllers alone, got %v", content, controller.timeouts)
+		}
+		if !strings.Contains(logs.String(), `"level":"error"`) || !strings.Contains(logs.String(), "Config reload failed") {
+			t.Errorf("Expected %q to log an error, got %s", content, logs.String())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+// Command smtptest runs a local SMTP server that logs every message it
+// receives, for trying the smtp reminder notifier without a mail server
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"todo-api/internal/notify/smtptest"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	addr := flag.String("addr", "localhost:1025", "address to listen on")
+	flag.Parse()
+
+	server, err := smtptest.NewServer(*addr)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to start SMTP server")
+	}
+	server.OnMessage = func(msg smtptest.Message) {
+		log.Info().Str("from", msg.From).Strs("to", msg.To).Msg("Message received\n" + msg.Data)
+	}
+	log.Info().Msg("Listening on " + server.Addr)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	server.Close()
+}
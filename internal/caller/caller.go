@@ -0,0 +1,25 @@
+// Package caller identifies the user making a request. There is no
+// authentication yet, clients name themselves with the X-User-ID header.
+// Anyone can send any id, so checks based on the caller, such as comment
+// authorship or time entry ownership, are advisory and guard against
+// mistakes, not against other clients.
+package caller
+
+import "context"
+
+// Header carries the id of the calling user
+const Header = "X-User-ID"
+
+type contextKey struct{}
+
+// WithUser returns a context that carries the calling user's id
+func WithUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, contextKey{}, userID)
+}
+
+// FromContext returns the calling user's id, false when the caller did not
+// say who they are
+func FromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(contextKey{}).(int)
+	return userID, ok
+}
@@ -0,0 +1,47 @@
+package clock
+
+import "time"
+
+// Clock tells the current time. Code that decides things based on "now"
+// takes a Clock so tests can control time.
+type Clock interface {
+	Now() time.Time
+	// NewTimer returns a timer that fires once d has passed on this clock
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a single-shot timer driven by a Clock
+type Timer interface {
+	// C receives the time the timer fired at
+	C() <-chan time.Time
+	// Stop prevents the timer from firing, it reports whether it was
+	// still pending
+	Stop() bool
+}
+
+type realClock struct{}
+
+// New returns the system clock
+func New() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t realTimer) Stop() bool {
+	return t.timer.Stop()
+}
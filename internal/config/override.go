@@ -0,0 +1,211 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const envPrefix = "TODO_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Set assigns value to the field at the dotted YAML path key,
+// e.g. "server.timeout" or "rate_limit.groups.public.read.rate"
+func (c *Config) Set(key string, value string) error {
+	if err := setPath(reflect.ValueOf(c).Elem(), strings.Split(key, "."), value); err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	return nil
+}
+
+func setPath(v reflect.Value, path []string, value string) error {
+	if len(path) == 0 {
+		return setValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if yamlName(v.Type().Field(i)) == path[0] {
+				return setPath(v.Field(i), path[1:], value)
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		// Map entries are not addressable, update a copy and store it back
+		key := reflect.ValueOf(path[0])
+		entry := reflect.New(v.Type().Elem()).Elem()
+		if existing := v.MapIndex(key); existing.IsValid() {
+			entry.Set(existing)
+		}
+		if err := setPath(entry, path[1:], value); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, entry)
+		return nil
+	}
+	return fmt.Errorf("unknown config key")
+}
+
+func setValue(v reflect.Value, value string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		// Comma separated, every element is parsed like a single value
+		parts := strings.Split(value, ",")
+		if value == "" {
+			parts = nil
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
+
+// Keys lists the dotted YAML paths of every leaf field. Map entries are
+// listed for the keys present in the config.
+func (c *Config) Keys() []string {
+	keys := []string{}
+	collectKeys(reflect.ValueOf(c).Elem(), "", &keys)
+	return keys
+}
+
+func collectKeys(v reflect.Value, prefix string, keys *[]string) {
+	if v.Type() == durationType {
+		*keys = append(*keys, prefix)
+		return
+	}
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			collectKeys(v.Field(i), join(yamlName(v.Type().Field(i))), keys)
+		}
+	case reflect.Map:
+		mapKeys := []string{}
+		for _, k := range v.MapKeys() {
+			mapKeys = append(mapKeys, k.String())
+		}
+		sort.Strings(mapKeys)
+		for _, k := range mapKeys {
+			collectKeys(v.MapIndex(reflect.ValueOf(k)), join(k), keys)
+		}
+	default:
+		*keys = append(*keys, prefix)
+	}
+}
+
+// EnvName returns the environment variable that overrides key
+func EnvName(key string) string {
+	return envPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+func applyEnv(c *Config, lookup func(string) (string, bool)) []error {
+	var errs []error
+	for _, key := range c.Keys() {
+		if value, ok := lookup(EnvName(key)); ok {
+			if err := c.Set(key, value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", EnvName(key), err))
+			}
+		}
+	}
+	return errs
+}
+
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
+
+// Redacted returns the config as YAML with secret fields masked
+func (c *Config) Redacted() ([]byte, error) {
+	var node yaml.Node
+	if err := node.Encode(c); err != nil {
+		return nil, err
+	}
+	redact(reflect.TypeOf(*c), &node)
+	return yaml.Marshal(&node)
+}
+
+// redactValue masks a secret value, or each value of a secret list
+func redactValue(node *yaml.Node) {
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			redactValue(item)
+		}
+	} else if node.Value != "" {
+		node.Value = "REDACTED"
+	}
+}
+
+func redact(t reflect.Type, node *yaml.Node) {
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			for j := 0; j < t.NumField(); j++ {
+				field := t.Field(j)
+				if yamlName(field) != node.Content[i].Value {
+					continue
+				}
+				if field.Tag.Get("secret") == "true" {
+					redactValue(node.Content[i+1])
+				} else {
+					redact(field.Type, node.Content[i+1])
+				}
+			}
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			redact(t.Elem(), node.Content[i])
+		}
+	}
+}
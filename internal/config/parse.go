@@ -1,53 +1,241 @@
 package config
 
 import (
+	"errors"
 	"flag"
-	"gopkg.in/yaml.v3"
+	"fmt"
 	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
 )
 
+type RateLimitRule struct {
+	Rate  float64 `yaml:"rate"`
+	Burst int     `yaml:"burst"`
+}
+
+type RateLimitGroup struct {
+	Read  RateLimitRule `yaml:"read"`
+	Write RateLimitRule `yaml:"write"`
+}
+
+// Config is the application configuration. Every field can be overridden
+// with a TODO_* environment variable named after its YAML path, e.g.
+// server.port is TODO_SERVER_PORT, and with the -set CLI flag.
+// Fields tagged secret:"true" are redacted when printed.
 type Config struct {
 	Db struct {
-		Name string `yaml:"name"`
+		Name       string `yaml:"name"`
+		Migrations string `yaml:"migrations"`
 	} `yaml:"db"`
 	Server struct {
-		Port    string `yaml:"port"`
-		Timeout int    `yaml:"timeout"`
+		Port            string        `yaml:"port"`
+		Timeout         time.Duration `yaml:"timeout"`
+		DrainDelay      time.Duration `yaml:"drain_delay"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+		BodyLimit       string        `yaml:"body_limit"`
+		// host:port of the unauthenticated admin endpoints, empty turns
+		// them off
+		AdminAddr string `yaml:"admin_addr"`
 	} `yaml:"server"`
 	Worker struct {
-		Interval int `yaml:"interval"`
+		Interval time.Duration `yaml:"interval"`
+		Jitter   time.Duration `yaml:"jitter"`
+		// Overdue tasks get one priority level higher after this many days,
+		// 0 disables escalation
+		EscalateAfterDays int `yaml:"escalate_after_days"`
 	} `yaml:"worker"`
+	Log struct {
+		Level string `yaml:"level"`
+	} `yaml:"log"`
+	Tracing struct {
+		Exporter    string `yaml:"exporter"`
+		Endpoint    string `yaml:"endpoint"`
+		ServiceName string `yaml:"service_name"`
+	} `yaml:"tracing"`
+	RateLimit struct {
+		Groups map[string]RateLimitGroup `yaml:"groups"`
+		// Proxies, as IPs or CIDRs, whose X-Forwarded-For header names the
+		// client. Without any the connection's remote address is the client.
+		TrustedProxies []string `yaml:"trusted_proxies"`
+		// Clients sending one of these in X-API-Key are limited per key
+		// instead of per IP, other keys are ignored
+		APIKeys []string `yaml:"api_keys" secret:"true"`
+	} `yaml:"rate_limit"`
+	Reminders struct {
+		// Lead times for tasks that do not set their own
+		Default  []time.Duration `yaml:"default"`
+		Interval time.Duration   `yaml:"interval"`
+		Notifier string          `yaml:"notifier"`
+		Webhook  struct {
+			URL     string        `yaml:"url"`
+			Timeout time.Duration `yaml:"timeout"`
+		} `yaml:"webhook"`
+		SMTP struct {
+			Addr     string   `yaml:"addr"`
+			From     string   `yaml:"from"`
+			To       []string `yaml:"to"`
+			Username string   `yaml:"username"`
+			Password string   `yaml:"password" secret:"true"`
+		} `yaml:"smtp"`
+	} `yaml:"reminders"`
+	Digest struct {
+		// How often users whose send time has passed are checked
+		Interval time.Duration `yaml:"interval"`
+		From     string        `yaml:"from"`
+		Mailer   string        `yaml:"mailer"`
+		// Directory the file mailer writes messages to
+		Dir  string `yaml:"dir"`
+		SMTP struct {
+			Addr     string `yaml:"addr"`
+			Username string `yaml:"username"`
+			Password string `yaml:"password" secret:"true"`
+		} `yaml:"smtp"`
+	} `yaml:"digest"`
+	Board struct {
+		// How often board positions are checked for keys to shorten
+		RebalanceInterval time.Duration `yaml:"rebalance_interval"`
+		// Columns with a position key longer than this are rebalanced
+		MaxKeyLength int `yaml:"max_key_length"`
+	} `yaml:"board"`
+	Attachments struct {
+		// Directory of the file blob store
+		Dir string `yaml:"dir"`
+		// Sizes such as 10M
+		MaxFileSize string `yaml:"max_file_size"`
+		MaxTaskSize string `yaml:"max_task_size"`
+	} `yaml:"attachments"`
+	Stats struct {
+		// How long GET /stats answers are reused, 0 disables the cache
+		CacheTTL time.Duration `yaml:"cache_ttl"`
+	} `yaml:"stats"`
 }
 
-func NewConfig(path string) (*Config, error) {
+// Options are the command line options
+type Options struct {
+	Path        string
+	PrintConfig bool
+	// Overrides in key=value form, keys are YAML paths like server.port
+	Overrides []string
+}
+
+// Default returns the configuration used for every field the file,
+// environment and flags leave unset
+func Default() *Config {
 	config := &Config{}
+	config.Db.Name = "./data/todo.db"
+	config.Db.Migrations = "internal/db/migrations"
+	config.Server.Port = "8080"
+	config.Server.Timeout = 5 * time.Second
+	config.Server.DrainDelay = 5 * time.Second
+	config.Server.ShutdownTimeout = 15 * time.Second
+	config.Server.BodyLimit = "1M"
+	config.Server.AdminAddr = "127.0.0.1:8081"
+	config.Worker.Interval = 10 * time.Second
+	config.Log.Level = "info"
+	config.Tracing.Exporter = "none"
+	config.Tracing.ServiceName = "todo-api"
+	config.RateLimit.Groups = map[string]RateLimitGroup{
+		"public": {
+			Read:  RateLimitRule{Rate: 20, Burst: 40},
+			Write: RateLimitRule{Rate: 5, Burst: 10},
+		},
+	}
+	config.Reminders.Default = []time.Duration{24 * time.Hour, time.Hour}
+	config.Reminders.Interval = 30 * time.Second
+	config.Reminders.Notifier = "log"
+	config.Reminders.Webhook.Timeout = 5 * time.Second
+	config.Reminders.SMTP.Addr = "localhost:1025"
+	config.Reminders.SMTP.From = "todo-api@localhost"
+	config.Digest.Interval = time.Minute
+	config.Digest.From = "todo-api@localhost"
+	config.Digest.Mailer = "file"
+	config.Digest.Dir = "./data/mail"
+	config.Digest.SMTP.Addr = "localhost:1025"
+	config.Board.RebalanceInterval = time.Hour
+	config.Board.MaxKeyLength = 12
+	config.Attachments.Dir = "./data/attachments"
+	config.Attachments.MaxFileSize = "10M"
+	config.Attachments.MaxTaskSize = "50M"
+	config.Stats.CacheTTL = 30 * time.Second
+	return config
+}
 
-	file, err := os.ReadFile(path)
+// NewConfig reads the config file at path on top of the defaults and
+// applies environment overrides
+func NewConfig(path string) (*Config, error) {
+	return Load(&Options{Path: path})
+}
+
+// Load builds the effective configuration: defaults, then the config file,
+// then TODO_* environment variables, then CLI overrides. The result is
+// validated and every problem is reported at once.
+func Load(opts *Options) (*Config, error) {
+	config := Default()
+
+	file, err := os.ReadFile(opts.Path)
 	if err != nil {
 		return nil, err
 	}
 
-	err = yaml.Unmarshal(file, &config)
+	err = yaml.Unmarshal(file, config)
 	if err != nil {
 		return nil, err
 	}
 
+	errs := applyEnv(config, os.LookupEnv)
+	for _, override := range opts.Overrides {
+		key, value, ok := strings.Cut(override, "=")
+		if !ok {
+			errs = append(errs, fmt.Errorf("invalid override %q, expected key=value", override))
+			continue
+		}
+		if err := config.Set(key, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// A bad override must not hide the problems of the other fields
+	errs = append(errs, config.Validate())
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
 	return config, nil
 }
 
-func ParseCLI() (string, error) {
-	var path string
+// overrides collects repeated -set flags
+type overrides []string
 
-	flag.StringVar(&path, "config", "./config.yaml", "path to config file")
-	flag.Parse()
+func (o *overrides) String() string {
+	return strings.Join(*o, ",")
+}
 
-	s, err := os.Stat(path)
+func (o *overrides) Set(value string) error {
+	*o = append(*o, value)
+	return nil
+}
+
+func ParseCLI(args []string) (*Options, error) {
+	opts := &Options{}
+	var set overrides
+
+	fs := flag.NewFlagSet("todo-api", flag.ContinueOnError)
+	fs.StringVar(&opts.Path, "config", "./config.yaml", "path to config file")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective config with secrets redacted and exit")
+	fs.Var(&set, "set", "override a config value, e.g. -set server.port=8081 (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	opts.Overrides = set
+
+	s, err := os.Stat(opts.Path)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	if s.IsDir() {
-		return "", err
+		return nil, fmt.Errorf("config path %s is a directory", opts.Path)
 	}
 
-	return path, nil
+	return opts, nil
 }
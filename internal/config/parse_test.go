@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: 9000
+  timeout: 2s
+worker:
+  interval: 1m
+`)
+	t.Setenv("TODO_SERVER_TIMEOUT", "3s")
+	t.Setenv("TODO_RATE_LIMIT_GROUPS_PUBLIC_WRITE_BURST", "3")
+	t.Setenv("TODO_REMINDERS_DEFAULT", "2h, 30m")
+
+	cfg, err := Load(&Options{Path: path, Overrides: []string{"server.port=9001"}})
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if cfg.Server.Port != "9001" {
+		t.Errorf("Expected flag to override port, got %s", cfg.Server.Port)
+	}
+	if cfg.Server.Timeout != 3*time.Second {
+		t.Errorf("Expected env to override timeout, got %s", cfg.Server.Timeout)
+	}
+	if cfg.Worker.Interval != time.Minute {
+		t.Errorf("Expected file interval, got %s", cfg.Worker.Interval)
+	}
+	if cfg.Db.Name != "./data/todo.db" {
+		t.Errorf("Expected default db name, got %s", cfg.Db.Name)
+	}
+	if cfg.RateLimit.Groups["public"].Write.Burst != 3 {
+		t.Errorf("Expected env to override map entry, got %+v", cfg.RateLimit.Groups["public"])
+	}
+	if want := []time.Duration{2 * time.Hour, 30 * time.Minute}; len(cfg.Reminders.Default) != 2 ||
+		cfg.Reminders.Default[0] != want[0] || cfg.Reminders.Default[1] != want[1] {
+		t.Errorf("Expected env to override reminder lead times, got %v", cfg.Reminders.Default)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	path := writeConfig(t, `
+server:
+  port: ''
+  timeout: 0s
+tracing:
+  exporter: 'zipkin'
+reminders:
+  notifier: 'smtp'
+`)
+	_, err := Load(&Options{Path: path, Overrides: []string{"log.level=loud"}})
+	if err == nil {
+		t.Fatalf("Expected validation error")
+	}
+	for _, key := range []string{"server.port", "server.timeout", "tracing.exporter", "log.level", "reminders.smtp.to"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error for %s in %q", key, err)
+		}
+	}
+}
+
+func TestOverrideErrorsDoNotHideValidation(t *testing.T) {
+	path := writeConfig(t, "tracing:\n  exporter: 'zipkin'\n")
+	t.Setenv("TODO_SERVER_TIMEOUT", "soon")
+	_, err := Load(&Options{Path: path, Overrides: []string{"server.port"}})
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	for _, want := range []string{"TODO_SERVER_TIMEOUT", `"server.port"`, "tracing.exporter"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error for %s in %q", want, err)
+		}
+	}
+}
+
+func TestBareIntegerDurationIsRejected(t *testing.T) {
+	path := writeConfig(t, "server:\n  timeout: 5\n")
+	if _, err := Load(&Options{Path: path}); err == nil {
+		t.Errorf("Expected bare integer timeout to be rejected")
+	}
+}
+
+func TestParseCLIRejectsDirectory(t *testing.T) {
+	if _, err := ParseCLI([]string{"-config", t.TempDir()}); err == nil {
+		t.Errorf("Expected error for directory config path")
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default()
+	cfg.Reminders.SMTP.Password = "hunter2"
+	cfg.RateLimit.APIKeys = []string{"key-1", "key-2"}
+	out, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Error printing config: %v", err)
+	}
+	if !strings.Contains(string(out), "timeout: 5s") {
+		t.Errorf("Expected durations to print with units:\n%s", out)
+	}
+	if strings.Contains(string(out), "hunter2") {
+		t.Errorf("Expected the SMTP password to be redacted:\n%s", out)
+	}
+	if strings.Contains(string(out), "key-1") || strings.Contains(string(out), "key-2") {
+		t.Errorf("Expected the API keys to be redacted:\n%s", out)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	old := Default()
+	new := Default()
+	new.Server.Port = "9090"
+	new.Worker.Interval = time.Minute
+	new.RateLimit.Groups["admin"] = RateLimitGroup{}
+
+	changed := strings.Join(Changed(old, new), ",")
+	for _, key := range []string{"server.port", "worker.interval", "rate_limit.groups.admin.read.rate"} {
+		if !strings.Contains(changed, key) {
+			t.Errorf("Expected %s to be changed, got %s", key, changed)
+		}
+	}
+	if strings.Contains(changed, "server.timeout") {
+		t.Errorf("Unexpected change of server.timeout")
+	}
+	if !RequiresRestart("server.port") || !RequiresRestart("db.name") || RequiresRestart("worker.interval") {
+		t.Errorf("Unexpected restart classification")
+	}
+}
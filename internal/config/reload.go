@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Keys, or key prefixes ending in a dot, that are only read at startup
+var restartKeys = []string{
+	"db.",
+	"server.port",
+	"server.body_limit",
+	"server.admin_addr",
+	"worker.jitter",
+	"worker.escalate_after_days",
+	"tracing.",
+	"rate_limit.trusted_proxies",
+	"reminders.default",
+	"reminders.notifier",
+	"reminders.webhook.",
+	"reminders.smtp.",
+	"digest.from",
+	"digest.mailer",
+	"digest.dir",
+	"digest.smtp.",
+	"board.max_key_length",
+	"attachments.",
+	"stats.",
+}
+
+// RequiresRestart reports whether a change to key only takes effect after
+// a restart
+func RequiresRestart(key string) bool {
+	for _, restartKey := range restartKeys {
+		if key == restartKey || (strings.HasSuffix(restartKey, ".") && strings.HasPrefix(key, restartKey)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Changed lists the keys whose values differ between old and new
+func Changed(old *Config, new *Config) []string {
+	oldValues := values(old)
+	newValues := values(new)
+	changed := []string{}
+	for _, key := range new.Keys() {
+		if oldValue, ok := oldValues[key]; !ok || oldValue != newValues[key] {
+			changed = append(changed, key)
+		}
+	}
+	for _, key := range old.Keys() {
+		if _, ok := newValues[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	return changed
+}
+
+func values(c *Config) map[string]string {
+	values := map[string]string{}
+	for _, key := range c.Keys() {
+		values[key] = fmt.Sprint(lookup(reflect.ValueOf(c).Elem(), strings.Split(key, ".")).Interface())
+	}
+	return values
+}
+
+func lookup(v reflect.Value, path []string) reflect.Value {
+	for _, name := range path {
+		switch v.Kind() {
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if yamlName(v.Type().Field(i)) == name {
+					v = v.Field(i)
+					break
+				}
+			}
+		case reflect.Map:
+			v = v.MapIndex(reflect.ValueOf(name))
+		}
+	}
+	return v
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/labstack/gommon/bytes"
+	"github.com/rs/zerolog"
+)
+
+var tracingExporters = map[string]bool{"none": true, "stdout": true, "otlp": true}
+
+var notifiers = map[string]bool{"log": true, "webhook": true, "smtp": true}
+
+var mailers = map[string]bool{"smtp": true, "file": true}
+
+// Validate checks the config and reports every problem it finds
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, key string, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+		}
+	}
+
+	check(c.Db.Name != "", "db.name", "is required")
+	check(c.Db.Migrations != "", "db.migrations", "is required")
+
+	port, err := strconv.Atoi(c.Server.Port)
+	check(err == nil && port > 0 && port < 65536, "server.port", "must be a port number, got %q", c.Server.Port)
+	check(c.Server.Timeout > 0, "server.timeout", "must be positive")
+	check(c.Server.DrainDelay >= 0, "server.drain_delay", "must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	_, err = bytes.Parse(c.Server.BodyLimit)
+	check(err == nil, "server.body_limit", "must be a size such as 1M, got %q", c.Server.BodyLimit)
+	if c.Server.AdminAddr != "" {
+		_, _, err = net.SplitHostPort(c.Server.AdminAddr)
+		check(err == nil, "server.admin_addr", "must be host:port, got %q", c.Server.AdminAddr)
+	}
+
+	check(c.Worker.Interval > 0, "worker.interval", "must be positive")
+	check(c.Worker.Jitter >= 0, "worker.jitter", "must not be negative")
+	check(c.Worker.EscalateAfterDays >= 0, "worker.escalate_after_days", "must not be negative")
+
+	_, err = zerolog.ParseLevel(c.Log.Level)
+	check(err == nil && c.Log.Level != "", "log.level", "unknown level %q", c.Log.Level)
+
+	check(tracingExporters[c.Tracing.Exporter], "tracing.exporter", "must be one of none, stdout, otlp, got %q", c.Tracing.Exporter)
+	check(c.Tracing.ServiceName != "", "tracing.service_name", "is required")
+
+	names := make([]string, 0, len(c.RateLimit.Groups))
+	for name := range c.RateLimit.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		group := c.RateLimit.Groups[name]
+		for _, rule := range []struct {
+			kind string
+			RateLimitRule
+		}{{"read", group.Read}, {"write", group.Write}} {
+			key := "rate_limit.groups." + name + "." + rule.kind
+			check(rule.Rate >= 0, key+".rate", "must not be negative")
+			check(rule.Rate == 0 || rule.Burst >= 1, key+".burst", "must be at least 1 when rate is set")
+		}
+	}
+
+	for _, proxy := range c.RateLimit.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		check(err == nil || net.ParseIP(proxy) != nil, "rate_limit.trusted_proxies", "must be IPs or CIDRs, got %q", proxy)
+	}
+	for _, key := range c.RateLimit.APIKeys {
+		check(key != "", "rate_limit.api_keys", "must not be empty")
+	}
+
+	for _, lead := range c.Reminders.Default {
+		check(lead > 0, "reminders.default", "lead times must be positive, got %s", lead)
+	}
+	check(c.Reminders.Interval > 0, "reminders.interval", "must be positive")
+	check(notifiers[c.Reminders.Notifier], "reminders.notifier", "must be one of log, webhook, smtp, got %q", c.Reminders.Notifier)
+	if c.Reminders.Notifier == "webhook" {
+		check(c.Reminders.Webhook.URL != "", "reminders.webhook.url", "is required by the webhook notifier")
+		check(c.Reminders.Webhook.Timeout > 0, "reminders.webhook.timeout", "must be positive")
+	}
+	if c.Reminders.Notifier == "smtp" {
+		check(c.Reminders.SMTP.Addr != "", "reminders.smtp.addr", "is required by the smtp notifier")
+		check(c.Reminders.SMTP.From != "", "reminders.smtp.from", "is required by the smtp notifier")
+		check(len(c.Reminders.SMTP.To) > 0, "reminders.smtp.to", "is required by the smtp notifier")
+	}
+
+	check(c.Digest.Interval > 0, "digest.interval", "must be positive")
+	check(c.Digest.From != "", "digest.from", "is required")
+	check(mailers[c.Digest.Mailer], "digest.mailer", "must be one of smtp, file, got %q", c.Digest.Mailer)
+	if c.Digest.Mailer == "file" {
+		check(c.Digest.Dir != "", "digest.dir", "is required by the file mailer")
+	}
+	if c.Digest.Mailer == "smtp" {
+		check(c.Digest.SMTP.Addr != "", "digest.smtp.addr", "is required by the smtp mailer")
+	}
+
+	check(c.Board.RebalanceInterval > 0, "board.rebalance_interval", "must be positive")
+	check(c.Board.MaxKeyLength > 0, "board.max_key_length", "must be positive")
+
+	check(c.Attachments.Dir != "", "attachments.dir", "is required")
+	maxFile, err := bytes.Parse(c.Attachments.MaxFileSize)
+	check(err == nil && maxFile > 0, "attachments.max_file_size", "must be a size such as 10M, got %q", c.Attachments.MaxFileSize)
+	maxTask, err := bytes.Parse(c.Attachments.MaxTaskSize)
+	check(err == nil && maxTask > 0, "attachments.max_task_size", "must be a size such as 50M, got %q", c.Attachments.MaxTaskSize)
+	check(maxTask >= maxFile, "attachments.max_task_size", "must not be below attachments.max_file_size")
+
+	check(c.Stats.CacheTTL >= 0, "stats.cache_ttl", "must not be negative")
+
+	return errors.Join(errs...)
+}
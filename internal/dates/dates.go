@@ -0,0 +1,280 @@
+// Package dates resolves due dates typed by people, such as tomorrow,
+// next friday, in 3 days, end of month or tomorrow 5pm. Phrases are
+// resolved against a given now in the caller's time zone: a day makes an
+// all-day date, a day with a time of day a date and time.
+package dates
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnrecognized = errors.New("dates: unrecognized date")
+
+// Latest year a date may fall in, later ones cannot be rendered as JSON
+const maxYear = 9999
+
+// Date is a resolved phrase. Time is midnight in the zone for all-day dates.
+type Date struct {
+	Time   time.Time
+	AllDay bool
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var (
+	// A time of day ending the phrase, e.g. 5pm, at 9:30am, 17:00 or noon
+	timeOfDay = regexp.MustCompile(`(?:^|\s)(?:at\s+)?(noon|midnight|(\d{1,2})(?::(\d{2}))?\s*(am|pm)|(\d{1,2}):(\d{2}))$`)
+	// At most 999 of a unit, which keeps times far from overflowing
+	relative = regexp.MustCompile(`^in\s+(a|an|\d{1,3})\s+(minute|hour|day|week|month|year)s?$`)
+	isoDay   = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	// december 1, dec 1st 2025
+	monthDay = regexp.MustCompile(`^([a-z]+)\s+(\d{1,2})(?:st|nd|rd|th)?(?:,?\s+(\d{4}))?$`)
+	// 1 december, 1st dec 2025
+	dayMonth = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?\s+([a-z]+)(?:,?\s+(\d{4}))?$`)
+)
+
+// Parse resolves a phrase at now in loc. A time of day without a day is
+// today, or tomorrow once that time has passed.
+func Parse(phrase string, now time.Time, loc *time.Location) (Date, error) {
+	text := strings.Join(strings.Fields(strings.ToLower(phrase)), " ")
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	if match := relative.FindStringSubmatch(text); match != nil {
+		n, err := count(match[1])
+		if err != nil {
+			return Date{}, ErrUnrecognized
+		}
+		switch match[2] {
+		case "minute":
+			return checked(Date{Time: now.Add(time.Duration(n) * time.Minute)})
+		case "hour":
+			return checked(Date{Time: now.Add(time.Duration(n) * time.Hour)})
+		}
+	}
+
+	dayPhrase := text
+	var hour, minute int
+	timed := false
+	if match := timeOfDay.FindStringSubmatch(text); match != nil {
+		var ok bool
+		hour, minute, ok = clock(match)
+		if !ok {
+			return Date{}, ErrUnrecognized
+		}
+		timed = true
+		dayPhrase = strings.TrimSpace(strings.TrimSuffix(text, match[0]))
+	}
+
+	var day time.Time
+	if dayPhrase == "" {
+		if !timed {
+			return Date{}, ErrUnrecognized
+		}
+		day = today
+		if !at(day, hour, minute).After(now) {
+			day = day.AddDate(0, 0, 1)
+		}
+	} else {
+		var ok bool
+		day, ok = resolveDay(dayPhrase, today)
+		if !ok {
+			return Date{}, ErrUnrecognized
+		}
+	}
+	if timed {
+		return checked(Date{Time: at(day, hour, minute)})
+	}
+	return checked(Date{Time: day, AllDay: true})
+}
+
+// checked rejects dates too far in the future to be stored and rendered
+func checked(d Date) (Date, error) {
+	if d.Time.Year() > maxYear {
+		return Date{}, ErrUnrecognized
+	}
+	return d, nil
+}
+
+// count reads the count of a relative phrase, a and an are 1
+func count(value string) (int, error) {
+	if value == "a" || value == "an" {
+		return 1, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// clock reads the hour and minute of a timeOfDay match
+func clock(match []string) (int, int, bool) {
+	switch {
+	case match[1] == "noon":
+		return 12, 0, true
+	case match[1] == "midnight":
+		return 0, 0, true
+	case match[4] != "":
+		hour, _ := strconv.Atoi(match[2])
+		minute := 0
+		if match[3] != "" {
+			minute, _ = strconv.Atoi(match[3])
+		}
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if match[4] == "pm" {
+			hour += 12
+		}
+		return hour, minute, true
+	}
+	hour, _ := strconv.Atoi(match[5])
+	minute, _ := strconv.Atoi(match[6])
+	return hour, minute, hour <= 23 && minute <= 59
+}
+
+// at is the time of day on day, in day's zone
+func at(day time.Time, hour int, minute int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+}
+
+// resolveDay resolves a phrase naming a day, today is midnight in the
+// caller's zone
+func resolveDay(text string, today time.Time) (time.Time, bool) {
+	switch text {
+	case "today", "end of day", "eod":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	case "day after tomorrow":
+		return today.AddDate(0, 0, 2), true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "next week":
+		return startOfWeek(today).AddDate(0, 0, 7), true
+	case "next month":
+		return time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, today.Location()), true
+	case "next year":
+		return time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, today.Location()), true
+	case "end of week", "end of the week", "eow":
+		return startOfWeek(today).AddDate(0, 0, 6), true
+	case "end of month", "end of the month", "eom":
+		return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, today.Location()), true
+	case "end of year", "end of the year":
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, today.Location()), true
+	}
+
+	if isoDay.MatchString(text) {
+		day, err := time.ParseInLocation("2006-01-02", text, today.Location())
+		return day, err == nil
+	}
+	if match := relative.FindStringSubmatch(text); match != nil {
+		n, err := count(match[1])
+		if err != nil {
+			return time.Time{}, false
+		}
+		switch match[2] {
+		case "day":
+			return today.AddDate(0, 0, n), true
+		case "week":
+			return today.AddDate(0, 0, 7*n), true
+		case "month":
+			return addMonths(today, n), true
+		case "year":
+			return addMonths(today, 12*n), true
+		}
+		// Minutes and hours are not days, in 2 hours 5pm makes no sense
+		return time.Time{}, false
+	}
+
+	// A bare weekday is the coming one, today included; next friday is the
+	// friday of next week, this friday the one of the current week
+	words := strings.Fields(text)
+	if weekday, ok := weekdays[words[len(words)-1]]; ok && len(words) <= 2 {
+		offset := (int(weekday) - int(today.Weekday()) + 7) % 7
+		if len(words) == 1 {
+			return today.AddDate(0, 0, offset), true
+		}
+		// Weeks start on Monday, Sunday is their last day
+		fromMonday := (int(weekday) + 6) % 7
+		switch words[0] {
+		case "this":
+			return startOfWeek(today).AddDate(0, 0, fromMonday), true
+		case "next":
+			return startOfWeek(today).AddDate(0, 0, 7+fromMonday), true
+		}
+		return time.Time{}, false
+	}
+
+	var monthName, dayText, yearText string
+	if match := monthDay.FindStringSubmatch(text); match != nil {
+		monthName, dayText, yearText = match[1], match[2], match[3]
+	} else if match := dayMonth.FindStringSubmatch(text); match != nil {
+		dayText, monthName, yearText = match[1], match[2], match[3]
+	} else {
+		return time.Time{}, false
+	}
+	month, ok := months[monthName]
+	if !ok {
+		return time.Time{}, false
+	}
+	dayOfMonth, _ := strconv.Atoi(dayText)
+	year := today.Year()
+	if yearText != "" {
+		year, _ = strconv.Atoi(yearText)
+	}
+	day := time.Date(year, month, dayOfMonth, 0, 0, 0, 0, today.Location())
+	if day.Month() != month || day.Day() != dayOfMonth {
+		// February 30 and the like
+		return time.Time{}, false
+	}
+	if yearText == "" && day.Before(today) {
+		// A day that has passed this year is next year's
+		day = day.AddDate(1, 0, 0)
+		if day.Day() != dayOfMonth {
+			return time.Time{}, false
+		}
+	}
+	return day, true
+}
+
+// startOfWeek is the Monday of day's week
+func startOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// addMonths adds n months, clipped to the last day of the month so that a
+// month after January 31 is the end of February
+func addMonths(day time.Time, n int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(n), 1, 0, 0, 0, 0, day.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if day.Day() < last {
+		last = day.Day()
+	}
+	return time.Date(first.Year(), first.Month(), last, 0, 0, 0, 0, day.Location())
+}
@@ -0,0 +1,161 @@
+package dates
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	day := func(year int, month time.Month, d int) Date {
+		return Date{Time: time.Date(year, month, d, 0, 0, 0, 0, time.UTC), AllDay: true}
+	}
+	timed := func(year int, month time.Month, d int, hour int, minute int) Date {
+		return Date{Time: time.Date(year, month, d, hour, minute, 0, 0, time.UTC)}
+	}
+	tests := []struct {
+		phrase string
+		now    time.Time
+		loc    *time.Location
+		want   Date
+	}{
+		{"today", now, time.UTC, day(2024, 11, 20)},
+		{"Tomorrow", now, time.UTC, day(2024, 11, 21)},
+		{"  day   after tomorrow ", now, time.UTC, day(2024, 11, 22)},
+		{"yesterday", now, time.UTC, day(2024, 11, 19)},
+		{"2024-12-01", now, time.UTC, day(2024, 12, 1)},
+
+		// Weekdays
+		{"friday", now, time.UTC, day(2024, 11, 22)},
+		{"fri", now, time.UTC, day(2024, 11, 22)},
+		{"wednesday", now, time.UTC, day(2024, 11, 20)},
+		{"monday", now, time.UTC, day(2024, 11, 25)},
+		{"this friday", now, time.UTC, day(2024, 11, 22)},
+		{"this monday", now, time.UTC, day(2024, 11, 18)},
+		{"next friday", now, time.UTC, day(2024, 11, 29)},
+		{"next monday", now, time.UTC, day(2024, 11, 25)},
+		{"next sunday", now, time.UTC, day(2024, 12, 1)},
+		{"next thurs", now, time.UTC, day(2024, 11, 28)},
+
+		// Relative days
+		{"in 3 days", now, time.UTC, day(2024, 11, 23)},
+		{"in 1 day", now, time.UTC, day(2024, 11, 21)},
+		{"in a week", now, time.UTC, day(2024, 11, 27)},
+		{"in 2 weeks", now, time.UTC, day(2024, 12, 4)},
+		{"in 3 months", now, time.UTC, day(2025, 2, 20)},
+		{"in a year", now, time.UTC, day(2025, 11, 20)},
+		{"in 1 month", time.Date(2025, 1, 31, 12, 0, 0, 0, time.UTC), time.UTC, day(2025, 2, 28)},
+		{"in 1 month", time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC), time.UTC, day(2024, 2, 29)},
+		{"in 2 hours", now, time.UTC, timed(2024, 11, 20, 14, 0)},
+		{"in an hour", now, time.UTC, timed(2024, 11, 20, 13, 0)},
+		{"in 90 minutes", now, time.UTC, timed(2024, 11, 20, 13, 30)},
+
+		// Ends and starts of periods
+		{"end of day", now, time.UTC, day(2024, 11, 20)},
+		{"end of week", now, time.UTC, day(2024, 11, 24)},
+		{"end of month", now, time.UTC, day(2024, 11, 30)},
+		{"end of the month", time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), time.UTC, day(2024, 2, 29)},
+		{"eom", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), time.UTC, day(2024, 12, 31)},
+		{"end of year", now, time.UTC, day(2024, 12, 31)},
+		{"next week", now, time.UTC, day(2024, 11, 25)},
+		{"next month", now, time.UTC, day(2024, 12, 1)},
+		{"next month", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), time.UTC, day(2025, 1, 1)},
+		{"next year", now, time.UTC, day(2025, 1, 1)},
+
+		// Month names
+		{"dec 1", now, time.UTC, day(2024, 12, 1)},
+		{"December 1st", now, time.UTC, day(2024, 12, 1)},
+		{"1 dec", now, time.UTC, day(2024, 12, 1)},
+		{"3rd march", now, time.UTC, day(2025, 3, 3)},
+		{"nov 20", now, time.UTC, day(2024, 11, 20)},
+		{"nov 19", now, time.UTC, day(2025, 11, 19)},
+		{"march 3, 2026", now, time.UTC, day(2026, 3, 3)},
+		{"sept 9 2023", now, time.UTC, day(2023, 9, 9)},
+
+		// Times of day
+		{"tomorrow 5pm", now, time.UTC, timed(2024, 11, 21, 17, 0)},
+		{"tomorrow at 5 pm", now, time.UTC, timed(2024, 11, 21, 17, 0)},
+		{"friday 9:30am", now, time.UTC, timed(2024, 11, 22, 9, 30)},
+		{"next monday at 17:45", now, time.UTC, timed(2024, 11, 25, 17, 45)},
+		{"in 3 days noon", now, time.UTC, timed(2024, 11, 23, 12, 0)},
+		{"dec 24 6pm", now, time.UTC, timed(2024, 12, 24, 18, 0)},
+		{"2024-12-01 08:00", now, time.UTC, timed(2024, 12, 1, 8, 0)},
+		{"today 12am", now, time.UTC, timed(2024, 11, 20, 0, 0)},
+		{"today 12pm", now, time.UTC, timed(2024, 11, 20, 12, 0)},
+		{"5pm", now, time.UTC, timed(2024, 11, 20, 17, 0)},
+		{"at 9am", now, time.UTC, timed(2024, 11, 21, 9, 0)},
+		{"noon", now, time.UTC, timed(2024, 11, 21, 12, 0)},
+		{"midnight", now, time.UTC, timed(2024, 11, 21, 0, 0)},
+
+		// Days are the caller's, it is already Wednesday evening in Tokyo
+		{"tomorrow", now, tokyo, Date{Time: time.Date(2024, 11, 21, 0, 0, 0, 0, tokyo), AllDay: true}},
+		{"tomorrow 5pm", now, tokyo, Date{Time: time.Date(2024, 11, 21, 17, 0, 0, 0, tokyo)}},
+		{"9pm", now, tokyo, Date{Time: time.Date(2024, 11, 21, 21, 0, 0, 0, tokyo)}},
+		{"end of month", time.Date(2024, 11, 30, 16, 0, 0, 0, time.UTC), tokyo,
+			Date{Time: time.Date(2024, 12, 31, 0, 0, 0, 0, tokyo), AllDay: true}},
+		// Across the end of daylight saving time
+		{"in a week 9am", time.Date(2024, 10, 25, 12, 0, 0, 0, time.UTC), berlin,
+			Date{Time: time.Date(2024, 11, 1, 9, 0, 0, 0, berlin)}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.phrase, tt.now, tt.loc)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.phrase, err)
+			continue
+		}
+		if !got.Time.Equal(tt.want.Time) || got.AllDay != tt.want.AllDay {
+			t.Errorf("Parse(%q) = %v (all day %t), want %v (all day %t)",
+				tt.phrase, got.Time, got.AllDay, tt.want.Time, tt.want.AllDay)
+		}
+		if got.Time.Location() != tt.loc {
+			t.Errorf("Parse(%q) is in %v, want %v", tt.phrase, got.Time.Location(), tt.loc)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	for _, phrase := range []string{
+		"",
+		"someday",
+		"next",
+		"last friday",
+		"friday friday",
+		"in days",
+		"in -3 days",
+		"in 2 hours 5pm",
+		"13pm",
+		"0am",
+		"tomorrow 24:00",
+		"tomorrow 9:75",
+		"feb 30",
+		"february 29 2025",
+		"31 smarch",
+		"2024-13-01",
+		"2024-11-20T17:00:00Z",
+		// Counts are capped, so results stay renderable and nothing overflows
+		"in 1000 days",
+		"in 10000 years",
+		"in 99999999999999999999 minutes",
+		"in 9223372036854775807 hours",
+	} {
+		if got, err := Parse(phrase, now, time.UTC); !errors.Is(err, ErrUnrecognized) {
+			t.Errorf("Parse(%q) = %v, %v, want ErrUnrecognized", phrase, got, err)
+		}
+	}
+
+	// Nothing past year 9999, which JSON cannot render
+	late := time.Date(9999, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, phrase := range []string{"in 999 years", "in 7 months", "next year", "in 999 weeks"} {
+		if got, err := Parse(phrase, late, time.UTC); !errors.Is(err, ErrUnrecognized) {
+			t.Errorf("Parse(%q) in 9999 = %v, %v, want ErrUnrecognized", phrase, got, err)
+		}
+	}
+	if got, err := Parse("in 999 years", now, time.UTC); err != nil || got.Time.Year() != 3023 {
+		t.Errorf("Expected in 999 years to be 3023, got %v %v", got, err)
+	}
+}
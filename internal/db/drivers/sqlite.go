@@ -1,6 +1,7 @@
 package drivers
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/jmoiron/sqlx"
@@ -37,3 +38,22 @@ func Down(db *sqlx.DB, path string) error {
 	var sqlDB *sql.DB = db.DB
 	return goose.Down(sqlDB, path)
 }
+
+// MigrationVersions returns the version the database is at and the latest
+// version available in path
+func MigrationVersions(ctx context.Context, db *sqlx.DB, path string) (current int64, latest int64, err error) {
+	goose.SetDialect("sqlite3")
+	current, err = goose.GetDBVersionContext(ctx, db.DB)
+	if err != nil {
+		return 0, 0, err
+	}
+	migrations, err := goose.CollectMigrations(path, 0, goose.MaxVersion)
+	if err != nil {
+		return 0, 0, err
+	}
+	last, err := migrations.Last()
+	if err != nil {
+		return 0, 0, err
+	}
+	return current, last.Version, nil
+}
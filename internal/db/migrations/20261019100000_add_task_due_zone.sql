@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE task ADD COLUMN due_all_day BOOLEAN DEFAULT 0;
+ALTER TABLE task ADD COLUMN due_tz TEXT DEFAULT 'UTC';
+ALTER TABLE task ADD COLUMN overdue_at DATETIME;
+-- Existing due dates are dates at midnight UTC, they become all-day tasks
+-- that are overdue once the day has ended
+UPDATE task SET
+    due_all_day = 1,
+    overdue_at = strftime('%Y-%m-%d %H:%M:%S+00:00', due_date, '+1 day')
+WHERE due_date IS NOT NULL;
+CREATE INDEX idx_task_overdue_at ON task (overdue, overdue_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_overdue_at;
+ALTER TABLE task DROP COLUMN overdue_at;
+ALTER TABLE task DROP COLUMN due_tz;
+ALTER TABLE task DROP COLUMN due_all_day;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE task ADD COLUMN reminders TEXT;
+-- Pending reminders, lead_time is in nanoseconds
+CREATE TABLE task_reminder (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    lead_time INTEGER NOT NULL,
+    remind_at DATETIME NOT NULL,
+    UNIQUE (task_id, lead_time)
+);
+CREATE INDEX idx_task_reminder_remind_at ON task_reminder (remind_at);
+-- Notification outbox, the unique key keeps a reminder from being written twice
+CREATE TABLE notification (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    due_date DATETIME,
+    lead_time INTEGER NOT NULL DEFAULT 0,
+    remind_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    UNIQUE (task_id, kind, lead_time, remind_at)
+);
+CREATE INDEX idx_notification_pending ON notification (sent_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE notification;
+DROP TABLE task_reminder;
+ALTER TABLE task DROP COLUMN reminders;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    email TEXT NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    digest_enabled BOOLEAN NOT NULL DEFAULT 0,
+    -- Local send time as HH:MM in time_zone
+    digest_time TEXT NOT NULL DEFAULT '08:00',
+    digest_sent_at DATETIME
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE user;
+-- +goose StatementEnd
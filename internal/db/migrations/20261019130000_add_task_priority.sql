@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Existing rows get priority none (0)
+ALTER TABLE task ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE task ADD COLUMN escalated BOOLEAN NOT NULL DEFAULT 0;
+CREATE INDEX idx_task_priority ON task (priority, due_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_priority;
+ALTER TABLE task DROP COLUMN escalated;
+ALTER TABLE task DROP COLUMN priority;
+-- +goose StatementEnd
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE project (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    key TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL
+);
+-- States in board order
+CREATE TABLE workflow_state (
+    project_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    position INTEGER NOT NULL,
+    initial BOOLEAN NOT NULL DEFAULT 0,
+    terminal BOOLEAN NOT NULL DEFAULT 0,
+    PRIMARY KEY (project_id, name)
+);
+CREATE TABLE workflow_transition (
+    project_id INTEGER NOT NULL,
+    from_state TEXT NOT NULL,
+    to_state TEXT NOT NULL,
+    PRIMARY KEY (project_id, from_state, to_state)
+);
+
+INSERT INTO project(id, key, name) VALUES (1, 'default', 'Default');
+INSERT INTO workflow_state(project_id, name, position, initial, terminal) VALUES
+    (1, 'backlog', 0, 1, 0),
+    (1, 'todo', 1, 0, 0),
+    (1, 'in_progress', 2, 0, 0),
+    (1, 'review', 3, 0, 0),
+    (1, 'done', 4, 0, 1);
+INSERT INTO workflow_transition(project_id, from_state, to_state) VALUES
+    (1, 'backlog', 'todo'),
+    (1, 'todo', 'backlog'),
+    (1, 'todo', 'in_progress'),
+    (1, 'in_progress', 'todo'),
+    (1, 'in_progress', 'review'),
+    (1, 'review', 'in_progress'),
+    (1, 'review', 'done'),
+    (1, 'done', 'todo');
+
+-- Existing tasks join the default project, completed ones are done and
+-- open ones are todo
+ALTER TABLE task ADD COLUMN project_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE task ADD COLUMN status TEXT NOT NULL DEFAULT 'backlog';
+UPDATE task SET status = CASE WHEN completed THEN 'done' ELSE 'todo' END;
+CREATE INDEX idx_task_project_status ON task (project_id, status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_project_status;
+ALTER TABLE task DROP COLUMN status;
+ALTER TABLE task DROP COLUMN project_id;
+DROP TABLE workflow_transition;
+DROP TABLE workflow_state;
+DROP TABLE project;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Manual order within a board column, see internal/rank
+ALTER TABLE task ADD COLUMN position TEXT;
+-- Existing tasks keep their id order, keys end in 1 so there is room
+-- before each of them
+UPDATE task SET position = ranked.position
+FROM (
+    SELECT id, printf('%07d1', row_number() OVER (PARTITION BY project_id, status ORDER BY id)) AS position
+    FROM task
+) AS ranked
+WHERE task.id = ranked.id;
+CREATE UNIQUE INDEX idx_task_position ON task (project_id, status, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_position;
+ALTER TABLE task DROP COLUMN position;
+-- +goose StatementEnd
@@ -0,0 +1,69 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Projects without members are open to every user
+CREATE TABLE project_member (
+    project_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (project_id, user_id)
+);
+CREATE TABLE task_assignee (
+    task_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    assigned_at DATETIME NOT NULL,
+    PRIMARY KEY (task_id, user_id)
+);
+CREATE INDEX idx_task_assignee_user ON task_assignee (user_id);
+
+-- Notifications name the user they are about, 0 for none, which becomes
+-- part of the unique key so assigning two users at once writes both
+CREATE TABLE notification_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    due_date DATETIME,
+    lead_time INTEGER NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    user_name TEXT,
+    remind_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    UNIQUE (task_id, kind, user_id, lead_time, remind_at)
+);
+INSERT INTO notification_new(id, task_id, kind, title, due_date, lead_time, remind_at, created_at, sent_at, attempts,
+    last_error)
+SELECT id, task_id, kind, title, due_date, lead_time, remind_at, created_at, sent_at, attempts, last_error
+FROM notification;
+DROP TABLE notification;
+ALTER TABLE notification_new RENAME TO notification;
+CREATE INDEX idx_notification_pending ON notification (sent_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE notification_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    title TEXT NOT NULL,
+    due_date DATETIME,
+    lead_time INTEGER NOT NULL DEFAULT 0,
+    remind_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    sent_at DATETIME,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    UNIQUE (task_id, kind, lead_time, remind_at)
+);
+INSERT INTO notification_old(id, task_id, kind, title, due_date, lead_time, remind_at, created_at, sent_at, attempts,
+    last_error)
+SELECT id, task_id, kind, title, due_date, lead_time, remind_at, created_at, sent_at, attempts, last_error
+FROM notification WHERE kind != 'assignment';
+DROP TABLE notification;
+ALTER TABLE notification_old RENAME TO notification;
+CREATE INDEX idx_notification_pending ON notification (sent_at, id);
+DROP TABLE task_assignee;
+DROP TABLE project_member;
+-- +goose StatementEnd
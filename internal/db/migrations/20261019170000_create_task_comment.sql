@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- body is the Markdown the author wrote, body_html its sanitized rendering
+CREATE TABLE task_comment (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    author_id INTEGER NOT NULL,
+    body TEXT NOT NULL,
+    body_html TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    edited_at DATETIME
+);
+CREATE INDEX idx_task_comment_task ON task_comment (task_id, id);
+-- Users mentioned with @username in a comment
+CREATE TABLE comment_mention (
+    comment_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    PRIMARY KEY (comment_id, user_id)
+);
+CREATE INDEX idx_comment_mention_user ON comment_mention (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE comment_mention;
+DROP TABLE task_comment;
+-- +goose StatementEnd
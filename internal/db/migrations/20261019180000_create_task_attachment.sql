@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The content lives in the blob store under its sha256, attachments with
+-- the same content share one blob
+CREATE TABLE task_attachment (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    mime_type TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX idx_task_attachment_task ON task_attachment (task_id, id);
+CREATE INDEX idx_task_attachment_sha256 ON task_attachment (sha256);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE task_attachment;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Entries without ended_at are running timers, duration is in seconds and
+-- set once the entry has ended
+CREATE TABLE time_entry (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME,
+    duration INTEGER,
+    note TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX idx_time_entry_task ON time_entry (task_id, started_at);
+CREATE INDEX idx_time_entry_started ON time_entry (started_at);
+-- A user runs at most one timer
+CREATE UNIQUE INDEX idx_time_entry_running ON time_entry (user_id) WHERE ended_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE time_entry;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Dates are YYYY-MM-DD days in time_zone, both included
+CREATE TABLE sprint (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    start_date TEXT NOT NULL,
+    end_date TEXT NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT 'UTC'
+);
+CREATE INDEX idx_sprint_project ON sprint (project_id, start_date);
+ALTER TABLE task ADD COLUMN estimate_points INTEGER;
+ALTER TABLE task ADD COLUMN estimate_minutes INTEGER;
+ALTER TABLE task ADD COLUMN sprint_id INTEGER;
+-- Tasks completed before this column existed keep it empty
+ALTER TABLE task ADD COLUMN completed_at DATETIME;
+CREATE INDEX idx_task_sprint ON task (sprint_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_sprint;
+ALTER TABLE task DROP COLUMN completed_at;
+ALTER TABLE task DROP COLUMN sprint_id;
+ALTER TABLE task DROP COLUMN estimate_minutes;
+ALTER TABLE task DROP COLUMN estimate_points;
+DROP TABLE sprint;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Tasks created before this column existed keep it empty
+ALTER TABLE task ADD COLUMN created_at DATETIME;
+CREATE INDEX idx_task_created_at ON task (created_at);
+CREATE INDEX idx_task_completed_at ON task (completed_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_completed_at;
+DROP INDEX IF EXISTS idx_task_created_at;
+ALTER TABLE task DROP COLUMN created_at;
+-- +goose StatementEnd
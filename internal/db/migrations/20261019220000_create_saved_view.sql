@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- query is task query language source, compiled each time the view runs
+CREATE TABLE saved_view (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    query TEXT NOT NULL,
+    created_by INTEGER,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE saved_view;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Databases migrated before this index had its own migration already have it
+CREATE INDEX IF NOT EXISTS idx_task_due_date ON task (completed, due_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_task_due_date;
+-- +goose StatementEnd
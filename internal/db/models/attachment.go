@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Attachment is the metadata of a file attached to a task
+type Attachment struct {
+	ID     int    `json:"id" db:"id"`
+	TaskID int    `json:"task_id" db:"task_id"`
+	Name   string `json:"name" db:"name"`
+	// Size in bytes
+	Size int64 `json:"size" db:"size"`
+	// Sniffed from the content, not taken from the client
+	MimeType string `json:"mime_type" db:"mime_type"`
+	// Hex SHA-256 of the content, also its blob store key
+	SHA256    string    `json:"sha256" db:"sha256"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+// Board shows the tasks of a project in one column per workflow state
+type Board struct {
+	ProjectID int      `json:"project_id"`
+	Columns   []Column `json:"columns"`
+}
+
+// Column holds the tasks in one workflow state, in manual order
+type Column struct {
+	Status   string `json:"status"`
+	Terminal bool   `json:"terminal"`
+	Tasks    []Task `json:"tasks"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+type Comment struct {
+	ID         int    `json:"id" db:"id"`
+	TaskID     int    `json:"task_id" db:"task_id"`
+	AuthorID   int    `json:"author_id" db:"author_id"`
+	AuthorName string `json:"author_name" db:"author_name"`
+	// Markdown as written and its sanitized HTML rendering
+	Body     string `json:"body" db:"body"`
+	BodyHTML string `json:"body_html" db:"body_html"`
+	// Usernames of the mentioned users that exist
+	Mentions  []string   `json:"mentions" db:"-"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	EditedAt  *time.Time `json:"edited_at" db:"edited_at"`
+}
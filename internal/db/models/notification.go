@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// Reminder is a pending reminder, it is removed once it has been written to
+// the notification outbox
+type Reminder struct {
+	ID       *int          `json:"id" db:"id"`
+	TaskID   int           `json:"task_id" db:"task_id"`
+	LeadTime time.Duration `json:"lead_time" db:"lead_time"`
+	RemindAt time.Time     `json:"remind_at" db:"remind_at"`
+}
+
+// Notification kinds
+const (
+	NotificationReminder   = "reminder"
+	NotificationAssignment = "assignment"
+)
+
+// Notification is an outbox entry, delivered by a notifier
+type Notification struct {
+	ID       int           `json:"id" db:"id"`
+	TaskID   int           `json:"task_id" db:"task_id"`
+	Kind     string        `json:"kind" db:"kind"`
+	Title    string        `json:"title" db:"title"`
+	DueDate  *time.Time    `json:"due_date" db:"due_date"`
+	LeadTime time.Duration `json:"-" db:"lead_time"`
+	// The user an assignment was made to
+	UserID    int        `json:"user_id,omitempty" db:"user_id"`
+	UserName  *string    `json:"user_name,omitempty" db:"user_name"`
+	RemindAt  time.Time  `json:"remind_at" db:"remind_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	SentAt    *time.Time `json:"sent_at" db:"sent_at"`
+	Attempts  int        `json:"attempts" db:"attempts"`
+	LastError *string    `json:"last_error" db:"last_error"`
+}
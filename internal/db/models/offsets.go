@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Offsets are reminder lead times before a due date. They are stored as a
+// comma separated list of durations, an empty list disables reminders.
+type Offsets []time.Duration
+
+// ParseOffsets parses durations such as 24h or 90m
+func ParseOffsets(values []string) (Offsets, error) {
+	offsets := Offsets{}
+	for _, value := range values {
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("offset %s must be positive", value)
+		}
+		offsets = append(offsets, d)
+	}
+	return offsets, nil
+}
+
+func (o Offsets) Strings() []string {
+	values := make([]string, len(o))
+	for i, d := range o {
+		values[i] = FormatDuration(d)
+	}
+	return values
+}
+
+func (o Offsets) Value() (driver.Value, error) {
+	return strings.Join(o.Strings(), ","), nil
+}
+
+func (o *Offsets) Scan(src interface{}) error {
+	var value string
+	switch src := src.(type) {
+	case string:
+		value = src
+	case []byte:
+		value = string(src)
+	default:
+		return fmt.Errorf("cannot scan %T into offsets", src)
+	}
+	if value == "" {
+		*o = Offsets{}
+		return nil
+	}
+	offsets, err := ParseOffsets(strings.Split(value, ","))
+	if err != nil {
+		return err
+	}
+	*o = offsets
+	return nil
+}
+
+func (o Offsets) MarshalJSON() ([]byte, error) {
+	return json.Marshal(o.Strings())
+}
+
+// FormatDuration formats d without zero trailing units, 24h0m0s is 24h
+func FormatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultProjectID is the project tasks belong to unless they say otherwise
+const DefaultProjectID = 1
+
+var (
+	ErrUnknownStatus     = errors.New("status is not a state of the project's workflow")
+	ErrIllegalTransition = errors.New("workflow does not allow this transition")
+)
+
+type Project struct {
+	ID       *int      `json:"id" db:"id"`
+	Key      *string   `json:"key" db:"key"`
+	Name     *string   `json:"name" db:"name"`
+	Workflow *Workflow `json:"workflow,omitempty" db:"-"`
+}
+
+// WorkflowState is a task status. New tasks start in the initial state and
+// tasks in a terminal state are completed.
+type WorkflowState struct {
+	Name     string `json:"name" db:"name"`
+	Initial  bool   `json:"initial" db:"initial"`
+	Terminal bool   `json:"terminal" db:"terminal"`
+}
+
+type Transition struct {
+	From string `json:"from" db:"from_state"`
+	To   string `json:"to" db:"to_state"`
+}
+
+// Workflow lists the states of a project in board order and the moves
+// allowed between them
+type Workflow struct {
+	States      []WorkflowState `json:"states"`
+	Transitions []Transition    `json:"transitions"`
+}
+
+// DefaultWorkflow is backlog, todo, in_progress, review, done
+func DefaultWorkflow() Workflow {
+	return Workflow{
+		States: []WorkflowState{
+			{Name: "backlog", Initial: true},
+			{Name: "todo"},
+			{Name: "in_progress"},
+			{Name: "review"},
+			{Name: "done", Terminal: true},
+		},
+		Transitions: []Transition{
+			{"backlog", "todo"},
+			{"todo", "backlog"},
+			{"todo", "in_progress"},
+			{"in_progress", "todo"},
+			{"in_progress", "review"},
+			{"review", "in_progress"},
+			{"review", "done"},
+			{"done", "todo"},
+		},
+	}
+}
+
+// State looks up a state by name
+func (w Workflow) State(name string) (WorkflowState, bool) {
+	for _, state := range w.States {
+		if state.Name == name {
+			return state, true
+		}
+	}
+	return WorkflowState{}, false
+}
+
+// Allows reports whether a task may move from one state to another
+func (w Workflow) Allows(from string, to string) bool {
+	for _, transition := range w.Transitions {
+		if transition.From == from && transition.To == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Check reports whether a task may move from one state to another, moving
+// to the state it is already in is always allowed
+func (w Workflow) Check(from string, to string) error {
+	if _, ok := w.State(to); !ok {
+		return ErrUnknownStatus
+	}
+	if from != to && !w.Allows(from, to) {
+		return ErrIllegalTransition
+	}
+	return nil
+}
+
+func (w Workflow) Initial() string {
+	for _, state := range w.States {
+		if state.Initial {
+			return state.Name
+		}
+	}
+	return ""
+}
+
+// Completion is the state a task moves to when it is marked completed
+func (w Workflow) Completion() string {
+	for _, state := range w.States {
+		if state.Terminal {
+			return state.Name
+		}
+	}
+	return ""
+}
+
+// Reopening is the state a completed task moves to when it is marked not
+// completed: the first open state it may move to, or the initial state
+func (w Workflow) Reopening(from string) string {
+	for _, state := range w.States {
+		if !state.Terminal && w.Allows(from, state.Name) {
+			return state.Name
+		}
+	}
+	return w.Initial()
+}
+
+// Validate checks that state names are unique, there is exactly one
+// initial state that is not terminal, at least one terminal state and that
+// transitions connect known states
+func (w Workflow) Validate() error {
+	if len(w.States) == 0 {
+		return errors.New("workflow needs at least one state")
+	}
+	seen := map[string]bool{}
+	initial, terminal := 0, 0
+	for _, state := range w.States {
+		if state.Name == "" || len(state.Name) > 50 {
+			return errors.New("state names must be 1 to 50 characters long")
+		}
+		if seen[state.Name] {
+			return fmt.Errorf("state %q is defined twice", state.Name)
+		}
+		seen[state.Name] = true
+		if state.Initial {
+			initial++
+			if state.Terminal {
+				return fmt.Errorf("initial state %q cannot be terminal", state.Name)
+			}
+		}
+		if state.Terminal {
+			terminal++
+		}
+	}
+	if initial != 1 {
+		return errors.New("workflow needs exactly one initial state")
+	}
+	if terminal == 0 {
+		return errors.New("workflow needs at least one terminal state")
+	}
+	for _, transition := range w.Transitions {
+		if !seen[transition.From] || !seen[transition.To] {
+			return fmt.Errorf("transition %s -> %s uses an unknown state", transition.From, transition.To)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package models
+
+// Sprint is a planning period of a project. StartDate and EndDate are
+// YYYY-MM-DD days in TimeZone, both included.
+type Sprint struct {
+	ID        int    `json:"id" db:"id"`
+	ProjectID int    `json:"project_id" db:"project_id"`
+	Name      string `json:"name" db:"name"`
+	StartDate string `json:"start_date" db:"start_date"`
+	EndDate   string `json:"end_date" db:"end_date"`
+	TimeZone  string `json:"time_zone" db:"time_zone"`
+}
+
+// Burndown is the story points of a sprint's tasks that were left and done
+// at the end of each sprint day up to today
+type Burndown struct {
+	SprintID    int `json:"sprint_id"`
+	TotalPoints int `json:"total_points"`
+	// Tasks of the sprint without a points estimate, they count as 0
+	Unestimated int           `json:"unestimated"`
+	Days        []BurndownDay `json:"days"`
+}
+
+type BurndownDay struct {
+	Date      string `json:"date"`
+	Remaining int    `json:"remaining"`
+	Completed int    `json:"completed"`
+	// Remaining points if the sprint burned down evenly to 0 on its last day
+	Ideal float64 `json:"ideal"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Stats series bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// Stats summarizes the tasks matching a filter. The counts describe the
+// tasks as they are now, the completion figures and the series cover a
+// date range.
+type Stats struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Bucket string `json:"bucket"`
+	// Tasks per workflow state
+	ByStatus map[string]int `json:"by_status"`
+	Total    int            `json:"total"`
+	Open     int            `json:"open"`
+	Overdue  int            `json:"overdue"`
+	// Share of open tasks that are overdue, nil without open tasks
+	OverduePercent *float64 `json:"overdue_percent"`
+	// Tasks completed in the range
+	Completed int `json:"completed"`
+	// Median time from creation to completion of the tasks completed in
+	// the range, nil when none of them has a creation time
+	MedianTimeToComplete *int64 `json:"median_seconds_to_complete"`
+	// Share of the tasks completed in the range with a due date that were
+	// completed before they became overdue, nil when none had a due date
+	OnTimePercent *float64      `json:"on_time_percent"`
+	Series        []StatsBucket `json:"series"`
+	// Stats are cached for a short while, this is when they were computed
+	GeneratedAt time.Time `json:"generated_at"`
+}
+
+// StatsBucket counts the tasks created and completed from Start to End,
+// both YYYY-MM-DD dates and included
+type StatsBucket struct {
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	Created   int    `json:"created"`
+	Completed int    `json:"completed"`
+}
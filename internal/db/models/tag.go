@@ -0,0 +1,30 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidTag = errors.New("tags are 1 to 50 lowercase letters, digits, - or _")
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// ParseTags lowercases tags, drops duplicates and sorts them
+func ParseTags(values []string) ([]string, error) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, value := range values {
+		tag := strings.ToLower(strings.TrimSpace(value))
+		if !tagPattern.MatchString(tag) {
+			return nil, ErrInvalidTag
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
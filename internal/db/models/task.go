@@ -6,9 +6,48 @@ import (
 
 type Task struct {
 	ID          *int       `json:"id" db:"id"`
+	ProjectID   *int       `json:"project_id" db:"project_id"`
 	Title       *string    `json:"title" db:"title"`
 	Description *string    `json:"description" db:"description"`
 	DueDate     *time.Time `json:"due_date" db:"due_date"`
-	Completed   *bool      `json:"completed" db:"completed"`
-	Overdue     *bool      `json:"overdue" db:"overdue"`
+	// All-day tasks are due on a date and become overdue when that day
+	// ends in TimeZone
+	DueAllDay *bool   `json:"due_all_day" db:"due_all_day"`
+	TimeZone  *string `json:"time_zone" db:"due_tz"`
+	// The instant the task becomes overdue, derived from the fields above
+	OverdueAt *time.Time `json:"overdue_at" db:"overdue_at"`
+	// Reminder lead times before the due date, nil uses the configured
+	// default
+	Reminders *Offsets  `json:"reminders" db:"reminders"`
+	Priority  *Priority `json:"priority" db:"priority"`
+	// Set when the overdue job raised the priority, cleared when the task
+	// is no longer overdue or is replaced
+	Escalated *bool `json:"escalated" db:"escalated"`
+	// A state of the project's workflow, tasks in a terminal state are
+	// completed
+	Status *string `json:"status" db:"status"`
+	// Ordering key within the task's board column
+	Position  *string `json:"position" db:"position"`
+	Completed *bool   `json:"completed" db:"completed"`
+	Overdue   *bool   `json:"overdue" db:"overdue"`
+	// When the task was created, nil for tasks older than this field
+	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+	// When the task last entered a terminal state, nil while it is open
+	CompletedAt *time.Time `json:"completed_at" db:"completed_at"`
+	// Effort in story points and in minutes, nil when not estimated
+	EstimatePoints  *int `json:"estimate_points" db:"estimate_points"`
+	EstimateMinutes *int `json:"estimate_minutes" db:"estimate_minutes"`
+	SprintID        *int `json:"sprint_id" db:"sprint_id"`
+	// Users responsible for the task, its tags, the length of its comment
+	// thread and the seconds of finished time entries, loaded separately
+	Assignees    []Assignee `json:"assignees" db:"-"`
+	Tags         []string   `json:"tags" db:"-"`
+	CommentCount int        `json:"comment_count" db:"-"`
+	TimeSpent    int64      `json:"time_spent_seconds" db:"-"`
+}
+
+// Assignee is a user a task is assigned to
+type Assignee struct {
+	ID   int    `json:"id" db:"user_id"`
+	Name string `json:"name" db:"name"`
 }
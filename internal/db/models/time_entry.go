@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// TimeEntry is time a user worked on a task, either tracked with a timer
+// or logged by hand
+type TimeEntry struct {
+	ID        int        `json:"id" db:"id"`
+	TaskID    int        `json:"task_id" db:"task_id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	StartedAt time.Time  `json:"started_at" db:"started_at"`
+	EndedAt   *time.Time `json:"ended_at" db:"ended_at"`
+	// Seconds between start and end, nil while the timer is running
+	Duration *int64 `json:"duration_seconds" db:"duration"`
+	Note     string `json:"note" db:"note"`
+}
+
+// Running reports whether the entry is a timer that has not been stopped
+func (e TimeEntry) Running() bool {
+	return e.EndedAt == nil
+}
+
+// Time report groupings
+const (
+	GroupByTask    = "task"
+	GroupByProject = "project"
+	GroupByDay     = "day"
+)
+
+// TimeReport sums the finished time entries that started in a date range
+type TimeReport struct {
+	From  string          `json:"from"`
+	To    string          `json:"to"`
+	Group string          `json:"group"`
+	Rows  []TimeReportRow `json:"rows"`
+	// Seconds of every row together
+	Total int64 `json:"total_seconds"`
+}
+
+// TimeReportRow is the time of one task, project or day. Key is the id of
+// the task or project, or the YYYY-MM-DD date.
+type TimeReportRow struct {
+	Key     string `json:"key"`
+	Name    string `json:"name"`
+	Seconds int64  `json:"seconds"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+type User struct {
+	ID       *int    `json:"id" db:"id"`
+	Username *string `json:"username" db:"username"`
+	Name     *string `json:"name" db:"name"`
+	// Only used for mailing, it is never returned
+	Email    *string `json:"-" db:"email"`
+	TimeZone *string `json:"time_zone" db:"time_zone"`
+	// Opted-in users get a daily digest at DigestTime, HH:MM in TimeZone
+	DigestEnabled *bool      `json:"digest_enabled" db:"digest_enabled"`
+	DigestTime    *string    `json:"digest_time" db:"digest_time"`
+	DigestSentAt  *time.Time `json:"digest_sent_at" db:"digest_sent_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SavedView is a named task query shared by everyone
+type SavedView struct {
+	ID    int    `json:"id" db:"id"`
+	Name  string `json:"name" db:"name"`
+	Query string `json:"query" db:"query"`
+	// The user who saved the view, nil when the caller was unknown
+	CreatedBy *int      `json:"created_by" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
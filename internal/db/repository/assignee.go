@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IAssigneeRepo interface {
+	Assign(ctx context.Context, taskID int, userID int, at time.Time) (bool, error)
+	Unassign(ctx context.Context, taskID int, userID int) (bool, error)
+}
+
+type AssigneeRepo struct {
+	db *sqlx.DB
+}
+
+func NewAssigneeRepo(db *sqlx.DB) IAssigneeRepo {
+	return &AssigneeRepo{db}
+}
+
+// Assign adds a user to the assignees of a task and writes an assignment
+// notification to the outbox in the same transaction. It reports false
+// when the user was already assigned.
+func (r *AssigneeRepo) Assign(ctx context.Context, taskID int, userID int, at time.Time) (bool, error) {
+	query := `INSERT OR IGNORE INTO task_assignee(task_id, user_id, assigned_at) VALUES($1, $2, $3)`
+	ctx, span := startSpan(ctx, "AssigneeRepo.Assign", query)
+	defer span.End()
+	at = at.UTC()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, taskID, userID, at)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	if added, err := res.RowsAffected(); err != nil || added == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+    INSERT INTO notification(task_id, kind, title, due_date, user_id, user_name, remind_at, created_at)
+    SELECT t.id, $1, t.title, t.due_date, u.id, u.name, $2, $2
+    FROM task t, user u
+    WHERE t.id = $3 AND u.id = $4`, models.NotificationAssignment, at, taskID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	return true, nil
+}
+
+// Unassign removes a user from the assignees of a task and reports false
+// when the user was not assigned
+func (r *AssigneeRepo) Unassign(ctx context.Context, taskID int, userID int) (bool, error) {
+	query := `DELETE FROM task_assignee WHERE task_id = $1 AND user_id = $2`
+	ctx, span := startSpan(ctx, "AssigneeRepo.Unassign", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, taskID, userID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	removed, err := res.RowsAffected()
+	return removed > 0, err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IAttachmentRepo interface {
+	Create(ctx context.Context, attachment *models.Attachment) error
+	GetByID(ctx context.Context, taskID int, id int) (*models.Attachment, error)
+	GetByTask(ctx context.Context, taskID int) ([]models.Attachment, error)
+	TotalSize(ctx context.Context, taskID int) (int64, error)
+	IsReferenced(ctx context.Context, sha256 string) (bool, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type AttachmentRepo struct {
+	db *sqlx.DB
+}
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+func NewAttachmentRepo(db *sqlx.DB) IAttachmentRepo {
+	return &AttachmentRepo{db}
+}
+
+func (r *AttachmentRepo) Create(ctx context.Context, attachment *models.Attachment) error {
+	query := `
+    INSERT INTO task_attachment(task_id, name, size, mime_type, sha256, created_at) VALUES($1, $2, $3, $4, $5, $6)
+    RETURNING *`
+	ctx, span := startSpan(ctx, "AttachmentRepo.Create", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, attachment, query, attachment.TaskID, attachment.Name, attachment.Size,
+		attachment.MimeType, attachment.SHA256, attachment.CreatedAt.UTC()); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *AttachmentRepo) GetByID(ctx context.Context, taskID int, id int) (*models.Attachment, error) {
+	attachment := models.Attachment{}
+	query := `SELECT * FROM task_attachment WHERE id = $1 AND task_id = $2`
+	ctx, span := startSpan(ctx, "AttachmentRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &attachment, query, id, taskID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAttachmentNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *AttachmentRepo) GetByTask(ctx context.Context, taskID int) ([]models.Attachment, error) {
+	attachments := []models.Attachment{}
+	query := `SELECT * FROM task_attachment WHERE task_id = $1 ORDER BY id`
+	ctx, span := startSpan(ctx, "AttachmentRepo.GetByTask", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &attachments, query, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// TotalSize sums the sizes of a task's attachments, shared blobs count
+// once per attachment
+func (r *AttachmentRepo) TotalSize(ctx context.Context, taskID int) (int64, error) {
+	var size int64
+	query := `SELECT COALESCE(SUM(size), 0) FROM task_attachment WHERE task_id = $1`
+	ctx, span := startSpan(ctx, "AttachmentRepo.TotalSize", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &size, query, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	return size, nil
+}
+
+// IsReferenced reports whether any attachment still uses the blob with the
+// given digest
+func (r *AttachmentRepo) IsReferenced(ctx context.Context, sha256 string) (bool, error) {
+	referenced := false
+	query := `SELECT EXISTS (SELECT 1 FROM task_attachment WHERE sha256 = $1)`
+	ctx, span := startSpan(ctx, "AttachmentRepo.IsReferenced", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &referenced, query, sha256); err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	return referenced, nil
+}
+
+func (r *AttachmentRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM task_attachment WHERE id = $1`
+	ctx, span := startSpan(ctx, "AttachmentRepo.Delete", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ICommentRepo interface {
+	Create(ctx context.Context, comment *models.Comment, mentions []string) error
+	Update(ctx context.Context, comment *models.Comment, mentions []string) error
+	GetByID(ctx context.Context, taskID int, id int) (*models.Comment, error)
+	GetPage(ctx context.Context, taskID int, after int, limit int) ([]models.Comment, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type CommentRepo struct {
+	db *sqlx.DB
+}
+
+var ErrCommentNotFound = errors.New("comment not found")
+
+func NewCommentRepo(db *sqlx.DB) ICommentRepo {
+	return &CommentRepo{db}
+}
+
+// Columns of a comment with its author's name
+const commentColumns = `c.id, c.task_id, c.author_id, u.name AS author_name, c.body, c.body_html, c.created_at,
+        c.edited_at`
+
+// Create stores a comment and the users it mentions, mentions of unknown
+// usernames are dropped
+func (r *CommentRepo) Create(ctx context.Context, comment *models.Comment, mentions []string) error {
+	query := `
+    INSERT INTO task_comment(task_id, author_id, body, body_html, created_at) VALUES($1, $2, $3, $4, $5)
+    RETURNING id`
+	ctx, span := startSpan(ctx, "CommentRepo.Create", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	id := 0
+	if err := tx.GetContext(ctx, &id, query, comment.TaskID, comment.AuthorID, comment.Body, comment.BodyHTML,
+		comment.CreatedAt.UTC()); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := setMentions(ctx, tx, id, mentions); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	created, err := r.GetByID(ctx, comment.TaskID, id)
+	if err != nil {
+		return err
+	}
+	*comment = *created
+	return nil
+}
+
+// Update replaces the body of a comment and the users it mentions
+func (r *CommentRepo) Update(ctx context.Context, comment *models.Comment, mentions []string) error {
+	query := `UPDATE task_comment SET body = $1, body_html = $2, edited_at = $3 WHERE id = $4 AND task_id = $5`
+	ctx, span := startSpan(ctx, "CommentRepo.Update", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, comment.Body, comment.BodyHTML, comment.EditedAt.UTC(), comment.ID,
+		comment.TaskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return ErrCommentNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comment_mention WHERE comment_id = $1`, comment.ID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := setMentions(ctx, tx, comment.ID, mentions); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	updated, err := r.GetByID(ctx, comment.TaskID, comment.ID)
+	if err != nil {
+		return err
+	}
+	*comment = *updated
+	return nil
+}
+
+// setMentions records the existing users among the mentioned usernames
+func setMentions(ctx context.Context, tx *sqlx.Tx, commentID int, mentions []string) error {
+	if len(mentions) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+    INSERT OR IGNORE INTO comment_mention(comment_id, user_id)
+    SELECT ?, id FROM user WHERE username IN (?)`, commentID, mentions)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, tx.Rebind(query), args...)
+	return err
+}
+
+func (r *CommentRepo) GetByID(ctx context.Context, taskID int, id int) (*models.Comment, error) {
+	comment := models.Comment{}
+	query := `SELECT ` + commentColumns + ` FROM task_comment c JOIN user u ON u.id = c.author_id
+    WHERE c.id = $1 AND c.task_id = $2`
+	ctx, span := startSpan(ctx, "CommentRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &comment, query, id, taskID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCommentNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	comments := []models.Comment{comment}
+	if err := r.loadMentions(ctx, comments); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &comments[0], nil
+}
+
+// GetPage returns up to limit comments of a task, oldest first, starting
+// after the comment with id after
+func (r *CommentRepo) GetPage(ctx context.Context, taskID int, after int, limit int) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	query := `SELECT ` + commentColumns + ` FROM task_comment c JOIN user u ON u.id = c.author_id
+    WHERE c.task_id = $1 AND c.id > $2
+    ORDER BY c.id LIMIT $3`
+	ctx, span := startSpan(ctx, "CommentRepo.GetPage", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &comments, query, taskID, after, limit); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if err := r.loadMentions(ctx, comments); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return comments, nil
+}
+
+// loadMentions fills in the usernames each comment mentions
+func (r *CommentRepo) loadMentions(ctx context.Context, comments []models.Comment) error {
+	byID := map[int]*models.Comment{}
+	ids := []int{}
+	for i := range comments {
+		comments[i].Mentions = []string{}
+		byID[comments[i].ID] = &comments[i]
+		ids = append(ids, comments[i].ID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In(`
+    SELECT m.comment_id, u.username FROM comment_mention m JOIN user u ON u.id = m.user_id
+    WHERE m.comment_id IN (?)
+    ORDER BY m.comment_id, u.username`, ids)
+	if err != nil {
+		return err
+	}
+	rows := []struct {
+		CommentID int    `db:"comment_id"`
+		Username  string `db:"username"`
+	}{}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		comment := byID[row.CommentID]
+		comment.Mentions = append(comment.Mentions, row.Username)
+	}
+	return nil
+}
+
+func (r *CommentRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM task_comment WHERE id = $1`
+	ctx, span := startSpan(ctx, "CommentRepo.Delete", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrCommentNotFound
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comment_mention WHERE comment_id = $1`, id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
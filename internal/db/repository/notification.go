@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type INotificationRepo interface {
+	GetPending(ctx context.Context, maxAttempts int, limit int) ([]models.Notification, error)
+	MarkSent(ctx context.Context, id int, sentAt time.Time) error
+	MarkFailed(ctx context.Context, id int, reason string) error
+}
+
+type NotificationRepo struct {
+	db *sqlx.DB
+}
+
+func NewNotificationRepo(db *sqlx.DB) INotificationRepo {
+	return &NotificationRepo{db}
+}
+
+// GetPending returns the oldest unsent notifications that have been tried
+// fewer than maxAttempts times
+func (r *NotificationRepo) GetPending(ctx context.Context, maxAttempts int, limit int) ([]models.Notification, error) {
+	notifications := []models.Notification{}
+	query := `SELECT * FROM notification WHERE sent_at IS NULL AND attempts < $1 ORDER BY id LIMIT $2`
+	ctx, span := startSpan(ctx, "NotificationRepo.GetPending", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &notifications, query, maxAttempts, limit); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *NotificationRepo) MarkSent(ctx context.Context, id int, sentAt time.Time) error {
+	query := `UPDATE notification SET sent_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2`
+	ctx, span := startSpan(ctx, "NotificationRepo.MarkSent", query)
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, query, sentAt.UTC(), id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *NotificationRepo) MarkFailed(ctx context.Context, id int, reason string) error {
+	query := `UPDATE notification SET attempts = attempts + 1, last_error = $1 WHERE id = $2`
+	ctx, span := startSpan(ctx, "NotificationRepo.MarkFailed", query)
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, query, reason, id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
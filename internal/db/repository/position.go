@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"todo-api/internal/db/models"
+	"todo-api/internal/rank"
+	"todo-api/internal/telemetry"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Attempts to place a task before giving up on a column that keeps
+// changing under it
+const maxPlaceAttempts = 5
+
+// endOfColumn sorts after every key
+const endOfColumn = "~"
+
+// Placement puts a task directly below the After card or directly above
+// the Before card of its column, at the end of the column when both are
+// nil. When both are set they must be adjacent.
+type Placement struct {
+	After  *int
+	Before *int
+}
+
+// card is a task's place in a board column
+type card struct {
+	ID       int    `db:"id"`
+	Position string `db:"position"`
+}
+
+// lastPosition returns the key of the last card in a column other than
+// the excluded task, or "" when there is none
+func (r *TaskRepo) lastPosition(ctx context.Context, projectID int, status string, exclude int) (string, error) {
+	last := ""
+	err := r.db.GetContext(ctx, &last, `
+    SELECT COALESCE(MAX(position), '') FROM task
+    WHERE project_id = $1 AND status = $2 AND id != $3`, projectID, status, exclude)
+	return last, err
+}
+
+// getCard looks up a card of a column
+func (r *TaskRepo) getCard(ctx context.Context, projectID int, status string, id int) (*card, error) {
+	c := &card{}
+	err := r.db.GetContext(ctx, c, `
+    SELECT id, position FROM task
+    WHERE id = $1 AND project_id = $2 AND status = $3 AND position IS NOT NULL`, id, projectID, status)
+	if err == sql.ErrNoRows {
+		return nil, ErrBadPlacement
+	}
+	return c, err
+}
+
+// neighbour returns the card next to position in a column, the one
+// below it when below is set and the one above it otherwise, or nil
+func (r *TaskRepo) neighbour(ctx context.Context, projectID int, status string, exclude int, position string,
+	below bool) (*card, error) {
+	query := `
+    SELECT id, position FROM task
+    WHERE project_id = $1 AND status = $2 AND id != $3 AND position < $4
+    ORDER BY position DESC LIMIT 1`
+	if below {
+		query = `
+    SELECT id, position FROM task
+    WHERE project_id = $1 AND status = $2 AND id != $3 AND position > $4
+    ORDER BY position LIMIT 1`
+	}
+	c := &card{}
+	err := r.db.GetContext(ctx, c, query, projectID, status, exclude, position)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// bounds finds the cards a task is placed between, nil stands for the
+// start or the end of the column
+func (r *TaskRepo) bounds(ctx context.Context, projectID int, status string, id int, at Placement) (*card, *card, error) {
+	if (at.After != nil && *at.After == id) || (at.Before != nil && *at.Before == id) {
+		return nil, nil, ErrBadPlacement
+	}
+	var upper, lower *card
+	var err error
+	if at.After != nil {
+		if lower, err = r.getCard(ctx, projectID, status, *at.After); err != nil {
+			return nil, nil, err
+		}
+	}
+	if at.Before != nil {
+		if upper, err = r.getCard(ctx, projectID, status, *at.Before); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch {
+	case lower != nil && upper != nil:
+		// A client that names both neighbours must see them adjacent
+		next, err := r.neighbour(ctx, projectID, status, id, lower.Position, true)
+		if err != nil {
+			return nil, nil, err
+		}
+		if next == nil || next.ID != upper.ID {
+			return nil, nil, ErrBadPlacement
+		}
+	case lower != nil:
+		upper, err = r.neighbour(ctx, projectID, status, id, lower.Position, true)
+	case upper != nil:
+		lower, err = r.neighbour(ctx, projectID, status, id, upper.Position, false)
+	default:
+		lower, err = r.neighbour(ctx, projectID, status, id, endOfColumn, false)
+	}
+	return lower, upper, err
+}
+
+// Move places a task in the task.Status column and sets task.Completed and
+// task.CompletedAt, provided it is still in the from state. The update only
+// goes through while the cards around the new place are where they were
+// read, so concurrent moves are retried instead of ending up out of order or on the
+// same position.
+func (r *TaskRepo) Move(ctx context.Context, task *models.Task, from string, at Placement) error {
+	query := `
+    UPDATE task SET status = $1, completed = $2, position = $3, completed_at = $4
+    WHERE id = $5 AND status = $6
+        AND ($7 IS NULL OR (SELECT position FROM task WHERE id = $7 AND project_id = $8 AND status = $1) = $9)
+        AND ($10 IS NULL OR (SELECT position FROM task WHERE id = $10 AND project_id = $8 AND status = $1) = $11)
+    RETURNING ` + taskColumns
+	ctx, span := startSpan(ctx, "TaskRepo.Move", query)
+	defer span.End()
+
+	current, err := r.GetByID(ctx, *task.ID)
+	if err != nil {
+		return err
+	}
+	for attempt := 0; attempt < maxPlaceAttempts; attempt++ {
+		lower, upper, err := r.bounds(ctx, *current.ProjectID, *task.Status, *task.ID, at)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+		var lowerID, upperID *int
+		lowerKey, upperKey := "", ""
+		if lower != nil {
+			lowerID, lowerKey = &lower.ID, lower.Position
+		}
+		if upper != nil {
+			upperID, upperKey = &upper.ID, upper.Position
+		}
+		position, err := rank.Between(lowerKey, upperKey)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+
+		row := r.db.QueryRowxContext(ctx, query, task.Status, task.Completed, position, utc(task.CompletedAt),
+			task.ID, from, lowerID, current.ProjectID, lowerKey, upperID, upperKey)
+		moved := models.Task{}
+		err = row.StructScan(&moved)
+		if err == nil {
+			*task = moved
+			return r.loadDetails(ctx, task)
+		}
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			telemetry.RecordError(span, err)
+			return err
+		}
+		// Either the task or one of its new neighbours moved
+		if current, err = r.GetByID(ctx, *task.ID); err != nil {
+			return err
+		}
+		if *current.Status != from {
+			return ErrStatusChanged
+		}
+	}
+	telemetry.RecordError(span, ErrMoveConflict)
+	return ErrMoveConflict
+}
+
+// Rebalance rewrites the positions of every column holding a key longer
+// than maxLength with short, evenly spaced keys and returns the number of
+// tasks it moved
+func (r *TaskRepo) Rebalance(ctx context.Context, maxLength int) (int, error) {
+	// The first statement writes so the transaction holds the write lock
+	// from the start; moving the keys past the end of the column keeps
+	// them unique and in order until they are replaced
+	query := `
+    UPDATE task SET position = '` + endOfColumn + `' || position
+    WHERE (project_id, status) IN (SELECT project_id, status FROM task WHERE length(position) > $1)
+        AND position IS NOT NULL
+    RETURNING id, project_id, status, position`
+	ctx, span := startSpan(ctx, "TaskRepo.Rebalance", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	type ranked struct {
+		card
+		ProjectID int    `db:"project_id"`
+		Status    string `db:"status"`
+	}
+	rows := []ranked{}
+	if err := tx.SelectContext(ctx, &rows, query, maxLength); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	columns := map[[2]interface{}][]card{}
+	for _, row := range rows {
+		column := [2]interface{}{row.ProjectID, row.Status}
+		columns[column] = append(columns[column], row.card)
+	}
+	for _, cards := range columns {
+		sort.Slice(cards, func(i, j int) bool { return cards[i].Position < cards[j].Position })
+		for i, key := range rank.Spread(len(cards)) {
+			if _, err := tx.ExecContext(ctx, `UPDATE task SET position = $1 WHERE id = $2`, key, cards[i].ID); err != nil {
+				telemetry.RecordError(span, err)
+				return 0, err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	return len(rows), nil
+}
@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+type IProjectRepo interface {
+	Create(ctx context.Context, project *models.Project) error
+	GetByID(ctx context.Context, id int) (*models.Project, error)
+	GetAll(ctx context.Context) ([]models.Project, error)
+	GetWorkflow(ctx context.Context, projectID int) (*models.Workflow, error)
+	AddMember(ctx context.Context, projectID int, userID int) error
+	RemoveMember(ctx context.Context, projectID int, userID int) error
+	GetMembers(ctx context.Context, projectID int) ([]models.User, error)
+	CanAssign(ctx context.Context, projectID int, userID int) (bool, error)
+}
+
+type ProjectRepo struct {
+	db *sqlx.DB
+}
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrProjectKeyTaken = errors.New("project key is already taken")
+)
+
+func NewProjectRepo(db *sqlx.DB) IProjectRepo {
+	return &ProjectRepo{db}
+}
+
+// Create stores a project together with its workflow
+func (r *ProjectRepo) Create(ctx context.Context, project *models.Project) error {
+	query := `INSERT INTO project(key, name) VALUES($1, $2) RETURNING *`
+	ctx, span := startSpan(ctx, "ProjectRepo.Create", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	workflow := project.Workflow
+	if err := tx.QueryRowxContext(ctx, query, project.Key, project.Name).StructScan(project); err != nil {
+		telemetry.RecordError(span, err)
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ErrProjectKeyTaken
+		}
+		return err
+	}
+	project.Workflow = workflow
+	for i, state := range workflow.States {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO workflow_state(project_id, name, position, initial, terminal) VALUES($1, $2, $3, $4, $5)`,
+			project.ID, state.Name, i, state.Initial, state.Terminal); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+	for _, transition := range workflow.Transitions {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO workflow_transition(project_id, from_state, to_state) VALUES($1, $2, $3)`,
+			project.ID, transition.From, transition.To); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// GetByID returns the project with its workflow
+func (r *ProjectRepo) GetByID(ctx context.Context, id int) (*models.Project, error) {
+	project := &models.Project{}
+	query := `SELECT * FROM project WHERE id = $1`
+	ctx, span := startSpan(ctx, "ProjectRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, project, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrProjectNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	workflow, err := r.GetWorkflow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	project.Workflow = workflow
+	return project, nil
+}
+
+func (r *ProjectRepo) GetAll(ctx context.Context) ([]models.Project, error) {
+	projects := []models.Project{}
+	query := `SELECT * FROM project ORDER BY id`
+	ctx, span := startSpan(ctx, "ProjectRepo.GetAll", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &projects, query); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return projects, nil
+}
+
+func (r *ProjectRepo) GetWorkflow(ctx context.Context, projectID int) (*models.Workflow, error) {
+	query := `SELECT name, initial, terminal FROM workflow_state WHERE project_id = $1 ORDER BY position`
+	ctx, span := startSpan(ctx, "ProjectRepo.GetWorkflow", query)
+	defer span.End()
+	workflow := &models.Workflow{States: []models.WorkflowState{}, Transitions: []models.Transition{}}
+	if err := r.db.SelectContext(ctx, &workflow.States, query, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if len(workflow.States) == 0 {
+		return nil, ErrProjectNotFound
+	}
+	if err := r.db.SelectContext(ctx, &workflow.Transitions,
+		`SELECT from_state, to_state FROM workflow_transition WHERE project_id = $1 ORDER BY rowid`, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return workflow, nil
+}
+
+func (r *ProjectRepo) AddMember(ctx context.Context, projectID int, userID int) error {
+	query := `INSERT OR IGNORE INTO project_member(project_id, user_id) VALUES($1, $2)`
+	ctx, span := startSpan(ctx, "ProjectRepo.AddMember", query)
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, query, projectID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// RemoveMember takes a user out of a project and unassigns them from the
+// project's tasks
+func (r *ProjectRepo) RemoveMember(ctx context.Context, projectID int, userID int) error {
+	query := `DELETE FROM project_member WHERE project_id = $1 AND user_id = $2`
+	ctx, span := startSpan(ctx, "ProjectRepo.RemoveMember", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, projectID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM task_assignee WHERE task_id IN (SELECT id FROM task WHERE project_id = $1) AND user_id = $2`,
+		projectID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *ProjectRepo) GetMembers(ctx context.Context, projectID int) ([]models.User, error) {
+	users := []models.User{}
+	query := `SELECT u.* FROM user u JOIN project_member m ON m.user_id = u.id WHERE m.project_id = $1 ORDER BY u.id`
+	ctx, span := startSpan(ctx, "ProjectRepo.GetMembers", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &users, query, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// CanAssign reports whether tasks of a project may be assigned to a user:
+// projects without members accept every user, the others only members
+func (r *ProjectRepo) CanAssign(ctx context.Context, projectID int, userID int) (bool, error) {
+	allowed := false
+	query := `
+    SELECT NOT EXISTS (SELECT 1 FROM project_member WHERE project_id = $1)
+        OR EXISTS (SELECT 1 FROM project_member WHERE project_id = $1 AND user_id = $2)`
+	ctx, span := startSpan(ctx, "ProjectRepo.CanAssign", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &allowed, query, projectID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	return allowed, nil
+}
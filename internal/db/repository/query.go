@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"todo-api/internal/db/models"
+	"todo-api/internal/query"
+)
+
+// TaskQuery filters and orders task lists
+type TaskQuery struct {
+	// Only tasks of this project, nil matches every project
+	ProjectID *int
+	// Only tasks in one of these states, empty matches every task
+	Statuses []string
+	// Only tasks with one of these priorities, empty matches every task
+	Priorities []models.Priority
+	// Only tasks assigned to this user
+	Assignee *int
+	// Only tasks without assignees
+	Unassigned bool
+	// Only tasks of this sprint
+	SprintID *int
+	// Only tasks matching a compiled task query, its sort order is not
+	// applied here
+	Filter *query.Filter
+	// Empty uses the default order: overdue first, then by priority, then
+	// by due date
+	Sort []SortKey
+}
+
+// SortKey orders a task list by one column
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+const defaultOrder = "overdue DESC, priority DESC, due_date IS NULL, due_date, id"
+
+// Columns a task list can be sorted by
+var sortColumns = map[string]bool{
+	"id":       true,
+	"title":    true,
+	"due_date": true,
+	"priority": true,
+	"overdue":  true,
+	"position": true,
+}
+
+// ParseSort parses a comma separated list of columns, a leading - sorts
+// that column descending, e.g. -priority,due_date
+func ParseSort(value string) ([]SortKey, error) {
+	keys := []SortKey{}
+	for _, field := range strings.Split(value, ",") {
+		key := SortKey{Column: strings.TrimSpace(field)}
+		if strings.HasPrefix(key.Column, "-") {
+			key.Column, key.Desc = key.Column[1:], true
+		}
+		if !sortColumns[key.Column] {
+			return nil, fmt.Errorf("cannot sort by %q", key.Column)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (q TaskQuery) where() (string, []interface{}) {
+	conditions, args := q.conditions()
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// conditions returns the filters of the query as SQL conditions on the
+// task table and their arguments
+func (q TaskQuery) conditions() ([]string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+	if q.ProjectID != nil {
+		conditions = append(conditions, "project_id = ?")
+		args = append(args, *q.ProjectID)
+	}
+	if len(q.Statuses) > 0 {
+		conditions = append(conditions, "status IN ("+placeholders(len(q.Statuses))+")")
+		for _, status := range q.Statuses {
+			args = append(args, status)
+		}
+	}
+	if len(q.Priorities) > 0 {
+		conditions = append(conditions, "priority IN ("+placeholders(len(q.Priorities))+")")
+		for _, priority := range q.Priorities {
+			args = append(args, priority)
+		}
+	}
+	if q.Assignee != nil {
+		conditions = append(conditions, "id IN (SELECT task_id FROM task_assignee WHERE user_id = ?)")
+		args = append(args, *q.Assignee)
+	}
+	if q.Unassigned {
+		conditions = append(conditions, "NOT EXISTS (SELECT 1 FROM task_assignee WHERE task_id = task.id)")
+	}
+	if q.SprintID != nil {
+		conditions = append(conditions, "sprint_id = ?")
+		args = append(args, *q.SprintID)
+	}
+	if q.Filter != nil && q.Filter.Where != "" {
+		conditions = append(conditions, "("+q.Filter.Where+")")
+		args = append(args, q.Filter.Args...)
+	}
+	return conditions, args
+}
+
+func (q TaskQuery) orderBy() string {
+	if len(q.Sort) == 0 {
+		return defaultOrder
+	}
+	terms := []string{}
+	for _, key := range q.Sort {
+		if !sortColumns[key.Column] {
+			continue
+		}
+		direction := ""
+		if key.Desc {
+			direction = " DESC"
+		}
+		// Tasks without a due date or board position go last either way
+		if key.Column == "due_date" || key.Column == "position" {
+			terms = append(terms, key.Column+" IS NULL")
+		}
+		terms = append(terms, key.Column+direction)
+	}
+	return strings.Join(append(terms, "id"), ", ")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IReminderRepo interface {
+	Arm(ctx context.Context, taskID int, reminders []models.Reminder) error
+	Cancel(ctx context.Context, taskID int) error
+	GetByTask(ctx context.Context, taskID int) ([]models.Reminder, error)
+	Enqueue(ctx context.Context, now time.Time) (int, error)
+}
+
+type ReminderRepo struct {
+	db *sqlx.DB
+}
+
+func NewReminderRepo(db *sqlx.DB) IReminderRepo {
+	return &ReminderRepo{db}
+}
+
+// Arm replaces the pending reminders of a task
+func (r *ReminderRepo) Arm(ctx context.Context, taskID int, reminders []models.Reminder) error {
+	query := `INSERT INTO task_reminder(task_id, lead_time, remind_at) VALUES($1, $2, $3)`
+	ctx, span := startSpan(ctx, "ReminderRepo.Arm", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_reminder WHERE task_id = $1`, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	for _, reminder := range reminders {
+		if _, err := tx.ExecContext(ctx, query, taskID, reminder.LeadTime, reminder.RemindAt.UTC()); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// Cancel removes the pending reminders of a task
+func (r *ReminderRepo) Cancel(ctx context.Context, taskID int) error {
+	query := `DELETE FROM task_reminder WHERE task_id = $1`
+	ctx, span := startSpan(ctx, "ReminderRepo.Cancel", query)
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, query, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *ReminderRepo) GetByTask(ctx context.Context, taskID int) ([]models.Reminder, error) {
+	reminders := []models.Reminder{}
+	query := `SELECT * FROM task_reminder WHERE task_id = $1 ORDER BY remind_at`
+	ctx, span := startSpan(ctx, "ReminderRepo.GetByTask", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &reminders, query, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return reminders, nil
+}
+
+// Enqueue moves every reminder due at now into the notification outbox and
+// returns how many notifications were written. Both happen in one
+// transaction and the outbox key ignores duplicates, so a reminder is
+// written exactly once.
+func (r *ReminderRepo) Enqueue(ctx context.Context, now time.Time) (int, error) {
+	query := `
+    INSERT INTO notification(task_id, kind, title, due_date, lead_time, remind_at, created_at)
+    SELECT r.task_id, $1, t.title, t.due_date, r.lead_time, r.remind_at, $2
+    FROM task_reminder r JOIN task t ON t.id = r.task_id
+    WHERE r.remind_at <= $2 AND t.completed = false
+    ON CONFLICT (task_id, kind, user_id, lead_time, remind_at) DO NOTHING
+    `
+	ctx, span := startSpan(ctx, "ReminderRepo.Enqueue", query)
+	defer span.End()
+	now = now.UTC()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query, models.NotificationReminder, now)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	enqueued, err := res.RowsAffected()
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM task_reminder WHERE remind_at <= $1`, now); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	return int(enqueued), nil
+}
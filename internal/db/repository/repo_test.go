@@ -176,7 +176,7 @@ func TestUpdateError(t *testing.T) {
 
 func TestGetAll(t *testing.T) {
 	// Get all tasks
-	tasks, err := r.GetAll(context.TODO())
+	tasks, err := r.GetAll(context.TODO(), TaskQuery{})
 	if err != nil {
 		t.Errorf("Error getting tasks: %v", err)
 	}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type ISprintRepo interface {
+	Create(ctx context.Context, sprint *models.Sprint) error
+	GetByID(ctx context.Context, id int) (*models.Sprint, error)
+	GetAll(ctx context.Context, projectID *int) ([]models.Sprint, error)
+	SetTaskSprint(ctx context.Context, taskID int, sprintID *int) error
+}
+
+type SprintRepo struct {
+	db *sqlx.DB
+}
+
+var ErrSprintNotFound = errors.New("sprint not found")
+
+func NewSprintRepo(db *sqlx.DB) ISprintRepo {
+	return &SprintRepo{db}
+}
+
+func (r *SprintRepo) Create(ctx context.Context, sprint *models.Sprint) error {
+	query := `
+    INSERT INTO sprint(project_id, name, start_date, end_date, time_zone) VALUES($1, $2, $3, $4, $5)
+    RETURNING *`
+	ctx, span := startSpan(ctx, "SprintRepo.Create", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, sprint, query, sprint.ProjectID, sprint.Name, sprint.StartDate, sprint.EndDate,
+		sprint.TimeZone); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *SprintRepo) GetByID(ctx context.Context, id int) (*models.Sprint, error) {
+	sprint := models.Sprint{}
+	query := `SELECT * FROM sprint WHERE id = $1`
+	ctx, span := startSpan(ctx, "SprintRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &sprint, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSprintNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &sprint, nil
+}
+
+// GetAll lists sprints in the order they start, only those of one project
+// unless projectID is nil
+func (r *SprintRepo) GetAll(ctx context.Context, projectID *int) ([]models.Sprint, error) {
+	sprints := []models.Sprint{}
+	query := `SELECT * FROM sprint WHERE $1 IS NULL OR project_id = $1 ORDER BY start_date, id`
+	ctx, span := startSpan(ctx, "SprintRepo.GetAll", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &sprints, query, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return sprints, nil
+}
+
+// SetTaskSprint puts a task into a sprint, nil takes it out of its sprint
+func (r *SprintRepo) SetTaskSprint(ctx context.Context, taskID int, sprintID *int) error {
+	query := `UPDATE task SET sprint_id = $1 WHERE id = $2`
+	ctx, span := startSpan(ctx, "SprintRepo.SetTaskSprint", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, sprintID, taskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if updated, _ := res.RowsAffected(); updated == 0 {
+		return ErrTaskNotFound
+	}
+	return nil
+}
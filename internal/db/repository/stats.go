@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+// IStatsRepo aggregates the tasks matching a TaskQuery, its Sort is
+// ignored
+type IStatsRepo interface {
+	StatusCounts(ctx context.Context, q TaskQuery) ([]StatusCount, error)
+	Completion(ctx context.Context, q TaskQuery, from time.Time, to time.Time) (*Completion, error)
+	Series(ctx context.Context, q TaskQuery, bounds []time.Time) ([]SeriesCount, error)
+}
+
+type StatsRepo struct {
+	db *sqlx.DB
+}
+
+func NewStatsRepo(db *sqlx.DB) IStatsRepo {
+	return &StatsRepo{db}
+}
+
+// StatusCount counts the tasks in one workflow state
+type StatusCount struct {
+	Status  string `db:"status"`
+	Count   int    `db:"count"`
+	Open    int    `db:"open"`
+	Overdue int    `db:"overdue"`
+}
+
+// Completion describes the tasks completed in a time range
+type Completion struct {
+	Completed int `db:"completed"`
+	// Completed tasks that had a due date, and those of them completed
+	// before they became overdue
+	WithDue int `db:"with_due"`
+	OnTime  int `db:"on_time"`
+	// Median seconds from creation to completion, nil when no completed
+	// task has a creation time
+	MedianSeconds *float64 `db:"median_seconds"`
+}
+
+// SeriesCount is the number of tasks created and completed in one bucket
+type SeriesCount struct {
+	Created   int `db:"created"`
+	Completed int `db:"completed"`
+}
+
+// and joins the query's filters with more conditions into a WHERE clause
+func and(q TaskQuery, more ...string) (string, []interface{}) {
+	conditions, args := q.conditions()
+	conditions = append(conditions, more...)
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *StatsRepo) StatusCounts(ctx context.Context, q TaskQuery) ([]StatusCount, error) {
+	where, args := and(q)
+	query := `
+    SELECT status, COUNT(*) AS count, SUM(completed = false) AS open, SUM(completed = false AND overdue) AS overdue
+    FROM task` + where + `
+    GROUP BY status ORDER BY status`
+	ctx, span := startSpan(ctx, "StatsRepo.StatusCounts", query)
+	defer span.End()
+	counts := []StatusCount{}
+	if err := r.db.SelectContext(ctx, &counts, query, args...); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Completion aggregates the tasks completed from from up to but excluding
+// to. The median is the middle duration, or the mean of the middle two.
+func (r *StatsRepo) Completion(ctx context.Context, q TaskQuery, from time.Time, to time.Time) (*Completion, error) {
+	where, args := and(q, "completed = true", "completed_at >= ?", "completed_at < ?")
+	query := `
+    WITH done AS (
+        SELECT created_at, completed_at, overdue_at FROM task` + where + `
+    ), took AS (
+        SELECT (julianday(completed_at) - julianday(created_at)) * 86400 AS seconds
+        FROM done WHERE created_at IS NOT NULL
+    )
+    SELECT COUNT(*) AS completed, COUNT(overdue_at) AS with_due,
+        COALESCE(SUM(completed_at <= overdue_at), 0) AS on_time,
+        (SELECT AVG(seconds) FROM (
+            SELECT seconds FROM took ORDER BY seconds
+            LIMIT 2 - (SELECT COUNT(*) FROM took) % 2 OFFSET (SELECT (COUNT(*) - 1) / 2 FROM took)
+        )) AS median_seconds
+    FROM done`
+	ctx, span := startSpan(ctx, "StatsRepo.Completion", query)
+	defer span.End()
+	completion := &Completion{}
+	args = append(args, from.UTC(), to.UTC())
+	if err := r.db.GetContext(ctx, completion, query, args...); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return completion, nil
+}
+
+// Series counts the tasks created and completed between each pair of
+// consecutive bounds, the first bound included and the second excluded
+func (r *StatsRepo) Series(ctx context.Context, q TaskQuery, bounds []time.Time) ([]SeriesCount, error) {
+	where, args := and(q)
+	query := `
+    WITH filtered AS (
+        SELECT created_at, completed, completed_at FROM task` + where + `
+    ), bucket AS (
+        SELECT key, value AS start, lead(value) OVER (ORDER BY key) AS stop FROM json_each(?)
+    )
+    SELECT
+        (SELECT COUNT(*) FROM filtered WHERE created_at >= start AND created_at < stop) AS created,
+        (SELECT COUNT(*) FROM filtered WHERE completed = true AND completed_at >= start AND completed_at < stop) AS completed
+    FROM bucket WHERE stop IS NOT NULL ORDER BY key`
+	ctx, span := startSpan(ctx, "StatsRepo.Series", query)
+	defer span.End()
+
+	// Bounds are compared as text, so they are written the way the driver
+	// stores times
+	formatted := make([]string, len(bounds))
+	for i, bound := range bounds {
+		formatted[i] = bound.UTC().Format(sqlite3.SQLiteTimestampFormats[0])
+	}
+	encoded, err := json.Marshal(formatted)
+	if err != nil {
+		return nil, err
+	}
+	counts := []SeriesCount{}
+	if err := r.db.SelectContext(ctx, &counts, query, append(args, string(encoded))...); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return counts, nil
+}
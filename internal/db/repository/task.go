@@ -5,54 +5,124 @@ import (
 	"database/sql"
 	"errors"
 	"strings"
+	"time"
 	"todo-api/internal/db/models"
+	"todo-api/internal/rank"
+	"todo-api/internal/telemetry"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/mattn/go-sqlite3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = telemetry.Tracer("todo-api/internal/db/repository")
+
 type ITaskRepo interface {
 	Create(ctx context.Context, task *models.Task) error
 	Update(ctx context.Context, task *models.Task) error
 	GetByID(ctx context.Context, id int) (*models.Task, error)
-	GetAll(ctx context.Context) ([]models.Task, error)
+	GetAll(ctx context.Context, q TaskQuery) ([]models.Task, error)
 	Delete(ctx context.Context, id int) error
-	GetTasksAfterDue(ctx context.Context) ([]models.Task, error)
+	ReconcileOverdue(ctx context.Context, now time.Time) (OverdueSummary, error)
+	EscalateOverdue(ctx context.Context, cutoff time.Time) (int, error)
+	Move(ctx context.Context, task *models.Task, from string, at Placement) error
+	Rebalance(ctx context.Context, maxLength int) (int, error)
+	GetOpenDueBefore(ctx context.Context, userID int, before time.Time) ([]models.Task, error)
+	SetTags(ctx context.Context, id int, tags []string) error
 }
 
 type TaskRepo struct {
 	db *sqlx.DB
 }
 
+// Columns returned by statements that write a task
+const taskColumns = "id, project_id, title, description, due_date, due_all_day, due_tz, overdue_at, reminders, priority, escalated, status, position, completed, overdue, created_at, completed_at, estimate_points, estimate_minutes, sprint_id"
+
 var (
 	ErrTaskNotFound  = errors.New("task not found")
 	ErrNoTitle       = errors.New("title is required")
 	ErrAlreadyExists = errors.New("task with given id already exists")
+	ErrStatusChanged = errors.New("task status was changed concurrently")
+	ErrBadPlacement  = errors.New("neighbours are not adjacent cards of the target column")
+	ErrMoveConflict  = errors.New("column kept changing while the task was placed")
 )
 
 func NewTaskRepo(db *sqlx.DB) ITaskRepo {
 	return &TaskRepo{db}
 }
 
+// startSpan starts a client span for a single SQL statement
+func startSpan(ctx context.Context, name string, query string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("db.statement", query),
+		))
+}
+
+// Create inserts a task at the end of its board column
 func (r *TaskRepo) Create(ctx context.Context, task *models.Task) error {
 	query := `
-    INSERT INTO task(id, title, description, due_date)
-    VALUES($1, $2, $3, $4)
-    RETURNING id, title, description, due_date, completed, overdue
-    `
-	row := r.db.QueryRowxContext(ctx, query, task.ID, task.Title, task.Description, task.DueDate)
-	err := row.StructScan(task)
-	if err != nil {
+    INSERT INTO task(id, project_id, title, description, due_date, due_all_day, due_tz, overdue_at, reminders, priority,
+        status, position, completed, estimate_points, estimate_minutes, created_at)
+    VALUES($1, $2, $3, $4, $5, COALESCE($6, 0), COALESCE($7, 'UTC'), $8, $9, COALESCE($10, 0), $11, $12, COALESCE($13, 0),
+        $14, $15, $16)
+    RETURNING ` + taskColumns
+	ctx, span := startSpan(ctx, "TaskRepo.Create", query)
+	defer span.End()
+
+	projectID := models.DefaultProjectID
+	if task.ProjectID != nil {
+		projectID = *task.ProjectID
+	}
+	status := task.Status
+	if status == nil {
+		initial := ""
+		if err := r.db.GetContext(ctx, &initial,
+			`SELECT name FROM workflow_state WHERE project_id = $1 AND initial`, projectID); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+		status = &initial
+	}
+	// A concurrent insert may take the same key, the next attempt sees it
+	for attempt := 0; ; attempt++ {
+		last, err := r.lastPosition(ctx, projectID, *status, 0)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+		position, err := rank.After(last)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+		row := r.db.QueryRowxContext(ctx, query, task.ID, projectID, task.Title, task.Description, task.DueDate,
+			task.DueAllDay, task.TimeZone, task.OverdueAt, task.Reminders, task.Priority, status, position, task.Completed,
+			task.EstimatePoints, task.EstimateMinutes, utc(task.CreatedAt))
+		// A failed scan leaves nil fields allocated, which would change the
+		// next attempt's arguments
+		created := models.Task{Assignees: []models.Assignee{}, Tags: []string{}}
+		err = row.StructScan(&created)
+		if err == nil {
+			*task = created
+			return nil
+		}
+		telemetry.RecordError(span, err)
 		if sqliteErr, ok := err.(sqlite3.Error); ok {
-			if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			switch {
+			case sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey:
 				return ErrAlreadyExists
-			} else if sqliteErr.ExtendedCode == sqlite3.ErrConstraintNotNull {
+			case sqliteErr.ExtendedCode == sqlite3.ErrConstraintNotNull:
 				return ErrNoTitle
+			case sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique && attempt < maxPlaceAttempts:
+				continue
 			}
 		}
 		return err
 	}
-	return nil
 }
 
 func (r *TaskRepo) Update(ctx context.Context, task *models.Task) error {
@@ -67,53 +137,103 @@ func (r *TaskRepo) Update(ctx context.Context, task *models.Task) error {
 	if task.DueDate != nil {
 		query += " due_date = :due_date,"
 	}
+	if task.DueAllDay != nil {
+		query += " due_all_day = :due_all_day,"
+	}
+	if task.TimeZone != nil {
+		query += " due_tz = :due_tz,"
+	}
+	if task.OverdueAt != nil {
+		query += " overdue_at = :overdue_at,"
+	}
+	if task.Reminders != nil {
+		query += " reminders = :reminders,"
+	}
+	if task.Priority != nil {
+		query += " priority = :priority,"
+	}
+	if task.Escalated != nil {
+		query += " escalated = :escalated,"
+	}
 	if task.Completed != nil {
 		query += " completed = :completed,"
 	}
 	if task.Overdue != nil {
 		query += " overdue = :overdue,"
 	}
+	if task.EstimatePoints != nil {
+		query += " estimate_points = :estimate_points,"
+	}
+	if task.EstimateMinutes != nil {
+		query += " estimate_minutes = :estimate_minutes,"
+	}
 	query = strings.TrimSuffix(query, ",") +
 		" WHERE id = :id" +
-		" RETURNING id, title, description, due_date, completed, overdue"
+		" RETURNING " + taskColumns
+	ctx, span := startSpan(ctx, "TaskRepo.Update", query)
+	defer span.End()
 
 	rows, err := r.db.NamedQueryContext(ctx, query, task)
 	if err != nil {
+		telemetry.RecordError(span, err)
 		return err
 	}
 
+	// The statement holds the write lock until its rows are closed
 	if rows.Next() {
-		defer rows.Close()
 		err = rows.StructScan(task)
+		rows.Close()
 	} else {
+		rows.Close()
 		return ErrTaskNotFound
 	}
 
 	if err != nil {
+		telemetry.RecordError(span, err)
 		return err
 	}
 
-	return nil
+	return r.loadDetails(ctx, task)
 }
 
 func (r *TaskRepo) GetByID(ctx context.Context, id int) (*models.Task, error) {
 	task := &models.Task{}
 	query := `SELECT * FROM task WHERE id = $1`
+	ctx, span := startSpan(ctx, "TaskRepo.GetByID", query)
+	defer span.End()
 	err := r.db.GetContext(ctx, task, query, id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrTaskNotFound
 		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if err := r.loadDetails(ctx, task); err != nil {
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
 	return task, nil
 }
 
-func (r *TaskRepo) GetAll(ctx context.Context) ([]models.Task, error) {
+// GetAll lists the tasks matching q in the order it asks for
+func (r *TaskRepo) GetAll(ctx context.Context, q TaskQuery) ([]models.Task, error) {
 	tasks := []models.Task{}
-	query := `SELECT * FROM task`
-	err := r.db.SelectContext(ctx, &tasks, query)
+	where, args := q.where()
+	query := `SELECT * FROM task` + where + ` ORDER BY ` + q.orderBy()
+	ctx, span := startSpan(ctx, "TaskRepo.GetAll", query)
+	defer span.End()
+	err := r.db.SelectContext(ctx, &tasks, query, args...)
 	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	pointers := make([]*models.Task, len(tasks))
+	for i := range tasks {
+		pointers[i] = &tasks[i]
+	}
+	if err := r.loadDetails(ctx, pointers...); err != nil {
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
 	return tasks, nil
@@ -121,23 +241,263 @@ func (r *TaskRepo) GetAll(ctx context.Context) ([]models.Task, error) {
 
 func (r *TaskRepo) Delete(ctx context.Context, id int) error {
 	query := `DELETE FROM task WHERE id = $1`
-	res, err := r.db.ExecContext(ctx, query, id)
+	ctx, span := startSpan(ctx, "TaskRepo.Delete", query)
+	defer span.End()
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, query, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
 		return err
 	}
 
 	if rowsAffected, _ := res.RowsAffected(); rowsAffected == 0 {
 		return ErrTaskNotFound
 	}
+	for _, related := range []string{
+		`DELETE FROM task_assignee WHERE task_id = $1`,
+		`DELETE FROM comment_mention WHERE comment_id IN (SELECT id FROM task_comment WHERE task_id = $1)`,
+		`DELETE FROM task_comment WHERE task_id = $1`,
+		`DELETE FROM time_entry WHERE task_id = $1`,
+		`DELETE FROM task_attachment WHERE task_id = $1`,
+		`DELETE FROM task_tag WHERE task_id = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, related, id); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
 	return nil
 }
 
-func (r *TaskRepo) GetTasksAfterDue(ctx context.Context) ([]models.Task, error) {
-	query := `SELECT * FROM task WHERE due_date < CURRENT_TIMESTAMP AND overdue = false`
-	tasks := []models.Task{}
-	err := r.db.SelectContext(ctx, &tasks, query)
+// SetTags replaces the tags of a task
+func (r *TaskRepo) SetTags(ctx context.Context, id int, tags []string) error {
+	query := `DELETE FROM task_tag WHERE task_id = $1`
+	ctx, span := startSpan(ctx, "TaskRepo.SetTags", query)
+	defer span.End()
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	defer tx.Rollback()
+
+	exists := false
+	if err := tx.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM task WHERE id = $1)`, id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if !exists {
+		return ErrTaskNotFound
+	}
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO task_tag(task_id, tag) VALUES($1, $2)`, id, tag); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// GetOpenDueBefore returns the tasks assigned to a user that are not
+// completed and are due before the given time, overdue ones included,
+// earliest first
+func (r *TaskRepo) GetOpenDueBefore(ctx context.Context, userID int, before time.Time) ([]models.Task, error) {
+	tasks := []models.Task{}
+	query := `
+    SELECT * FROM task
+    WHERE completed = false AND due_date < $1 AND id IN (SELECT task_id FROM task_assignee WHERE user_id = $2)
+    ORDER BY due_date, id
+    `
+	ctx, span := startSpan(ctx, "TaskRepo.GetOpenDueBefore", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &tasks, query, before.UTC(), userID); err != nil {
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
 	return tasks, nil
 }
+
+// OverdueSummary counts the overdue transitions of one reconcile pass
+type OverdueSummary struct {
+	Flagged int `json:"flagged"`
+	Cleared int `json:"cleared"`
+}
+
+// ReconcileOverdue sets the overdue flag of every task to whether it is
+// overdue at now, in both directions. Completed tasks and tasks without a
+// due date are never overdue. Flagging and clearing are separate updates so
+// both can find their rows through idx_task_overdue_at.
+func (r *TaskRepo) ReconcileOverdue(ctx context.Context, now time.Time) (OverdueSummary, error) {
+	flag := `
+    UPDATE task SET overdue = true
+    WHERE overdue = false AND overdue_at < $1 AND completed = false
+    `
+	clear := `
+    UPDATE task SET overdue = false, escalated = false
+    WHERE overdue = true AND (overdue_at IS NULL OR overdue_at >= $1 OR completed = true)
+    `
+	ctx, span := startSpan(ctx, "TaskRepo.ReconcileOverdue", flag+";"+clear)
+	defer span.End()
+	summary := OverdueSummary{}
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return summary, err
+	}
+	defer tx.Rollback()
+
+	for _, update := range []struct {
+		query string
+		count *int
+	}{{flag, &summary.Flagged}, {clear, &summary.Cleared}} {
+		result, err := tx.ExecContext(ctx, update.query, now.UTC())
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return OverdueSummary{}, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return OverdueSummary{}, err
+		}
+		*update.count = int(affected)
+	}
+
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return OverdueSummary{}, err
+	}
+	return summary, nil
+}
+
+// EscalateOverdue raises the priority of open tasks that became overdue
+// before cutoff by one level, once per overdue period
+func (r *TaskRepo) EscalateOverdue(ctx context.Context, cutoff time.Time) (int, error) {
+	query := `
+    UPDATE task SET priority = priority + 1, escalated = true
+    WHERE overdue = true AND completed = false AND escalated = false AND overdue_at < $1 AND priority < $2
+    `
+	ctx, span := startSpan(ctx, "TaskRepo.EscalateOverdue", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, cutoff.UTC(), models.PriorityUrgent)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	escalated, err := res.RowsAffected()
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	return int(escalated), nil
+}
+
+// Tasks per details lookup, kept below SQLite's variable limit
+const detailsBatch = 500
+
+// loadDetails fills in the assignees of tasks, in assignment order, their
+// comment counts and the time logged on them
+func (r *TaskRepo) loadDetails(ctx context.Context, tasks ...*models.Task) error {
+	byTask := map[int]*models.Task{}
+	ids := []int{}
+	for _, task := range tasks {
+		task.Assignees = []models.Assignee{}
+		task.Tags = []string{}
+		task.CommentCount = 0
+		task.TimeSpent = 0
+		if task.ID != nil {
+			byTask[*task.ID] = task
+			ids = append(ids, *task.ID)
+		}
+	}
+	for start := 0; start < len(ids); start += detailsBatch {
+		batch := ids[start:min(start+detailsBatch, len(ids))]
+		query, args, err := sqlx.In(`
+    SELECT a.task_id, a.user_id, u.name FROM task_assignee a JOIN user u ON u.id = a.user_id
+    WHERE a.task_id IN (?)
+    ORDER BY a.assigned_at, a.user_id`, batch)
+		if err != nil {
+			return err
+		}
+		assignees := []struct {
+			TaskID int `db:"task_id"`
+			models.Assignee
+		}{}
+		if err := r.db.SelectContext(ctx, &assignees, r.db.Rebind(query), args...); err != nil {
+			return err
+		}
+		for _, row := range assignees {
+			task := byTask[row.TaskID]
+			task.Assignees = append(task.Assignees, row.Assignee)
+		}
+
+		query, args, err = sqlx.In(`SELECT task_id, tag FROM task_tag WHERE task_id IN (?) ORDER BY task_id, tag`, batch)
+		if err != nil {
+			return err
+		}
+		tags := []struct {
+			TaskID int    `db:"task_id"`
+			Tag    string `db:"tag"`
+		}{}
+		if err := r.db.SelectContext(ctx, &tags, r.db.Rebind(query), args...); err != nil {
+			return err
+		}
+		for _, row := range tags {
+			task := byTask[row.TaskID]
+			task.Tags = append(task.Tags, row.Tag)
+		}
+
+		query, args, err = sqlx.In(`
+    SELECT task_id, COUNT(*) AS comments FROM task_comment WHERE task_id IN (?) GROUP BY task_id`, batch)
+		if err != nil {
+			return err
+		}
+		counts := []struct {
+			TaskID   int `db:"task_id"`
+			Comments int `db:"comments"`
+		}{}
+		if err := r.db.SelectContext(ctx, &counts, r.db.Rebind(query), args...); err != nil {
+			return err
+		}
+		for _, row := range counts {
+			byTask[row.TaskID].CommentCount = row.Comments
+		}
+
+		query, args, err = sqlx.In(`
+    SELECT task_id, SUM(duration) AS spent FROM time_entry
+    WHERE task_id IN (?) AND duration IS NOT NULL
+    GROUP BY task_id`, batch)
+		if err != nil {
+			return err
+		}
+		spent := []struct {
+			TaskID int   `db:"task_id"`
+			Spent  int64 `db:"spent"`
+		}{}
+		if err := r.db.SelectContext(ctx, &spent, r.db.Rebind(query), args...); err != nil {
+			return err
+		}
+		for _, row := range spent {
+			byTask[row.TaskID].TimeSpent = row.Spent
+		}
+	}
+	return nil
+}
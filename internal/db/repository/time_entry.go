@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+type ITimeEntryRepo interface {
+	Create(ctx context.Context, entry *models.TimeEntry) error
+	Stop(ctx context.Context, taskID int, userID int, at time.Time) (*models.TimeEntry, error)
+	Update(ctx context.Context, entry *models.TimeEntry) error
+	GetByID(ctx context.Context, taskID int, id int) (*models.TimeEntry, error)
+	GetByTask(ctx context.Context, taskID int) ([]models.TimeEntry, error)
+	GetFinished(ctx context.Context, from time.Time, to time.Time, userID *int) ([]ReportEntry, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type TimeEntryRepo struct {
+	db *sqlx.DB
+}
+
+var (
+	ErrTimeEntryNotFound = errors.New("time entry not found")
+	ErrTimerRunning      = errors.New("user already has a running timer")
+	ErrNoRunningTimer    = errors.New("no timer of the user is running on this task")
+)
+
+// ReportEntry is a finished time entry with the task and project it
+// belongs to
+type ReportEntry struct {
+	models.TimeEntry
+	TaskTitle   string `db:"task_title"`
+	ProjectID   int    `db:"project_id"`
+	ProjectName string `db:"project_name"`
+}
+
+func NewTimeEntryRepo(db *sqlx.DB) ITimeEntryRepo {
+	return &TimeEntryRepo{db}
+}
+
+// duration is the whole seconds between start and end of an entry
+func duration(entry *models.TimeEntry) *int64 {
+	if entry.EndedAt == nil {
+		return nil
+	}
+	seconds := int64(entry.EndedAt.Sub(entry.StartedAt) / time.Second)
+	return &seconds
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// isRunningConflict reports whether err is the one running timer per user
+// constraint
+func isRunningConflict(err error) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// Create stores an entry, an entry without an end starts a timer and fails
+// with ErrTimerRunning while the user has another one running
+func (r *TimeEntryRepo) Create(ctx context.Context, entry *models.TimeEntry) error {
+	query := `
+    INSERT INTO time_entry(task_id, user_id, started_at, ended_at, duration, note) VALUES($1, $2, $3, $4, $5, $6)
+    RETURNING *`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.Create", query)
+	defer span.End()
+	created := models.TimeEntry{}
+	if err := r.db.GetContext(ctx, &created, query, entry.TaskID, entry.UserID, entry.StartedAt.UTC(),
+		utc(entry.EndedAt), duration(entry), entry.Note); err != nil {
+		if isRunningConflict(err) {
+			return ErrTimerRunning
+		}
+		telemetry.RecordError(span, err)
+		return err
+	}
+	*entry = created
+	return nil
+}
+
+// Stop ends the user's running timer on a task at the given time
+func (r *TimeEntryRepo) Stop(ctx context.Context, taskID int, userID int, at time.Time) (*models.TimeEntry, error) {
+	query := `UPDATE time_entry SET ended_at = $1, duration = $2 WHERE id = $3 AND ended_at IS NULL`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.Stop", query)
+	defer span.End()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry := models.TimeEntry{}
+	if err := tx.GetContext(ctx, &entry, `SELECT * FROM time_entry WHERE task_id = $1 AND user_id = $2 AND ended_at IS NULL`,
+		taskID, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNoRunningTimer
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	// A clock that went backwards never makes a negative entry
+	if at.Before(entry.StartedAt) {
+		at = entry.StartedAt
+	}
+	endedAt := at.UTC()
+	entry.EndedAt = &endedAt
+	entry.Duration = duration(&entry)
+	if _, err := tx.ExecContext(ctx, query, entry.EndedAt, entry.Duration, entry.ID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Update replaces the start, end and note of an entry
+func (r *TimeEntryRepo) Update(ctx context.Context, entry *models.TimeEntry) error {
+	query := `
+    UPDATE time_entry SET started_at = $1, ended_at = $2, duration = $3, note = $4 WHERE id = $5 AND task_id = $6
+    RETURNING *`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.Update", query)
+	defer span.End()
+	updated := models.TimeEntry{}
+	if err := r.db.GetContext(ctx, &updated, query, entry.StartedAt.UTC(), utc(entry.EndedAt), duration(entry),
+		entry.Note, entry.ID, entry.TaskID); err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			return ErrTimeEntryNotFound
+		case isRunningConflict(err):
+			return ErrTimerRunning
+		}
+		telemetry.RecordError(span, err)
+		return err
+	}
+	*entry = updated
+	return nil
+}
+
+func (r *TimeEntryRepo) GetByID(ctx context.Context, taskID int, id int) (*models.TimeEntry, error) {
+	entry := models.TimeEntry{}
+	query := `SELECT * FROM time_entry WHERE id = $1 AND task_id = $2`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &entry, query, id, taskID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTimeEntryNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetByTask returns the entries of a task, running timers included, in the
+// order they started
+func (r *TimeEntryRepo) GetByTask(ctx context.Context, taskID int) ([]models.TimeEntry, error) {
+	entries := []models.TimeEntry{}
+	query := `SELECT * FROM time_entry WHERE task_id = $1 ORDER BY started_at, id`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.GetByTask", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &entries, query, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// GetFinished returns the finished entries that started in [from, to),
+// only those of one user unless userID is nil
+func (r *TimeEntryRepo) GetFinished(ctx context.Context, from time.Time, to time.Time, userID *int) ([]ReportEntry, error) {
+	entries := []ReportEntry{}
+	query := `
+    SELECT e.*, COALESCE(t.title, '') AS task_title, t.project_id, COALESCE(p.name, '') AS project_name
+    FROM time_entry e JOIN task t ON t.id = e.task_id LEFT JOIN project p ON p.id = t.project_id
+    WHERE e.ended_at IS NOT NULL AND e.started_at >= $1 AND e.started_at < $2 AND ($3 IS NULL OR e.user_id = $3)
+    ORDER BY e.started_at, e.id`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.GetFinished", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &entries, query, from.UTC(), to.UTC(), userID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *TimeEntryRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM time_entry WHERE id = $1`
+	ctx, span := startSpan(ctx, "TimeEntryRepo.Delete", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if deleted, _ := res.RowsAffected(); deleted == 0 {
+		return ErrTimeEntryNotFound
+	}
+	return nil
+}
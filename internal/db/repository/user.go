@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattn/go-sqlite3"
+)
+
+type IUserRepo interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int) (*models.User, error)
+	GetAll(ctx context.Context) ([]models.User, error)
+	UpdateDigest(ctx context.Context, user *models.User) error
+	GetDigestEnabled(ctx context.Context) ([]models.User, error)
+	ClaimDigest(ctx context.Context, id int, sendAt time.Time, at time.Time) (bool, error)
+	ReleaseDigest(ctx context.Context, id int, at time.Time, previous *time.Time) error
+}
+
+type UserRepo struct {
+	db *sqlx.DB
+}
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+func NewUserRepo(db *sqlx.DB) IUserRepo {
+	return &UserRepo{db}
+}
+
+func (r *UserRepo) Create(ctx context.Context, user *models.User) error {
+	query := `
+    INSERT INTO user(username, name, email, time_zone)
+    VALUES($1, $2, $3, COALESCE($4, 'UTC'))
+    RETURNING *`
+	ctx, span := startSpan(ctx, "UserRepo.Create", query)
+	defer span.End()
+	row := r.db.QueryRowxContext(ctx, query, user.Username, user.Name, user.Email, user.TimeZone)
+	if err := row.StructScan(user); err != nil {
+		telemetry.RecordError(span, err)
+		if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ErrUsernameTaken
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepo) GetByID(ctx context.Context, id int) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT * FROM user WHERE id = $1`
+	ctx, span := startSpan(ctx, "UserRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, user, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *UserRepo) GetAll(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	query := `SELECT * FROM user ORDER BY id`
+	ctx, span := startSpan(ctx, "UserRepo.GetAll", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &users, query); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateDigest stores the digest settings and zone of a user, nil fields
+// are left unchanged
+func (r *UserRepo) UpdateDigest(ctx context.Context, user *models.User) error {
+	query := `
+    UPDATE user SET
+        digest_enabled = COALESCE($1, digest_enabled),
+        digest_time = COALESCE($2, digest_time),
+        time_zone = COALESCE($3, time_zone)
+    WHERE id = $4
+    RETURNING *`
+	ctx, span := startSpan(ctx, "UserRepo.UpdateDigest", query)
+	defer span.End()
+	row := r.db.QueryRowxContext(ctx, query, user.DigestEnabled, user.DigestTime, user.TimeZone, user.ID)
+	if err := row.StructScan(user); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrUserNotFound
+		}
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepo) GetDigestEnabled(ctx context.Context) ([]models.User, error) {
+	users := []models.User{}
+	query := `SELECT * FROM user WHERE digest_enabled = true ORDER BY id`
+	ctx, span := startSpan(ctx, "UserRepo.GetDigestEnabled", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &users, query); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// ClaimDigest marks the digest due at sendAt as sent at the given time,
+// before it is mailed. It reports false when it was already sent, so two
+// runs never mail the same digest.
+func (r *UserRepo) ClaimDigest(ctx context.Context, id int, sendAt time.Time, at time.Time) (bool, error) {
+	query := `UPDATE user SET digest_sent_at = $1 WHERE id = $2 AND (digest_sent_at IS NULL OR digest_sent_at < $3)`
+	ctx, span := startSpan(ctx, "UserRepo.ClaimDigest", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, at.UTC(), id, sendAt.UTC())
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n == 1, nil
+}
+
+// ReleaseDigest undoes the claim made at the given time when the digest
+// could not be mailed, restoring the previous send time so the next run
+// retries it
+func (r *UserRepo) ReleaseDigest(ctx context.Context, id int, at time.Time, previous *time.Time) error {
+	query := `UPDATE user SET digest_sent_at = $1 WHERE id = $2 AND digest_sent_at = $3`
+	ctx, span := startSpan(ctx, "UserRepo.ReleaseDigest", query)
+	defer span.End()
+	if _, err := r.db.ExecContext(ctx, query, utc(previous), id, at.UTC()); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
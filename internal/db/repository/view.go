@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"todo-api/internal/db/models"
+	"todo-api/internal/telemetry"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IViewRepo interface {
+	Create(ctx context.Context, view *models.SavedView) error
+	Update(ctx context.Context, view *models.SavedView) error
+	GetByID(ctx context.Context, id int) (*models.SavedView, error)
+	GetAll(ctx context.Context) ([]models.SavedView, error)
+	Delete(ctx context.Context, id int) error
+}
+
+type ViewRepo struct {
+	db *sqlx.DB
+}
+
+var ErrViewNotFound = errors.New("saved view not found")
+
+func NewViewRepo(db *sqlx.DB) IViewRepo {
+	return &ViewRepo{db}
+}
+
+func (r *ViewRepo) Create(ctx context.Context, view *models.SavedView) error {
+	query := `
+    INSERT INTO saved_view(name, query, created_by, created_at, updated_at) VALUES($1, $2, $3, $4, $4)
+    RETURNING *`
+	ctx, span := startSpan(ctx, "ViewRepo.Create", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, view, query, view.Name, view.Query, view.CreatedBy, view.CreatedAt.UTC()); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// Update renames a view and replaces its query
+func (r *ViewRepo) Update(ctx context.Context, view *models.SavedView) error {
+	query := `UPDATE saved_view SET name = $1, query = $2, updated_at = $3 WHERE id = $4 RETURNING *`
+	ctx, span := startSpan(ctx, "ViewRepo.Update", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, view, query, view.Name, view.Query, view.UpdatedAt.UTC(), view.ID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrViewNotFound
+		}
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (r *ViewRepo) GetByID(ctx context.Context, id int) (*models.SavedView, error) {
+	view := models.SavedView{}
+	query := `SELECT * FROM saved_view WHERE id = $1`
+	ctx, span := startSpan(ctx, "ViewRepo.GetByID", query)
+	defer span.End()
+	if err := r.db.GetContext(ctx, &view, query, id); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrViewNotFound
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return &view, nil
+}
+
+// GetAll lists views by name
+func (r *ViewRepo) GetAll(ctx context.Context) ([]models.SavedView, error) {
+	views := []models.SavedView{}
+	query := `SELECT * FROM saved_view ORDER BY name, id`
+	ctx, span := startSpan(ctx, "ViewRepo.GetAll", query)
+	defer span.End()
+	if err := r.db.SelectContext(ctx, &views, query); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return views, nil
+}
+
+func (r *ViewRepo) Delete(ctx context.Context, id int) error {
+	query := `DELETE FROM saved_view WHERE id = $1`
+	ctx, span := startSpan(ctx, "ViewRepo.Delete", query)
+	defer span.End()
+	res, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrViewNotFound
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package digest
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	texttemplate "text/template"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/timezone"
+)
+
+//go:embed templates
+var templates embed.FS
+
+var (
+	textTemplate = texttemplate.Must(texttemplate.ParseFS(templates, "templates/digest.txt.tmpl"))
+	htmlTemplate = htmltemplate.Must(htmltemplate.ParseFS(templates, "templates/digest.html.tmpl"))
+)
+
+// Days after today that count as this week
+const weekDays = 7
+
+// Item is a task as listed in a digest
+type Item struct {
+	ID    int
+	Title string
+	Due   string
+}
+
+// Digest lists a user's open tasks by urgency, dates are in the user's zone
+type Digest struct {
+	Name    string
+	Date    string
+	Overdue []Item
+	Today   []Item
+	Week    []Item
+}
+
+// Horizon is the end of the period a digest covers, tasks due before it are
+// candidates for Build
+func Horizon(now time.Time, loc *time.Location) time.Time {
+	return startOfDay(now.In(loc)).AddDate(0, 0, weekDays+1)
+}
+
+// Build sorts open tasks into overdue, due today and due in the next seven
+// days as seen in loc. All-day tasks keep the date of their own zone.
+func Build(user models.User, tasks []models.Task, now time.Time, loc *time.Location) Digest {
+	today := startOfDay(now.In(loc))
+	d := Digest{Date: today.Format("Monday 2 January")}
+	if user.Name != nil {
+		d.Name = *user.Name
+	}
+	for _, task := range tasks {
+		if task.DueDate == nil || (task.Completed != nil && *task.Completed) {
+			continue
+		}
+		due, allDay := localDue(task, loc)
+		item := Item{ID: *task.ID, Title: *task.Title, Due: due.Format("Mon 2 Jan 15:04")}
+		if allDay {
+			item.Due = due.Format("Mon 2 Jan")
+		}
+		day := time.Date(due.Year(), due.Month(), due.Day(), 0, 0, 0, 0, loc)
+		switch {
+		case task.OverdueAt != nil && task.OverdueAt.Before(now):
+			d.Overdue = append(d.Overdue, item)
+		case day.Equal(today):
+			d.Today = append(d.Today, item)
+		case day.After(today) && day.Before(today.AddDate(0, 0, weekDays+1)):
+			d.Week = append(d.Week, item)
+		}
+	}
+	return d
+}
+
+// localDue returns the due date in loc, or in the task's zone for all-day
+// tasks
+func localDue(task models.Task, loc *time.Location) (time.Time, bool) {
+	if task.DueAllDay != nil && *task.DueAllDay && task.TimeZone != nil {
+		if taskLoc, err := timezone.Load(*task.TimeZone); err == nil {
+			return task.DueDate.In(taskLoc), true
+		}
+	}
+	return task.DueDate.In(loc), false
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Section is a titled group of digest items
+type Section struct {
+	Title string
+	Items []Item
+}
+
+// Sections lists the non-empty groups in order of urgency
+func (d Digest) Sections() []Section {
+	sections := []Section{}
+	for _, section := range []Section{
+		{"Overdue", d.Overdue},
+		{"Due today", d.Today},
+		{"Due this week", d.Week},
+	} {
+		if len(section.Items) > 0 {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// Empty reports whether there is nothing to list
+func (d Digest) Empty() bool {
+	return len(d.Overdue) == 0 && len(d.Today) == 0 && len(d.Week) == 0
+}
+
+func (d Digest) Subject() string {
+	return fmt.Sprintf("Tasks for %s: %d overdue, %d due today, %d this week",
+		d.Date, len(d.Overdue), len(d.Today), len(d.Week))
+}
+
+// Render returns the plain text and HTML bodies of the digest
+func (d Digest) Render() (string, string, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return "", "", err
+	}
+	return text.String(), html.String(), nil
+}
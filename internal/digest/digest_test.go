@@ -0,0 +1,94 @@
+package digest
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+)
+
+func task(id int, title string, dueDate time.Time, allDay bool, zone string, overdue bool) models.Task {
+	overdueAt := dueDate
+	if allDay {
+		overdueAt = dueDate.AddDate(0, 0, 1)
+	}
+	if overdue {
+		overdueAt = dueDate.Add(-time.Hour)
+	}
+	completed := false
+	return models.Task{ID: &id, Title: &title, DueDate: &dueDate, DueAllDay: &allDay, TimeZone: &zone,
+		OverdueAt: &overdueAt, Completed: &completed}
+}
+
+func TestBuild(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("Error loading zone: %v", err)
+	}
+	// 20 November, 08:00 in Berlin
+	now := time.Date(2024, 11, 20, 7, 0, 0, 0, time.UTC)
+	tasks := []models.Task{
+		task(1, "late", now.Add(-24*time.Hour), false, "UTC", true),
+		task(2, "tonight", time.Date(2024, 11, 20, 22, 0, 0, 0, berlin), false, "Europe/Berlin", false),
+		// 23:30 in Berlin, due today there
+		task(3, "late night", time.Date(2024, 11, 20, 22, 30, 0, 0, time.UTC), false, "UTC", false),
+		// 00:30 on 21 November in Berlin, still 20 November in UTC
+		task(7, "after midnight", time.Date(2024, 11, 20, 23, 30, 0, 0, time.UTC), false, "UTC", false),
+		task(4, "all-day today", time.Date(2024, 11, 20, 0, 0, 0, 0, time.UTC), true, "UTC", false),
+		task(5, "friday", time.Date(2024, 11, 22, 0, 0, 0, 0, berlin), true, "Europe/Berlin", false),
+		task(6, "next month", time.Date(2024, 12, 20, 0, 0, 0, 0, berlin), true, "Europe/Berlin", false),
+	}
+	name := "Ada"
+	d := Build(models.User{Name: &name}, tasks, now, berlin)
+
+	ids := func(items []Item) []int {
+		got := []int{}
+		for _, item := range items {
+			got = append(got, item.ID)
+		}
+		return got
+	}
+	for _, tt := range []struct {
+		section string
+		got     []int
+		want    []int
+	}{
+		{"overdue", ids(d.Overdue), []int{1}},
+		{"today", ids(d.Today), []int{2, 3, 4}},
+		{"week", ids(d.Week), []int{7, 5}},
+	} {
+		if len(tt.got) != len(tt.want) {
+			t.Errorf("Expected %s %v, got %v", tt.section, tt.want, tt.got)
+			continue
+		}
+		for i := range tt.got {
+			if tt.got[i] != tt.want[i] {
+				t.Errorf("Expected %s %v, got %v", tt.section, tt.want, tt.got)
+			}
+		}
+	}
+	if d.Today[0].Due != "Wed 20 Nov 22:00" || d.Week[1].Due != "Fri 22 Nov" {
+		t.Errorf("Unexpected due dates %q, %q", d.Today[0].Due, d.Week[1].Due)
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := Digest{
+		Name:    "Ada",
+		Date:    "Wednesday 20 November",
+		Overdue: []Item{{ID: 1, Title: "<b>late</b>", Due: "Tue 19 Nov"}},
+	}
+	text, html, err := d.Render()
+	if err != nil {
+		t.Fatalf("Error rendering digest: %v", err)
+	}
+	if !strings.Contains(text, "Overdue\n  - <b>late</b> (#1, due Tue 19 Nov)") || strings.Contains(text, "Due today") {
+		t.Errorf("Unexpected text body:\n%s", text)
+	}
+	if !strings.Contains(html, "&lt;b&gt;late&lt;/b&gt;") {
+		t.Errorf("Expected the title escaped in HTML:\n%s", html)
+	}
+	if d.Subject() != "Tasks for Wednesday 20 November: 1 overdue, 0 due today, 0 this week" {
+		t.Errorf("Unexpected subject %q", d.Subject())
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type AssigneeController struct {
+	AssigneeService services.IAssigneeService
+	requestTimeout
+}
+
+func NewAssigneeController(assigneeService services.IAssigneeService, timeout time.Duration) *AssigneeController {
+	ac := &AssigneeController{AssigneeService: assigneeService}
+	ac.SetTimeout(timeout)
+	return ac
+}
+
+// parseUserParam reads the user_id path parameter
+func parseUserParam(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		return 0, problems.InvalidField("user_id", "user id must be an integer")
+	}
+	return id, nil
+}
+
+func (ac *AssigneeController) Assign(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	assignReq := requests.AssignTaskRequest{}
+	if err := bindAndValidate(c, &assignReq); err != nil {
+		return err
+	}
+
+	task, err := ac.AssigneeService.Assign(ctx, id, *assignReq.UserID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
+
+func (ac *AssigneeController) Unassign(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := parseUserParam(c)
+	if err != nil {
+		return err
+	}
+
+	task, err := ac.AssigneeService.Unassign(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
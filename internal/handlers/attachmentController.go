@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Room for the multipart headers around the file
+const multipartOverhead = 64 << 10
+
+type AttachmentController struct {
+	AttachmentService services.IAttachmentService
+	// Uploads whose body exceeds this are cut off while they are read
+	MaxUploadSize int64
+	requestTimeout
+}
+
+func NewAttachmentController(attachmentService services.IAttachmentService, maxFileSize int64,
+	timeout time.Duration) *AttachmentController {
+	ac := &AttachmentController{AttachmentService: attachmentService, MaxUploadSize: maxFileSize + multipartOverhead}
+	ac.SetTimeout(timeout)
+	return ac
+}
+
+func parseAttachmentID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		return 0, problems.InvalidField("attachment_id", "attachment id must be an integer")
+	}
+	return id, nil
+}
+
+// attachmentName keeps the base name of an uploaded file, clients may send
+// full paths
+func attachmentName(filename string) string {
+	name := filepath.Base(filepath.ToSlash(filename))
+	if name == "." || name == "/" {
+		name = "attachment"
+	}
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[:255])
+	}
+	return name
+}
+
+// CreateAttachment takes a multipart/form-data upload with the content in
+// the file field
+func (ac *AttachmentController) CreateAttachment(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	c.Request().Body = http.MaxBytesReader(c.Response(), c.Request().Body, ac.MaxUploadSize)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return problems.New(http.StatusRequestEntityTooLarge, problems.TypeFileTooLarge,
+				"file is larger than the per-file limit")
+		}
+		return problems.InvalidField("file", "file is required as a multipart/form-data field")
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	attachment := &models.Attachment{TaskID: taskID, Name: attachmentName(fileHeader.Filename)}
+	if err := ac.AttachmentService.CreateAttachment(ctx, attachment, file); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, attachment)
+}
+
+func (ac *AttachmentController) GetAttachments(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	attachments, err := ac.AttachmentService.GetAttachments(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, attachments)
+}
+
+// DownloadAttachment serves the content with its sniffed type, always as a
+// download so uploaded HTML never renders in the API's origin
+func (ac *AttachmentController) DownloadAttachment(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseAttachmentID(c)
+	if err != nil {
+		return err
+	}
+
+	attachment, content, err := ac.AttachmentService.OpenAttachment(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, attachment.MimeType)
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("attachment",
+		map[string]string{"filename": attachment.Name}))
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set("ETag", `"`+attachment.SHA256+`"`)
+	http.ServeContent(c.Response(), c.Request(), "", attachment.CreatedAt, content)
+	return nil
+}
+
+func (ac *AttachmentController) DeleteAttachment(c echo.Context) error {
+	ctx, cancel := ac.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseAttachmentID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := ac.AttachmentService.DeleteAttachment(ctx, taskID, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
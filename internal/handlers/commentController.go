@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/caller"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	defaultCommentLimit = 50
+	maxCommentLimit     = 200
+)
+
+type CommentController struct {
+	CommentService services.ICommentService
+	requestTimeout
+}
+
+// commentsResponse is a page of comments, NextCursor fetches the next one
+// and is missing on the last page
+type commentsResponse struct {
+	Comments   []models.Comment `json:"comments"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+func NewCommentController(commentService services.ICommentService, timeout time.Duration) *CommentController {
+	cc := &CommentController{CommentService: commentService}
+	cc.SetTimeout(timeout)
+	return cc
+}
+
+// requireCaller returns the calling user's id, comments always have an
+// author
+func requireCaller(c echo.Context) (int, error) {
+	userID, ok := caller.FromContext(c.Request().Context())
+	if !ok {
+		return 0, problems.New(http.StatusUnauthorized, problems.TypeCallerRequired,
+			"the "+caller.Header+" header is required")
+	}
+	return userID, nil
+}
+
+func parseCommentID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("comment_id"))
+	if err != nil {
+		return 0, problems.InvalidField("comment_id", "comment id must be an integer")
+	}
+	return id, nil
+}
+
+// The cursor is opaque to clients, it encodes the id of the last comment
+// of the previous page
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		if id, err := strconv.Atoi(string(raw)); err == nil && id > 0 {
+			return id, nil
+		}
+	}
+	return 0, problems.InvalidField("cursor", "cursor is not one returned by this endpoint")
+}
+
+func parseLimit(c echo.Context) (int, error) {
+	value := c.QueryParam("limit")
+	if value == "" {
+		return defaultCommentLimit, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxCommentLimit {
+		return 0, problems.InvalidField("limit", "limit must be between 1 and "+strconv.Itoa(maxCommentLimit))
+	}
+	return limit, nil
+}
+
+func (cc *CommentController) CreateComment(c echo.Context) error {
+	ctx, cancel := cc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	authorID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	commentReq := requests.CommentRequest{}
+	if err := bindAndValidate(c, &commentReq); err != nil {
+		return err
+	}
+
+	comment := &models.Comment{TaskID: taskID, AuthorID: authorID, Body: *commentReq.Body}
+	if err := cc.CommentService.CreateComment(ctx, comment); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, comment)
+}
+
+func (cc *CommentController) GetComments(c echo.Context) error {
+	ctx, cancel := cc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	after, err := decodeCursor(c.QueryParam("cursor"))
+	if err != nil {
+		return err
+	}
+	limit, err := parseLimit(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := cc.CommentService.GetComments(ctx, taskID, after, limit)
+	if err != nil {
+		return err
+	}
+	res := commentsResponse{Comments: page.Comments}
+	if page.Next != nil {
+		res.NextCursor = encodeCursor(*page.Next)
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+func (cc *CommentController) EditComment(c echo.Context) error {
+	ctx, cancel := cc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseCommentID(c)
+	if err != nil {
+		return err
+	}
+	authorID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	commentReq := requests.CommentRequest{}
+	if err := bindAndValidate(c, &commentReq); err != nil {
+		return err
+	}
+
+	comment := &models.Comment{ID: id, TaskID: taskID, Body: *commentReq.Body}
+	if err := cc.CommentService.EditComment(ctx, comment, authorID); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, comment)
+}
+
+func (cc *CommentController) DeleteComment(c echo.Context) error {
+	ctx, cancel := cc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseCommentID(c)
+	if err != nil {
+		return err
+	}
+	authorID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	if err := cc.CommentService.DeleteComment(ctx, taskID, id, authorID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
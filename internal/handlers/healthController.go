@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/drivers"
+	"todo-api/internal/jobs"
+	"todo-api/internal/version"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+)
+
+// Number of schedule periods without a successful run after which a job
+// is reported as unhealthy
+const jobStaleIntervals = 3
+
+type HealthController struct {
+	DB             *sqlx.DB
+	MigrationsPath string
+	Scheduler      jobs.IScheduler
+	// The scheduler's clock, job staleness is measured with it
+	Clock        clock.Clock
+	Timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+type ReadinessResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+func NewHealthController(db *sqlx.DB, migrationsPath string, scheduler jobs.IScheduler, clock clock.Clock,
+	timeout time.Duration) *HealthController {
+	return &HealthController{
+		DB:             db,
+		MigrationsPath: migrationsPath,
+		Scheduler:      scheduler,
+		Clock:          clock,
+		Timeout:        timeout,
+	}
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop routing
+// new requests before the server drains
+func (hc *HealthController) SetShuttingDown() {
+	hc.shuttingDown.Store(true)
+}
+
+// Healthz reports that the process is alive
+func (hc *HealthController) Healthz(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the instance can serve traffic
+func (hc *HealthController) Readyz(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), hc.Timeout)
+	defer cancel()
+
+	checks := map[string]string{
+		"database":   hc.checkDatabase(ctx),
+		"migrations": hc.checkMigrations(ctx),
+	}
+	for _, status := range hc.Scheduler.Status() {
+		checks["job:"+status.Name] = checkJob(status, hc.Clock.Now())
+	}
+	if hc.shuttingDown.Load() {
+		checks["shutdown"] = "shutting down"
+	}
+
+	res := ReadinessResponse{Status: "ok", Checks: checks}
+	for _, check := range checks {
+		if check != "ok" {
+			res.Status = "unavailable"
+			return c.JSON(http.StatusServiceUnavailable, res)
+		}
+	}
+	return c.JSON(http.StatusOK, res)
+}
+
+// Version returns build information
+func (hc *HealthController) Version(c echo.Context) error {
+	return c.JSON(http.StatusOK, version.Get())
+}
+
+func (hc *HealthController) checkDatabase(ctx context.Context) string {
+	if err := hc.DB.PingContext(ctx); err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+func (hc *HealthController) checkMigrations(ctx context.Context) string {
+	current, latest, err := drivers.MigrationVersions(ctx, hc.DB, hc.MigrationsPath)
+	if err != nil {
+		return err.Error()
+	}
+	if current != latest {
+		return fmt.Sprintf("database at version %d, latest is %d", current, latest)
+	}
+	return "ok"
+}
+
+// checkJob fails a job whose last successful run is more than a few
+// periods ago, failing runs do not count
+func checkJob(status jobs.JobStatus, now time.Time) string {
+	if status.NextRun == nil {
+		return "ok"
+	}
+	// Before the first run count from when the scheduler started
+	last, succeeded := status.Started, status.Started
+	if status.LastEnd != nil {
+		last = *status.LastEnd
+	}
+	if status.LastSuccess != nil {
+		succeeded = *status.LastSuccess
+	}
+	period := status.NextRun.Sub(last)
+	since := now.Sub(succeeded)
+	if period > 0 && since > jobStaleIntervals*period {
+		return fmt.Sprintf("no successful run for %s", since.Round(time.Second))
+	}
+	return "ok"
+}
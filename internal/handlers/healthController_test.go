@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"todo-api/internal/clock/fakeclock"
+	"todo-api/internal/db/drivers"
+	"todo-api/internal/jobs"
+
+	"github.com/labstack/echo/v4"
+)
+
+type fakeScheduler struct {
+	jobs.IScheduler
+	status jobs.JobStatus
+}
+
+func (s *fakeScheduler) Status() []jobs.JobStatus {
+	return []jobs.JobStatus{s.status}
+}
+
+func TestReadyz(t *testing.T) {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "health.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+
+	scheduler := &fakeScheduler{}
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	hc := NewHealthController(db, "../db/migrations", scheduler, fakeclock.New(now), time.Second)
+	e := echo.New()
+	e.GET("/readyz", hc.Readyz)
+	readyz := func() int {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return rec.Code
+	}
+
+	// Runs every second, the last one ended at lastEnd and the last
+	// successful one at lastSuccess
+	setLastRun := func(lastEnd time.Time, lastSuccess time.Time) {
+		nextRun := lastEnd.Add(time.Second)
+		scheduler.status = jobs.JobStatus{Name: "overdue", LastEnd: &lastEnd, LastSuccess: &lastSuccess, NextRun: &nextRun}
+	}
+
+	for _, tt := range []struct {
+		name        string
+		lastEnd     time.Time
+		lastSuccess time.Time
+		want        int
+	}{
+		{"recent success", now, now, http.StatusOK},
+		{"one failure", now, now.Add(-time.Second), http.StatusOK},
+		{"stale job", now.Add(-time.Minute), now.Add(-time.Minute), http.StatusServiceUnavailable},
+		{"failing on every run", now, now.Add(-time.Minute), http.StatusServiceUnavailable},
+	} {
+		setLastRun(tt.lastEnd, tt.lastSuccess)
+		if code := readyz(); code != tt.want {
+			t.Errorf("Expected %d for %s, got %d", tt.want, tt.name, code)
+		}
+	}
+
+	// Before any successful run staleness counts from the start
+	nextRun := now.Add(time.Second)
+	scheduler.status = jobs.JobStatus{Name: "overdue", LastEnd: &now, NextRun: &nextRun, Started: now.Add(-time.Minute)}
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected a job that never succeeded to fail readiness, got %d", code)
+	}
+
+	setLastRun(now, now)
+	hc.SetShuttingDown()
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected readiness to fail during shutdown, got %d", code)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"todo-api/internal/jobs"
+
+	"github.com/labstack/echo/v4"
+)
+
+type JobController struct {
+	Scheduler jobs.IScheduler
+}
+
+func NewJobController(scheduler jobs.IScheduler) *JobController {
+	return &JobController{scheduler}
+}
+
+// GetJobs lists every background job with its last run status
+func (jc *JobController) GetJobs(c echo.Context) error {
+	return c.JSON(http.StatusOK, jc.Scheduler.Status())
+}
+
+// RunJob triggers a job immediately, it runs in the background
+func (jc *JobController) RunJob(c echo.Context) error {
+	name := c.Param("name")
+	if err := jc.Scheduler.RunNow(name); err != nil {
+		return err
+	}
+	status, err := jc.Scheduler.JobStatus(name)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusAccepted, status)
+}
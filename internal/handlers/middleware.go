@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"strconv"
+	"todo-api/internal/caller"
+	"todo-api/internal/problems"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog/log"
+)
+
+// ContextLogger attaches a logger tagged with the request ID to the request
+// context, so every log line written while serving the request carries it.
+// It must run after the request ID middleware.
+func ContextLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			requestID := c.Response().Header().Get(echo.HeaderXRequestID)
+			logger := log.Logger.With().Str("request_id", requestID).Logger()
+			c.SetRequest(req.WithContext(logger.WithContext(req.Context())))
+			return next(c)
+		}
+	}
+}
+
+// TimeZone resolves the caller's time zone from the X-Timezone header and
+// stores it in the request context. Without the header UTC is used.
+func TimeZone() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			name := c.Request().Header.Get(timezone.Header)
+			if name == "" {
+				return next(c)
+			}
+			loc, err := timezone.Load(name)
+			if err != nil {
+				return problems.InvalidField(timezone.Header, "unknown time zone "+name)
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(timezone.WithLocation(req.Context(), loc)))
+			return next(c)
+		}
+	}
+}
+
+// Caller reads the calling user's id from the X-User-ID header and stores
+// it in the request context. Requests without the header are anonymous.
+func Caller() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			value := c.Request().Header.Get(caller.Header)
+			if value == "" {
+				return next(c)
+			}
+			userID, err := strconv.Atoi(value)
+			if err != nil || userID < 1 {
+				return problems.InvalidField(caller.Header, "user id must be a positive integer")
+			}
+			req := c.Request()
+			c.SetRequest(req.WithContext(caller.WithUser(req.Context(), userID)))
+			return next(c)
+		}
+	}
+}
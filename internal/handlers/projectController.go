@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ProjectController struct {
+	ProjectService services.IProjectService
+	requestTimeout
+}
+
+func NewProjectController(projectService services.IProjectService, timeout time.Duration) *ProjectController {
+	pc := &ProjectController{ProjectService: projectService}
+	pc.SetTimeout(timeout)
+	return pc
+}
+
+// parseWorkflow converts the requested workflow and checks that it is
+// usable, nil keeps the default workflow
+func parseWorkflow(workflowReq *requests.WorkflowRequest) (*models.Workflow, error) {
+	if workflowReq == nil {
+		return nil, nil
+	}
+	workflow := &models.Workflow{}
+	for _, state := range workflowReq.States {
+		workflow.States = append(workflow.States, models.WorkflowState(state))
+	}
+	for _, transition := range workflowReq.Transitions {
+		workflow.Transitions = append(workflow.Transitions, models.Transition(transition))
+	}
+	if err := workflow.Validate(); err != nil {
+		return nil, problems.InvalidField("workflow", err.Error())
+	}
+	return workflow, nil
+}
+
+func (pc *ProjectController) CreateProject(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+
+	projectReq := requests.PostProjectRequest{}
+	if err := bindAndValidate(c, &projectReq); err != nil {
+		return err
+	}
+	workflow, err := parseWorkflow(projectReq.Workflow)
+	if err != nil {
+		return err
+	}
+	project := models.Project{
+		Key:      projectReq.Key,
+		Name:     projectReq.Name,
+		Workflow: workflow,
+	}
+	if err := pc.ProjectService.CreateProject(ctx, &project); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, project)
+}
+
+// parseProjectID reads the project id path parameter
+func parseProjectID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, problems.InvalidField("id", "project id must be an integer")
+	}
+	return id, nil
+}
+
+func (pc *ProjectController) GetProject(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+	id, err := parseProjectID(c)
+	if err != nil {
+		return err
+	}
+
+	project, err := pc.ProjectService.GetProject(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, project)
+}
+
+func (pc *ProjectController) GetProjects(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+	projects, err := pc.ProjectService.GetProjects(ctx)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, projects)
+}
+
+func (pc *ProjectController) GetMembers(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+	id, err := parseProjectID(c)
+	if err != nil {
+		return err
+	}
+
+	members, err := pc.ProjectService.GetMembers(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, members)
+}
+
+func (pc *ProjectController) AddMember(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+	id, err := parseProjectID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := parseUserParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := pc.ProjectService.AddMember(ctx, id, userID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func (pc *ProjectController) RemoveMember(c echo.Context) error {
+	ctx, cancel := pc.newContext(c)
+	defer cancel()
+	id, err := parseProjectID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := parseUserParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := pc.ProjectService.RemoveMember(ctx, id, userID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Longest sprint, keeps burndown series short
+const maxSprintDays = 366
+
+type SprintController struct {
+	SprintService services.ISprintService
+	requestTimeout
+}
+
+func NewSprintController(sprintService services.ISprintService, timeout time.Duration) *SprintController {
+	sc := &SprintController{SprintService: sprintService}
+	sc.SetTimeout(timeout)
+	return sc
+}
+
+// parseSprintID reads the sprint id path parameter
+func parseSprintID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, problems.InvalidField("id", "sprint id must be an integer")
+	}
+	return id, nil
+}
+
+func (sc *SprintController) CreateSprint(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+
+	sprintReq := requests.PostSprintRequest{}
+	if err := bindAndValidate(c, &sprintReq); err != nil {
+		return err
+	}
+	if err := checkZone(sprintReq.TimeZone); err != nil {
+		return err
+	}
+	start, err := time.Parse("2006-01-02", *sprintReq.StartDate)
+	if err != nil {
+		return problems.InvalidField("start_date", "start_date must be a YYYY-MM-DD date")
+	}
+	end, err := time.Parse("2006-01-02", *sprintReq.EndDate)
+	if err != nil {
+		return problems.InvalidField("end_date", "end_date must be a YYYY-MM-DD date")
+	}
+	if end.Before(start) {
+		return problems.InvalidField("end_date", "end_date must not be before start_date")
+	}
+	if end.After(start.AddDate(0, 0, maxSprintDays-1)) {
+		return problems.InvalidField("end_date", "a sprint lasts at most "+strconv.Itoa(maxSprintDays)+" days")
+	}
+
+	sprint := models.Sprint{
+		ProjectID: models.DefaultProjectID,
+		Name:      *sprintReq.Name,
+		StartDate: *sprintReq.StartDate,
+		EndDate:   *sprintReq.EndDate,
+		TimeZone:  timezone.FromContext(c.Request().Context()).String(),
+	}
+	if sprintReq.ProjectID != nil {
+		sprint.ProjectID = *sprintReq.ProjectID
+	}
+	if sprintReq.TimeZone != nil {
+		sprint.TimeZone = *sprintReq.TimeZone
+	}
+	err = sc.SprintService.CreateSprint(ctx, &sprint)
+	if errors.Is(err, repository.ErrProjectNotFound) {
+		return problems.InvalidField("project_id", "unknown project")
+	} else if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, sprint)
+}
+
+func (sc *SprintController) GetSprint(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+	id, err := parseSprintID(c)
+	if err != nil {
+		return err
+	}
+
+	sprint, err := sc.SprintService.GetSprint(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, sprint)
+}
+
+// GetSprints lists sprints, only those of one project with ?project=
+func (sc *SprintController) GetSprints(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+	var projectID *int
+	if value := c.QueryParam("project"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return problems.InvalidField("project", "project must be a project id")
+		}
+		projectID = &id
+	}
+
+	sprints, err := sc.SprintService.GetSprints(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, sprints)
+}
+
+func (sc *SprintController) Burndown(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+	id, err := parseSprintID(c)
+	if err != nil {
+		return err
+	}
+
+	burndown, err := sc.SprintService.Burndown(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, burndown)
+}
+
+func (sc *SprintController) SetTaskSprint(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	sprintReq := requests.SetSprintRequest{}
+	if err := bindAndValidate(c, &sprintReq); err != nil {
+		return err
+	}
+
+	task, err := sc.SprintService.SetTaskSprint(ctx, id, sprintReq.SprintID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/services"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+)
+
+type StatsController struct {
+	StatsService services.IStatsService
+	// Relative times of task queries count from the clock's now
+	Clock clock.Clock
+	requestTimeout
+}
+
+func NewStatsController(statsService services.IStatsService, timeout time.Duration) *StatsController {
+	sc := &StatsController{StatsService: statsService, Clock: clock.New()}
+	sc.SetTimeout(timeout)
+	return sc
+}
+
+// parseStatsQuery reads the task list filters, the date range and the
+// bucket size from the query string
+func parseStatsQuery(c echo.Context, now time.Time) (services.StatsQuery, error) {
+	loc := timezone.FromContext(c.Request().Context())
+	q := services.StatsQuery{Location: loc, Bucket: models.BucketDay}
+	tasks, err := parseTaskQuery(c, now)
+	if err != nil {
+		return q, err
+	}
+	q.Tasks = tasks
+
+	from, to := c.QueryParam("from"), c.QueryParam("to")
+	if from != "" || to != "" {
+		for _, param := range []struct {
+			name  string
+			value string
+			date  *time.Time
+		}{{"from", from, &q.From}, {"to", to, &q.To}} {
+			date, err := time.ParseInLocation("2006-01-02", param.value, loc)
+			if err != nil {
+				return q, problems.InvalidField(param.name, param.name+" must be a YYYY-MM-DD date, from and to go together")
+			}
+			*param.date = date
+		}
+		if q.To.Before(q.From) {
+			return q, problems.InvalidField("to", "to must not be before from")
+		}
+		if q.To.After(q.From.AddDate(0, 0, maxReportDays-1)) {
+			return q, problems.InvalidField("to", "stats cover at most "+strconv.Itoa(maxReportDays)+" days")
+		}
+	}
+
+	switch bucket := c.QueryParam("bucket"); bucket {
+	case "":
+	case models.BucketDay, models.BucketWeek, models.BucketMonth:
+		q.Bucket = bucket
+	default:
+		return q, problems.InvalidField("bucket", "bucket must be one of day, week, month")
+	}
+	return q, nil
+}
+
+// GetStats summarizes the tasks matching the list filters
+func (sc *StatsController) GetStats(c echo.Context) error {
+	ctx, cancel := sc.newContext(c)
+	defer cancel()
+	q, err := parseStatsQuery(c, sc.Clock.Now())
+	if err != nil {
+		return err
+	}
+	stats, err := sc.StatsService.GetStats(ctx, q)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, stats)
+}
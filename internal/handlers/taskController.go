@@ -1,203 +1,505 @@
 package handlers
 
 import (
-	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"todo-api/internal/caller"
+	"todo-api/internal/clock"
+	"todo-api/internal/dates"
 	"todo-api/internal/db/models"
 	"todo-api/internal/db/repository"
+	"todo-api/internal/problems"
+	"todo-api/internal/query"
 	"todo-api/internal/requests"
 	"todo-api/internal/services"
+	"todo-api/internal/timezone"
 
 	"github.com/labstack/echo/v4"
-	"github.com/rs/zerolog/log"
 )
 
 type TaskController struct {
 	TaskService services.ITaskService
-	Timeout     time.Duration
+	// Relative times of task queries and due date phrases count from the
+	// clock's now
+	Clock clock.Clock
+	requestTimeout
 }
 
 func NewTaskController(taskService services.ITaskService, timeout time.Duration) *TaskController {
-	return &TaskController{taskService, timeout}
+	tc := &TaskController{TaskService: taskService, Clock: clock.New()}
+	tc.SetTimeout(timeout)
+	return tc
+}
+
+// bindAndValidate binds the request body into req and validates it
+func bindAndValidate(c echo.Context, req interface{}) error {
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	return c.Validate(req)
+}
+
+// parseID reads the task id path parameter
+func parseID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, problems.InvalidField("id", "task id must be an integer")
+	}
+	return id, nil
+}
+
+// parseDueDate parses a due date given as an RFC 3339 date and time, or as
+// a YYYY-MM-DD date or a phrase such as next friday or tomorrow 5pm resolved
+// at now in loc. A day without a time makes an all-day task.
+func parseDueDate(dueDate string, loc *time.Location, now time.Time) (*time.Time, bool, error) {
+	if parsed, err := time.Parse(time.RFC3339, dueDate); err == nil {
+		return &parsed, false, nil
+	}
+	resolved, err := dates.Parse(dueDate, now, loc)
+	if err != nil {
+		return nil, false, problems.InvalidField("due_date", "due date must be a YYYY-MM-DD date, an RFC 3339 date "+
+			"and time, or a phrase such as tomorrow, next friday, in 3 days, end of month or tomorrow 5pm")
+	}
+	return &resolved.Time, resolved.AllDay, nil
+}
+
+// setDue fills the due date fields of task from the request. The task's
+// zone is the requested one, or the caller's zone, and phrases are resolved
+// in it at now.
+func setDue(c echo.Context, task *models.Task, dueDate *string, timeZone *string, now time.Time) error {
+	loc := timezone.FromContext(c.Request().Context())
+	if timeZone != nil {
+		var err error
+		loc, err = timezone.Load(*timeZone)
+		if err != nil {
+			return problems.InvalidField("time_zone", "unknown time zone "+*timeZone)
+		}
+	}
+	zone := loc.String()
+	task.TimeZone = &zone
+	if dueDate == nil {
+		return nil
+	}
+	parsed, allDay, err := parseDueDate(*dueDate, loc, now)
+	if err != nil {
+		return err
+	}
+	task.DueDate = parsed
+	task.DueAllDay = &allDay
+	return nil
+}
+
+// setReminders fills the reminder lead times of task from the request
+func setReminders(task *models.Task, reminders *[]string) error {
+	if reminders == nil {
+		return nil
+	}
+	offsets, err := models.ParseOffsets(*reminders)
+	if err != nil {
+		return problems.InvalidField("reminders", "reminders must be positive durations such as 24h or 90m")
+	}
+	task.Reminders = &offsets
+	return nil
+}
+
+// setPriority fills the priority of task from the request, the validator
+// has checked the name
+func setPriority(task *models.Task, priority *string) {
+	if priority == nil {
+		return
+	}
+	parsed, _ := models.ParsePriority(*priority)
+	task.Priority = &parsed
+}
+
+// compileQuery compiles a task query for the caller, in the caller's zone
+func compileQuery(c echo.Context, src string, now time.Time) (*query.Filter, error) {
+	parsed, err := query.Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	env := query.Env{Now: now, Location: timezone.FromContext(c.Request().Context())}
+	if userID, ok := caller.FromContext(c.Request().Context()); ok {
+		env.Caller = &userID
+	}
+	return query.Compile(parsed, env)
+}
+
+// sortKeys converts the sort order of a task query
+func sortKeys(keys []query.SortKey) []repository.SortKey {
+	sort := []repository.SortKey{}
+	for _, key := range keys {
+		sort = append(sort, repository.SortKey{Column: key.Column, Desc: key.Desc})
+	}
+	return sort
+}
+
+// parseTaskQuery reads the list filters and sort order from the query
+// string. A task query in q adds its conditions, and its order unless sort
+// is given.
+func parseTaskQuery(c echo.Context, now time.Time) (repository.TaskQuery, error) {
+	q := repository.TaskQuery{}
+	if value := c.QueryParam("project"); value != "" {
+		projectID, err := strconv.Atoi(value)
+		if err != nil {
+			return q, problems.InvalidField("project", "project must be a project id")
+		}
+		q.ProjectID = &projectID
+	}
+	if value := c.QueryParam("status"); value != "" {
+		for _, status := range strings.Split(value, ",") {
+			q.Statuses = append(q.Statuses, strings.TrimSpace(status))
+		}
+	}
+	if value := c.QueryParam("priority"); value != "" {
+		for _, name := range strings.Split(value, ",") {
+			priority, err := models.ParsePriority(strings.TrimSpace(name))
+			if err != nil {
+				return q, problems.InvalidField("priority", "priority must be a list of none, low, medium, high, urgent")
+			}
+			q.Priorities = append(q.Priorities, priority)
+		}
+	}
+	switch value := c.QueryParam("assignee"); value {
+	case "":
+	case "unassigned":
+		q.Unassigned = true
+	case "me":
+		userID, ok := caller.FromContext(c.Request().Context())
+		if !ok {
+			return q, problems.InvalidField("assignee", "assignee=me needs the "+caller.Header+" header")
+		}
+		q.Assignee = &userID
+	default:
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			return q, problems.InvalidField("assignee", "assignee must be me, unassigned or a user id")
+		}
+		q.Assignee = &userID
+	}
+	if value := c.QueryParam("sprint"); value != "" {
+		sprintID, err := strconv.Atoi(value)
+		if err != nil {
+			return q, problems.InvalidField("sprint", "sprint must be a sprint id")
+		}
+		q.SprintID = &sprintID
+	}
+	if value := c.QueryParam("sort"); value != "" {
+		sort, err := repository.ParseSort(value)
+		if err != nil {
+			return q, problems.InvalidField("sort", "sort must be a list of id, title, due_date, priority, overdue, position, each optionally prefixed with -")
+		}
+		q.Sort = sort
+	}
+	if src := c.QueryParam("q"); src != "" {
+		filter, err := compileQuery(c, src, now)
+		var queryErr *query.Error
+		if errors.As(err, &queryErr) {
+			return q, problems.InvalidQuery("q", queryErr)
+		} else if err != nil {
+			return q, err
+		}
+		q.Filter = filter
+		if len(q.Sort) == 0 {
+			q.Sort = sortKeys(filter.Sort)
+		}
+	}
+	return q, nil
+}
+
+// localize renders the task's times in the caller's zone. All-day due
+// dates stay in the task's zone so they keep their date.
+func localize(c echo.Context, task *models.Task) *models.Task {
+	loc := timezone.FromContext(c.Request().Context())
+	if task.DueDate != nil {
+		dueLoc := loc
+		if task.DueAllDay != nil && *task.DueAllDay && task.TimeZone != nil {
+			if taskLoc, err := timezone.Load(*task.TimeZone); err == nil {
+				dueLoc = taskLoc
+			}
+		}
+		dueDate := task.DueDate.In(dueLoc)
+		task.DueDate = &dueDate
+	}
+	if task.OverdueAt != nil {
+		overdueAt := task.OverdueAt.In(loc)
+		task.OverdueAt = &overdueAt
+	}
+	if task.CreatedAt != nil {
+		createdAt := task.CreatedAt.In(loc)
+		task.CreatedAt = &createdAt
+	}
+	if task.CompletedAt != nil {
+		completedAt := task.CompletedAt.In(loc)
+		task.CompletedAt = &completedAt
+	}
+	return task
 }
 
 func (tc *TaskController) CreateTask(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
 
 	taskReq := requests.PostTaskRequest{}
-	if err := c.Bind(&taskReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to bind task")
-		return c.JSON(http.StatusBadRequest, "failed to parse JSON")
-	}
-	if err := c.Validate(taskReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to validate task")
-		return c.JSON(http.StatusBadRequest, "invalid request")
+	if err := bindAndValidate(c, &taskReq); err != nil {
+		return err
 	}
 	// Create task
 	task := models.Task{
-		Title: taskReq.Title,
+		ProjectID:       taskReq.ProjectID,
+		Title:           taskReq.Title,
+		Description:     taskReq.Description,
+		EstimatePoints:  taskReq.EstimatePoints,
+		EstimateMinutes: taskReq.EstimateMinutes,
 	}
 	// Parse due date
-	if taskReq.DueDate == nil {
-		task.DueDate = nil
-	} else {
-		parsed, err := time.Parse("2006-01-02", *taskReq.DueDate)
-		if err != nil {
-			log.Logger.Error().Err(err).Msg("failed to parse due date")
-			return c.JSON(http.StatusBadRequest, "invalid due date")
-		}
-		task.DueDate = &parsed
+	if err := setDue(c, &task, taskReq.DueDate, taskReq.TimeZone, tc.Clock.Now()); err != nil {
+		return err
 	}
-	// Set description if present
-	if taskReq.Description == nil {
-		task.Description = nil
-	} else {
-		task.Description = taskReq.Description
+	if err := setReminders(&task, taskReq.Reminders); err != nil {
+		return err
 	}
+	setPriority(&task, taskReq.Priority)
 
-	if err := tc.TaskService.CreateTask(ctx, &task); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to create task")
-		if err == repository.ErrNoTitle {
-			return c.JSON(http.StatusBadRequest, "task title is required")
-		} else if err == repository.ErrAlreadyExists {
-			return c.JSON(http.StatusConflict, "task already exists")
-		}
-		return c.JSON(http.StatusInternalServerError, "failed to create task")
+	err := tc.TaskService.CreateTask(ctx, &task)
+	if errors.Is(err, repository.ErrProjectNotFound) {
+		return problems.InvalidField("project_id", "unknown project")
+	} else if err != nil {
+		return err
 	}
-	return c.JSON(http.StatusCreated, task)
+	return c.JSON(http.StatusCreated, localize(c, &task))
 }
 
 func (tc *TaskController) GetTask(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
 	// Retrieve task id
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("failed to parse task id")
-		return c.JSON(http.StatusBadRequest, "invalid task id")
+		return err
 	}
 
 	task, err := tc.TaskService.GetTask(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to get task with id %d", id)
-		if err == repository.ErrTaskNotFound {
-			return c.JSON(http.StatusNotFound, "task not found")
-		}
-		return c.JSON(http.StatusInternalServerError, "failed to get task")
+		return err
 	}
-	return c.JSON(http.StatusOK, task)
+	return c.JSON(http.StatusOK, localize(c, task))
 }
 
 func (tc *TaskController) GetTasks(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
-	tasks, err := tc.TaskService.GetTasks(ctx)
+	q, err := parseTaskQuery(c, tc.Clock.Now())
+	if err != nil {
+		return err
+	}
+	tasks, err := tc.TaskService.GetTasks(ctx, q)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("failed to get tasks")
-		return c.JSON(http.StatusInternalServerError, "failed to get tasks")
+		return err
+	}
+	for i := range tasks {
+		localize(c, &tasks[i])
 	}
 	return c.JSON(http.StatusOK, tasks)
 }
 
 func (tc *TaskController) UpdateTask(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
 	// Retrieve task id
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("failed to parse task id")
-		return c.JSON(http.StatusBadRequest, "invalid task id")
+		return err
 	}
 
 	taskReq := requests.PutTaskRequest{}
-	if err := c.Bind(&taskReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to bind task")
-		return c.JSON(http.StatusBadRequest, "failed to parse JSON")
-	}
-	if err := c.Validate(taskReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to validate task")
-		return c.JSON(http.StatusBadRequest, "invalid request")
+	if err := bindAndValidate(c, &taskReq); err != nil {
+		return err
 	}
 	task := models.Task{
-		ID:          &id,
-		Title:       taskReq.Title,
-		Description: taskReq.Description,
+		ID:              &id,
+		Title:           taskReq.Title,
+		Description:     taskReq.Description,
+		EstimatePoints:  taskReq.EstimatePoints,
+		EstimateMinutes: taskReq.EstimateMinutes,
 	}
 	// Parse due date
-    parsed, err := time.Parse("2006-01-02", *taskReq.DueDate)
-    if err != nil {
-        log.Logger.Error().Err(err).Msg("failed to parse due date")
-        return c.JSON(http.StatusBadRequest, "invalid due date")
-    }
-    task.DueDate = &parsed
+	if err := setDue(c, &task, taskReq.DueDate, taskReq.TimeZone, tc.Clock.Now()); err != nil {
+		return err
+	}
+	if err := setReminders(&task, taskReq.Reminders); err != nil {
+		return err
+	}
+	setPriority(&task, taskReq.Priority)
 	// Update task
 	err = tc.TaskService.UpdateTask(ctx, &task)
 	if err == repository.ErrTaskNotFound {
 		// Create new task with provided ID
 		if err := tc.TaskService.CreateTask(ctx, &task); err != nil {
-			log.Logger.Error().Err(err).Msg("failed to create task")
-			return c.JSON(http.StatusInternalServerError, "failed to create task")
+			return err
 		}
-		return c.JSON(http.StatusCreated, task)
+		return c.JSON(http.StatusCreated, localize(c, &task))
 	} else if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to update task with id %d", id)
-		return c.JSON(http.StatusInternalServerError, "failed to update task")
+		return err
 	}
 
-	return c.JSON(http.StatusOK, task)
+	return c.JSON(http.StatusOK, localize(c, &task))
+}
+
+// parsedDate is a previewed due date, rendered as a task would render it
+type parsedDate struct {
+	Input     string     `json:"input"`
+	DueDate   *time.Time `json:"due_date"`
+	DueAllDay *bool      `json:"due_all_day"`
+	TimeZone  *string    `json:"time_zone"`
+}
+
+// ParseDate resolves a due date the way creating a task would, so clients
+// can show what a phrase such as next friday means before saving it
+func (tc *TaskController) ParseDate(c echo.Context) error {
+	dateReq := requests.ParseDateRequest{}
+	if err := bindAndValidate(c, &dateReq); err != nil {
+		return err
+	}
+	task := models.Task{}
+	if err := setDue(c, &task, dateReq.DueDate, dateReq.TimeZone, tc.Clock.Now()); err != nil {
+		return err
+	}
+	localize(c, &task)
+	return c.JSON(http.StatusOK, parsedDate{
+		Input:     *dateReq.DueDate,
+		DueDate:   task.DueDate,
+		DueAllDay: task.DueAllDay,
+		TimeZone:  task.TimeZone,
+	})
 }
 
 func (tc *TaskController) SetCompleted(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
 	// Retrieve task id
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("failed to parse task id")
-		return c.JSON(http.StatusBadRequest, "invalid task id")
+		return err
 	}
 
 	completedReq := requests.PatchTaskRequest{}
-	if err := c.Bind(&completedReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to bind task")
-		return c.JSON(http.StatusBadRequest, "failed to parse JSON")
-	}
-	if err := c.Validate(completedReq); err != nil {
-		log.Logger.Error().Err(err).Msg("failed to validate task")
-		return c.JSON(http.StatusBadRequest, "invalid request")
+	if err := bindAndValidate(c, &completedReq); err != nil {
+		return err
 	}
 
 	taskUpdated, err := tc.TaskService.SetCompleted(ctx, id, completedReq.Completed)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to set completed task with id %d", id)
-		if err == repository.ErrTaskNotFound {
-			return c.JSON(http.StatusNotFound, "task not found")
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, taskUpdated))
+}
+
+// Transition moves a task to another state of its project's workflow
+func (tc *TaskController) Transition(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	// Retrieve task id
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	transitionReq := requests.TransitionTaskRequest{}
+	if err := bindAndValidate(c, &transitionReq); err != nil {
+		return err
+	}
+
+	task, err := tc.TaskService.Transition(ctx, id, *transitionReq.Status)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
+
+// SetTags replaces the tags of a task
+func (tc *TaskController) SetTags(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	tagsReq := requests.SetTagsRequest{}
+	if err := bindAndValidate(c, &tagsReq); err != nil {
+		return err
+	}
+
+	task, err := tc.TaskService.SetTags(ctx, id, *tagsReq.Tags)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
+
+// Move places a task on its project's board
+func (tc *TaskController) Move(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	// Retrieve task id
+	id, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	moveReq := requests.MoveTaskRequest{}
+	if err := bindAndValidate(c, &moveReq); err != nil {
+		return err
+	}
+	status := ""
+	if moveReq.Status != nil {
+		status = *moveReq.Status
+	}
+
+	task, err := tc.TaskService.Move(ctx, id, status, repository.Placement{After: moveReq.After, Before: moveReq.Before})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localize(c, task))
+}
+
+// GetBoard lists the tasks of a project in one column per workflow state
+func (tc *TaskController) GetBoard(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	projectID, err := strconv.Atoi(c.Param("project"))
+	if err != nil {
+		return problems.InvalidField("project", "project id must be an integer")
+	}
+
+	board, err := tc.TaskService.GetBoard(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	for i := range board.Columns {
+		for j := range board.Columns[i].Tasks {
+			localize(c, &board.Columns[i].Tasks[j])
 		}
-		return c.JSON(http.StatusInternalServerError, "failed to set completed")
 	}
-	return c.JSON(http.StatusOK, taskUpdated)
+	return c.JSON(http.StatusOK, board)
 }
 
 func (tc *TaskController) DeleteTask(c echo.Context) error {
-	ctx, cancel := context.WithTimeout(context.Background(), tc.Timeout)
+	ctx, cancel := tc.newContext(c)
 	defer cancel()
 	// Retrieve task id
-	id, err := strconv.Atoi(c.Param("id"))
+	id, err := parseID(c)
 	if err != nil {
-		log.Logger.Error().Err(err).Msg("failed to parse task id")
-		return c.JSON(http.StatusBadRequest, "invalid task id")
+		return err
 	}
 
 	err = tc.TaskService.DeleteTask(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to delete task with id %d", id)
-		if err == repository.ErrTaskNotFound {
-			return c.JSON(http.StatusNotFound, "task not found")
-		}
-		return c.JSON(http.StatusInternalServerError, "failed to delete task")
+		return err
 	}
 	return c.JSON(http.StatusOK, "task deleted")
 }
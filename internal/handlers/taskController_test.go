@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/clock/fakeclock"
+	"todo-api/internal/db/drivers"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// blockingRepo blocks in GetAll until the context is done and reports
+// the context error it observed
+type blockingRepo struct {
+	repository.ITaskRepo
+	entered chan struct{}
+	errs    chan error
+}
+
+func newBlockingRepo() *blockingRepo {
+	return &blockingRepo{entered: make(chan struct{}, 1), errs: make(chan error, 1)}
+}
+
+func (r *blockingRepo) GetAll(ctx context.Context, q repository.TaskQuery) ([]models.Task, error) {
+	r.entered <- struct{}{}
+	<-ctx.Done()
+	r.errs <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+func newServer(repo repository.ITaskRepo, timeout time.Duration) *echo.Echo {
+	taskController := NewTaskController(services.NewTaskService(repo, nil, nil, nil, clock.New()), timeout)
+	e := echo.New()
+	e.Validator = requests.NewValidator()
+	e.HTTPErrorHandler = problems.HTTPErrorHandler
+	e.Use(middleware.RequestID())
+	e.Use(ContextLogger())
+	e.Use(TimeZone())
+	e.Use(Caller())
+	e.GET("/tasks", taskController.GetTasks)
+	e.GET("/tasks/:id", taskController.GetTask)
+	e.POST("/tasks", taskController.CreateTask)
+	e.PUT("/tasks/:id", taskController.UpdateTask)
+	e.PUT("/tasks/:id/tags", taskController.SetTags)
+	e.POST("/parse-date", taskController.ParseDate)
+	commentController := NewCommentController(services.NewCommentService(nil, repo, nil, clock.New()), timeout)
+	e.GET("/tasks/:id/comments", commentController.GetComments)
+	e.POST("/tasks/:id/comments", commentController.CreateComment)
+	timeController := NewTimeController(services.NewTimeService(nil, repo, nil, clock.New()), timeout)
+	e.POST("/tasks/:id/timer/start", timeController.StartTimer)
+	e.POST("/tasks/:id/time-entries", timeController.CreateEntry)
+	e.GET("/reports/time", timeController.Report)
+	sprintController := NewSprintController(services.NewSprintService(nil, repo, nil, clock.New()), timeout)
+	e.POST("/sprints", sprintController.CreateSprint)
+	statsController := NewStatsController(services.NewStatsService(nil, clock.New(), 0), timeout)
+	e.GET("/stats", statsController.GetStats)
+	viewController := NewViewController(services.NewViewService(nil, repo, clock.New()), timeout)
+	e.POST("/views", viewController.CreateView)
+	return e
+}
+
+func TestClientCancellationReachesRepository(t *testing.T) {
+	repo := newBlockingRepo()
+	e := newServer(repo, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		e.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+
+	<-repo.entered
+	cancel()
+	select {
+	case err := <-repo.errs:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled in repository, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Repository did not observe client cancellation")
+	}
+	<-done
+}
+
+func TestTimeoutReachesRepository(t *testing.T) {
+	repo := newBlockingRepo()
+	e := newServer(repo, 20*time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tasks", nil))
+
+	if err := <-repo.errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded in repository, got %v", err)
+	}
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", rec.Code)
+	}
+}
+
+func TestRequestIDIsLoggedAndEchoed(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&buf)
+	defer func() { log.Logger = logger }()
+
+	repo := newBlockingRepo()
+	e := newServer(repo, 20*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-123")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(echo.HeaderXRequestID); got != "req-123" {
+		t.Errorf("Expected request id to be echoed, got %q", got)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatalf("Expected log lines")
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, `"request_id":"req-123"`) {
+			t.Errorf("Log line without request id: %s", line)
+		}
+	}
+}
+
+func TestProblemResponses(t *testing.T) {
+	e := newServer(newBlockingRepo(), time.Second)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		status int
+		field  string
+	}{
+		{"invalid id", http.MethodGet, "/tasks/abc", "", http.StatusBadRequest, "id"},
+		{"missing title", http.MethodPost, "/tasks", `{"description":"no title"}`, http.StatusBadRequest, "title"},
+		{"put without due date", http.MethodPut, "/tasks/1", `{"title":"a","description":"b"}`, http.StatusBadRequest, "due_date"},
+		{"invalid due date", http.MethodPost, "/tasks", `{"title":"a","due_date":"tomorrow-ish"}`, http.StatusBadRequest, "due_date"},
+		{"unknown due date phrase", http.MethodPost, "/parse-date", `{"due_date":"someday"}`, http.StatusBadRequest, "due_date"},
+		{"missing due date phrase", http.MethodPost, "/parse-date", `{"time_zone":"Asia/Tokyo"}`, http.StatusBadRequest, "due_date"},
+		{"malformed JSON", http.MethodPost, "/tasks", `{"title":`, http.StatusBadRequest, ""},
+		{"unknown priority", http.MethodPost, "/tasks", `{"title":"a","priority":"asap"}`, http.StatusBadRequest, "priority"},
+		{"unknown priority filter", http.MethodGet, "/tasks?priority=asap", "", http.StatusBadRequest, "priority"},
+		{"unknown sort column", http.MethodGet, "/tasks?sort=-description", "", http.StatusBadRequest, "sort"},
+		{"assigned to me without caller", http.MethodGet, "/tasks?assignee=me", "", http.StatusBadRequest, "assignee"},
+		{"unknown assignee filter", http.MethodGet, "/tasks?assignee=someone", "", http.StatusBadRequest, "assignee"},
+		{"comment without caller", http.MethodPost, "/tasks/1/comments", `{"body":"hi"}`, http.StatusUnauthorized, ""},
+		{"invalid comment cursor", http.MethodGet, "/tasks/1/comments?cursor=%21", "", http.StatusBadRequest, "cursor"},
+		{"comment limit too large", http.MethodGet, "/tasks/1/comments?limit=500", "", http.StatusBadRequest, "limit"},
+		{"timer without caller", http.MethodPost, "/tasks/1/timer/start", "", http.StatusUnauthorized, ""},
+		{"report without range", http.MethodGet, "/reports/time?to=2024-11-30", "", http.StatusBadRequest, "from"},
+		{"report ending before it starts", http.MethodGet, "/reports/time?from=2024-11-30&to=2024-11-01", "", http.StatusBadRequest, "to"},
+		{"report over a year", http.MethodGet, "/reports/time?from=2024-01-01&to=2025-01-01", "", http.StatusBadRequest, "to"},
+		{"unknown report group", http.MethodGet, "/reports/time?from=2024-11-01&to=2024-11-30&group=week", "", http.StatusBadRequest, "group"},
+		{"unknown report format", http.MethodGet, "/reports/time?from=2024-11-01&to=2024-11-30&format=xml", "", http.StatusBadRequest, "format"},
+		{"negative estimate", http.MethodPost, "/tasks", `{"title":"a","estimate_points":-1}`, http.StatusBadRequest, "estimate_points"},
+		{"invalid sprint date", http.MethodPost, "/sprints", `{"project_id":1,"name":"S1","start_date":"18.11.2024","end_date":"2024-11-22"}`, http.StatusBadRequest, "start_date"},
+		{"sprint ending before it starts", http.MethodPost, "/sprints", `{"project_id":1,"name":"S1","start_date":"2024-11-22","end_date":"2024-11-18"}`, http.StatusBadRequest, "end_date"},
+		{"stats from without to", http.MethodGet, "/stats?from=2024-11-01", "", http.StatusBadRequest, "to"},
+		{"stats over a year", http.MethodGet, "/stats?from=2024-01-01&to=2025-01-01", "", http.StatusBadRequest, "to"},
+		{"unknown stats bucket", http.MethodGet, "/stats?bucket=year", "", http.StatusBadRequest, "bucket"},
+		{"unknown stats filter", http.MethodGet, "/stats?priority=asap", "", http.StatusBadRequest, "priority"},
+		{"invalid tag", http.MethodPut, "/tasks/1/tags", `{"tags":["no spaces"]}`, http.StatusBadRequest, "tags"},
+		{"tags missing", http.MethodPut, "/tasks/1/tags", `{}`, http.StatusBadRequest, "tags"},
+		{"unknown query field", http.MethodGet, "/tasks?q=label:bug", "", http.StatusBadRequest, "q"},
+		{"unterminated query string", http.MethodGet, `/tasks?q=%22login`, "", http.StatusBadRequest, "q"},
+		{"view with invalid query", http.MethodPost, "/views", `{"name":"soon","query":"due:7d"}`, http.StatusBadRequest, "query"},
+		{"view without name", http.MethodPost, "/views", `{"query":"is:open"}`, http.StatusBadRequest, "name"},
+		{"unknown sprint zone", http.MethodPost, "/sprints", `{"project_id":1,"name":"S1","start_date":"2024-11-18","end_date":"2024-11-22","time_zone":"Mars/Olympus"}`, http.StatusBadRequest, "time_zone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if ct := rec.Header().Get(echo.HeaderContentType); ct != problems.ContentType {
+				t.Errorf("Expected content type %s, got %s", problems.ContentType, ct)
+			}
+			p := problems.Problem{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+				t.Fatalf("Error decoding problem: %v", err)
+			}
+			if p.Status != tt.status || p.Title == "" || p.Type == "" || p.Instance != req.URL.Path {
+				t.Errorf("Incomplete problem: %+v", p)
+			}
+			if tt.field != "" && (len(p.Errors) != 1 || p.Errors[0].Field != tt.field) {
+				t.Errorf("Expected a single error for field %s, got %+v", tt.field, p.Errors)
+			}
+		})
+	}
+}
+
+func TestDueDateRenderedInCallerZone(t *testing.T) {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "tasks.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+	e := newServer(repository.NewTaskRepo(db), time.Second)
+
+	do := func(method string, target string, body string, zone string) map[string]interface{} {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Timezone", zone)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		res := map[string]interface{}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Error decoding response %s: %v", rec.Body.String(), err)
+		}
+		return res
+	}
+
+	created := do(http.MethodPost, "/tasks", `{"title":"standup","due_date":"2024-11-20"}`, "Asia/Tokyo")
+	if created["due_date"] != "2024-11-20T00:00:00+09:00" || created["time_zone"] != "Asia/Tokyo" || created["due_all_day"] != true {
+		t.Errorf("Unexpected all-day task: %v", created)
+	}
+	if created["overdue_at"] != "2024-11-21T00:00:00+09:00" {
+		t.Errorf("Expected overdue at end of day in Tokyo, got %v", created["overdue_at"])
+	}
+
+	timed := do(http.MethodPost, "/tasks", `{"title":"call","due_date":"2024-11-20T17:00:00+09:00"}`, "Asia/Tokyo")
+	got := do(http.MethodGet, fmt.Sprintf("/tasks/%v", timed["id"]), "", "Europe/Berlin")
+	if got["due_date"] != "2024-11-20T09:00:00+01:00" || got["due_all_day"] != false {
+		t.Errorf("Expected due date in caller zone, got %v", got)
+	}
+
+	invalid := do(http.MethodGet, "/tasks", "", "Mars/Olympus")
+	if invalid["status"] != float64(http.StatusBadRequest) {
+		t.Errorf("Expected unknown zone to be rejected, got %v", invalid)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "tasks.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+	// A Wednesday, already evening in Tokyo
+	clock := fakeclock.New(time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC))
+	taskController := NewTaskController(services.NewTaskService(repository.NewTaskRepo(db), nil, nil, nil, clock),
+		time.Second)
+	taskController.Clock = clock
+	e := echo.New()
+	e.Validator = requests.NewValidator()
+	e.HTTPErrorHandler = problems.HTTPErrorHandler
+	e.Use(TimeZone())
+	e.POST("/tasks", taskController.CreateTask)
+	e.POST("/parse-date", taskController.ParseDate)
+
+	do := func(target string, body string) map[string]interface{} {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-Timezone", "Asia/Tokyo")
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		res := map[string]interface{}{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("Error decoding response %s: %v", rec.Body.String(), err)
+		}
+		return res
+	}
+
+	tests := []struct {
+		body    string
+		dueDate string
+		allDay  bool
+	}{
+		{`{"due_date":"tomorrow"}`, "2024-11-21T00:00:00+09:00", true},
+		{`{"due_date":"tomorrow 5pm"}`, "2024-11-21T17:00:00+09:00", false},
+		{`{"due_date":"next friday"}`, "2024-11-29T00:00:00+09:00", true},
+		{`{"due_date":"in 3 days"}`, "2024-11-23T00:00:00+09:00", true},
+		{`{"due_date":"end of month"}`, "2024-11-30T00:00:00+09:00", true},
+		// Days are in the requested zone, the time is shown in the caller's
+		{`{"due_date":"tomorrow 9am","time_zone":"Europe/Berlin"}`, "2024-11-21T17:00:00+09:00", false},
+	}
+	for _, tt := range tests {
+		got := do("/parse-date", tt.body)
+		if got["due_date"] != tt.dueDate || got["due_all_day"] != tt.allDay {
+			t.Errorf("%s: expected %s (all day %t), got %v", tt.body, tt.dueDate, tt.allDay, got)
+		}
+	}
+
+	created := do("/tasks", `{"title":"report","due_date":"Next Friday"}`)
+	preview := do("/parse-date", `{"due_date":"Next Friday"}`)
+	if created["due_date"] != preview["due_date"] || created["due_all_day"] != true || preview["input"] != "Next Friday" {
+		t.Errorf("Expected the task to get the previewed due date %v, got %v", preview, created)
+	}
+}
+
+func TestUsersHideEmail(t *testing.T) {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "users.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	defer db.Close()
+	uc := NewUserController(services.NewUserService(repository.NewUserRepo(db)), time.Second)
+	e := echo.New()
+	e.Validator = requests.NewValidator()
+	e.POST("/users", uc.CreateUser)
+	e.GET("/users", uc.GetUsers)
+	e.GET("/users/:id", uc.GetUser)
+
+	req := httptest.NewRequest(http.MethodPost, "/users",
+		strings.NewReader(`{"username":"ada","name":"Ada Lovelace","email":"ada@example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	for _, req := range []*http.Request{req, httptest.NewRequest(http.MethodGet, "/users", nil),
+		httptest.NewRequest(http.MethodGet, "/users/1", nil)} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code >= 300 || !strings.Contains(rec.Body.String(), "ada") {
+			t.Fatalf("Unexpected response to %s %s: %d %s", req.Method, req.URL, rec.Code, rec.Body.String())
+		}
+		if strings.Contains(rec.Body.String(), "example.com") {
+			t.Errorf("Expected %s %s to leave out the email, got %s", req.Method, req.URL, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"todo-api/internal/caller"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Longest range a time report covers
+const maxReportDays = 366
+
+type TimeController struct {
+	TimeService services.ITimeService
+	requestTimeout
+}
+
+func NewTimeController(timeService services.ITimeService, timeout time.Duration) *TimeController {
+	tc := &TimeController{TimeService: timeService}
+	tc.SetTimeout(timeout)
+	return tc
+}
+
+func parseEntryID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("entry_id"))
+	if err != nil {
+		return 0, problems.InvalidField("entry_id", "entry id must be an integer")
+	}
+	return id, nil
+}
+
+// localizeEntry renders the entry's times in the caller's zone
+func localizeEntry(c echo.Context, entry *models.TimeEntry) *models.TimeEntry {
+	loc := timezone.FromContext(c.Request().Context())
+	entry.StartedAt = entry.StartedAt.In(loc)
+	if entry.EndedAt != nil {
+		endedAt := entry.EndedAt.In(loc)
+		entry.EndedAt = &endedAt
+	}
+	return entry
+}
+
+// parseEntry reads the times and note of a time entry request
+func parseEntry(entryReq requests.TimeEntryRequest, entry *models.TimeEntry) error {
+	startedAt, err := time.Parse(time.RFC3339, *entryReq.StartedAt)
+	if err != nil {
+		return problems.InvalidField("started_at", "started_at must be an RFC 3339 date and time")
+	}
+	entry.StartedAt = startedAt
+	if entryReq.EndedAt != nil {
+		endedAt, err := time.Parse(time.RFC3339, *entryReq.EndedAt)
+		if err != nil {
+			return problems.InvalidField("ended_at", "ended_at must be an RFC 3339 date and time")
+		}
+		entry.EndedAt = &endedAt
+	}
+	if entryReq.Note != nil {
+		entry.Note = *entryReq.Note
+	}
+	return nil
+}
+
+func (tc *TimeController) StartTimer(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	timerReq := requests.StartTimerRequest{}
+	if err := bindAndValidate(c, &timerReq); err != nil {
+		return err
+	}
+	note := ""
+	if timerReq.Note != nil {
+		note = *timerReq.Note
+	}
+
+	entry, err := tc.TimeService.StartTimer(ctx, taskID, userID, note)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, localizeEntry(c, entry))
+}
+
+func (tc *TimeController) StopTimer(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	entry, err := tc.TimeService.StopTimer(ctx, taskID, userID)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localizeEntry(c, entry))
+}
+
+func (tc *TimeController) GetEntries(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+
+	entries, err := tc.TimeService.GetEntries(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		localizeEntry(c, &entries[i])
+	}
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (tc *TimeController) CreateEntry(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	entryReq := requests.TimeEntryRequest{}
+	if err := bindAndValidate(c, &entryReq); err != nil {
+		return err
+	}
+	if entryReq.EndedAt == nil {
+		return problems.InvalidField("ended_at", "ended_at is required, start a timer to track time as it passes")
+	}
+	entry := &models.TimeEntry{TaskID: taskID, UserID: userID}
+	if err := parseEntry(entryReq, entry); err != nil {
+		return err
+	}
+
+	if err := tc.TimeService.CreateEntry(ctx, entry); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, localizeEntry(c, entry))
+}
+
+func (tc *TimeController) UpdateEntry(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseEntryID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	entryReq := requests.TimeEntryRequest{}
+	if err := bindAndValidate(c, &entryReq); err != nil {
+		return err
+	}
+	entry := &models.TimeEntry{ID: id, TaskID: taskID}
+	if err := parseEntry(entryReq, entry); err != nil {
+		return err
+	}
+
+	if err := tc.TimeService.UpdateEntry(ctx, entry, userID); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, localizeEntry(c, entry))
+}
+
+func (tc *TimeController) DeleteEntry(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	taskID, err := parseID(c)
+	if err != nil {
+		return err
+	}
+	id, err := parseEntryID(c)
+	if err != nil {
+		return err
+	}
+	userID, err := requireCaller(c)
+	if err != nil {
+		return err
+	}
+
+	if err := tc.TimeService.DeleteEntry(ctx, taskID, id, userID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// parseReportQuery reads the from and to dates, both inclusive and in the
+// caller's zone, the grouping and the user filter
+func parseReportQuery(c echo.Context) (services.TimeReportQuery, error) {
+	loc := timezone.FromContext(c.Request().Context())
+	q := services.TimeReportQuery{Location: loc, Group: models.GroupByTask}
+	for _, param := range []struct {
+		name string
+		date *time.Time
+	}{{"from", &q.From}, {"to", &q.To}} {
+		date, err := time.ParseInLocation("2006-01-02", c.QueryParam(param.name), loc)
+		if err != nil {
+			return q, problems.InvalidField(param.name, param.name+" must be a YYYY-MM-DD date")
+		}
+		*param.date = date
+	}
+	if q.To.Before(q.From) {
+		return q, problems.InvalidField("to", "to must not be before from")
+	}
+	if q.To.After(q.From.AddDate(0, 0, maxReportDays-1)) {
+		return q, problems.InvalidField("to", "a report covers at most "+strconv.Itoa(maxReportDays)+" days")
+	}
+
+	switch group := c.QueryParam("group"); group {
+	case "":
+	case models.GroupByTask, models.GroupByProject, models.GroupByDay:
+		q.Group = group
+	default:
+		return q, problems.InvalidField("group", "group must be one of task, project, day")
+	}
+
+	switch value := c.QueryParam("user"); value {
+	case "":
+	case "me":
+		userID, ok := caller.FromContext(c.Request().Context())
+		if !ok {
+			return q, problems.InvalidField("user", "user=me needs the "+caller.Header+" header")
+		}
+		q.UserID = &userID
+	default:
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			return q, problems.InvalidField("user", "user must be me or a user id")
+		}
+		q.UserID = &userID
+	}
+	return q, nil
+}
+
+// wantsCSV reports whether the client asked for CSV with ?format=csv or the
+// Accept header
+func wantsCSV(c echo.Context) (bool, error) {
+	switch format := c.QueryParam("format"); format {
+	case "csv":
+		return true, nil
+	case "json":
+		return false, nil
+	case "":
+		return strings.Contains(c.Request().Header.Get(echo.HeaderAccept), "text/csv"), nil
+	default:
+		return false, problems.InvalidField("format", "format must be json or csv")
+	}
+}
+
+// csvCell keeps spreadsheets from running user text as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func hours(seconds int64) string {
+	return strconv.FormatFloat(float64(seconds)/3600, 'f', 2, 64)
+}
+
+// writeReportCSV writes a row per task, project or day and a closing total
+// row
+func writeReportCSV(c echo.Context, report *models.TimeReport) error {
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	res.Header().Set(echo.HeaderContentDisposition,
+		`attachment; filename="time-`+report.Group+`-`+report.From+`-`+report.To+`.csv"`)
+	res.WriteHeader(http.StatusOK)
+
+	w := csv.NewWriter(res)
+	header := map[string][]string{
+		models.GroupByTask:    {"task_id", "title", "seconds", "hours"},
+		models.GroupByProject: {"project_id", "name", "seconds", "hours"},
+		models.GroupByDay:     {"date", "seconds", "hours"},
+	}[report.Group]
+	records := [][]string{header}
+	for _, row := range report.Rows {
+		record := []string{row.Key, csvCell(row.Name), strconv.FormatInt(row.Seconds, 10), hours(row.Seconds)}
+		if report.Group == models.GroupByDay {
+			record = append(record[:1], record[2:]...)
+		}
+		records = append(records, record)
+	}
+	total := []string{"total", "", strconv.FormatInt(report.Total, 10), hours(report.Total)}
+	if report.Group == models.GroupByDay {
+		total = append(total[:1], total[2:]...)
+	}
+	return w.WriteAll(append(records, total))
+}
+
+// Report sums finished time entries per task, project or day, as JSON or
+// CSV
+func (tc *TimeController) Report(c echo.Context) error {
+	ctx, cancel := tc.newContext(c)
+	defer cancel()
+	q, err := parseReportQuery(c)
+	if err != nil {
+		return err
+	}
+	asCSV, err := wantsCSV(c)
+	if err != nil {
+		return err
+	}
+
+	report, err := tc.TimeService.Report(ctx, q)
+	if err != nil {
+		return err
+	}
+	if asCSV {
+		return writeReportCSV(c, report)
+	}
+	return c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// requestTimeout is embedded by controllers to bound the work of a request
+type requestTimeout struct {
+	timeout atomic.Int64
+}
+
+// SetTimeout changes the per-request timeout, it is safe to call while
+// serving requests
+func (rt *requestTimeout) SetTimeout(timeout time.Duration) {
+	rt.timeout.Store(int64(timeout))
+}
+
+// newContext derives a context with the controller timeout from the request
+// context, so work stops when the client goes away and request-scoped
+// values (span, logger, request ID) reach the service layer
+func (rt *requestTimeout) newContext(c echo.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(c.Request().Context(), time.Duration(rt.timeout.Load()))
+}
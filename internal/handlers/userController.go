@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+)
+
+type UserController struct {
+	UserService services.IUserService
+	requestTimeout
+}
+
+func NewUserController(userService services.IUserService, timeout time.Duration) *UserController {
+	uc := &UserController{UserService: userService}
+	uc.SetTimeout(timeout)
+	return uc
+}
+
+// parseUserID reads the user id path parameter
+func parseUserID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, problems.InvalidField("id", "user id must be an integer")
+	}
+	return id, nil
+}
+
+// checkZone rejects unknown time zone names
+func checkZone(timeZone *string) error {
+	if timeZone == nil {
+		return nil
+	}
+	if _, err := timezone.Load(*timeZone); err != nil {
+		return problems.InvalidField("time_zone", "unknown time zone "+*timeZone)
+	}
+	return nil
+}
+
+func (uc *UserController) CreateUser(c echo.Context) error {
+	ctx, cancel := uc.newContext(c)
+	defer cancel()
+
+	userReq := requests.PostUserRequest{}
+	if err := bindAndValidate(c, &userReq); err != nil {
+		return err
+	}
+	if err := checkZone(userReq.TimeZone); err != nil {
+		return err
+	}
+	user := models.User{
+		Username: userReq.Username,
+		Name:     userReq.Name,
+		Email:    userReq.Email,
+		TimeZone: userReq.TimeZone,
+	}
+	if err := uc.UserService.CreateUser(ctx, &user); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, user)
+}
+
+func (uc *UserController) GetUser(c echo.Context) error {
+	ctx, cancel := uc.newContext(c)
+	defer cancel()
+	id, err := parseUserID(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := uc.UserService.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
+
+func (uc *UserController) GetUsers(c echo.Context) error {
+	ctx, cancel := uc.newContext(c)
+	defer cancel()
+	users, err := uc.UserService.GetUsers(ctx)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, users)
+}
+
+// UpdateDigest sets the daily digest opt-in, send time and zone
+func (uc *UserController) UpdateDigest(c echo.Context) error {
+	ctx, cancel := uc.newContext(c)
+	defer cancel()
+	id, err := parseUserID(c)
+	if err != nil {
+		return err
+	}
+
+	digestReq := requests.PutDigestRequest{}
+	if err := bindAndValidate(c, &digestReq); err != nil {
+		return err
+	}
+	if err := checkZone(digestReq.TimeZone); err != nil {
+		return err
+	}
+	user := models.User{
+		ID:            &id,
+		DigestEnabled: digestReq.Enabled,
+		DigestTime:    digestReq.Time,
+		TimeZone:      digestReq.TimeZone,
+	}
+	if err := uc.UserService.UpdateDigest(ctx, &user); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"todo-api/internal/caller"
+	"todo-api/internal/db/models"
+	"todo-api/internal/problems"
+	"todo-api/internal/requests"
+	"todo-api/internal/services"
+	"todo-api/internal/timezone"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ViewController struct {
+	ViewService services.IViewService
+	requestTimeout
+}
+
+func NewViewController(viewService services.IViewService, timeout time.Duration) *ViewController {
+	vc := &ViewController{ViewService: viewService}
+	vc.SetTimeout(timeout)
+	return vc
+}
+
+// parseViewID reads the view id path parameter
+func parseViewID(c echo.Context) (int, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return 0, problems.InvalidField("id", "view id must be an integer")
+	}
+	return id, nil
+}
+
+func (vc *ViewController) CreateView(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+
+	viewReq := requests.ViewRequest{}
+	if err := bindAndValidate(c, &viewReq); err != nil {
+		return err
+	}
+
+	view := models.SavedView{Name: *viewReq.Name, Query: *viewReq.Query}
+	if userID, ok := caller.FromContext(c.Request().Context()); ok {
+		view.CreatedBy = &userID
+	}
+	if err := vc.ViewService.CreateView(ctx, &view); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, view)
+}
+
+func (vc *ViewController) UpdateView(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+	id, err := parseViewID(c)
+	if err != nil {
+		return err
+	}
+
+	viewReq := requests.ViewRequest{}
+	if err := bindAndValidate(c, &viewReq); err != nil {
+		return err
+	}
+
+	view := models.SavedView{ID: id, Name: *viewReq.Name, Query: *viewReq.Query}
+	if err := vc.ViewService.UpdateView(ctx, &view); err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+func (vc *ViewController) GetView(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+	id, err := parseViewID(c)
+	if err != nil {
+		return err
+	}
+
+	view, err := vc.ViewService.GetView(ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, view)
+}
+
+func (vc *ViewController) GetViews(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+
+	views, err := vc.ViewService.GetViews(ctx)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, views)
+}
+
+func (vc *ViewController) DeleteView(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+	id, err := parseViewID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := vc.ViewService.DeleteView(ctx, id); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetViewTasks runs a saved view in the caller's time zone, assignee:me
+// needs the caller header
+func (vc *ViewController) GetViewTasks(c echo.Context) error {
+	ctx, cancel := vc.newContext(c)
+	defer cancel()
+	id, err := parseViewID(c)
+	if err != nil {
+		return err
+	}
+
+	var callerID *int
+	if userID, ok := caller.FromContext(c.Request().Context()); ok {
+		callerID = &userID
+	}
+	tasks, err := vc.ViewService.GetViewTasks(ctx, id, timezone.FromContext(c.Request().Context()), callerID)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		localize(c, &tasks[i])
+	}
+	return c.JSON(http.StatusOK, tasks)
+}
@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/services"
+)
+
+const DigestJobName = "digest"
+
+// NewDigestJob returns the job that mails daily digests once each user's
+// send time has passed
+func NewDigestJob(digestService services.IDigestService, interval time.Duration) Job {
+	return Job{
+		Name:     DigestJobName,
+		Schedule: Every(interval),
+		Timeout:  interval,
+		Run: func(ctx context.Context) error {
+			_, err := digestService.SendDue(ctx)
+			return err
+		},
+	}
+}
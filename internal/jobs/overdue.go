@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/services"
+)
+
+const OverdueJobName = "overdue"
+
+// NewOverdueJob returns the job that flags tasks past their due date. When
+// escalateAfter is positive it also raises the priority of tasks overdue
+// for longer than that.
+func NewOverdueJob(taskService services.ITaskService, interval time.Duration, jitter time.Duration, escalateAfter time.Duration) Job {
+	return Job{
+		Name:     OverdueJobName,
+		Schedule: Every(interval),
+		Jitter:   jitter,
+		Timeout:  interval,
+		Run: func(ctx context.Context) error {
+			if _, err := taskService.UpdateOverdue(ctx); err != nil {
+				return err
+			}
+			if escalateAfter > 0 {
+				_, err := taskService.EscalateOverdue(ctx, escalateAfter)
+				return err
+			}
+			return nil
+		},
+	}
+}
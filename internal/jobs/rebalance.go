@@ -0,0 +1,23 @@
+package jobs
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/services"
+)
+
+const RebalanceJobName = "rebalance"
+
+// NewRebalanceJob returns the job that shortens board positions once they
+// grow longer than maxLength
+func NewRebalanceJob(taskService services.ITaskService, interval time.Duration, maxLength int) Job {
+	return Job{
+		Name:     RebalanceJobName,
+		Schedule: Every(interval),
+		Timeout:  interval,
+		Run: func(ctx context.Context) error {
+			_, err := taskService.Rebalance(ctx, maxLength)
+			return err
+		},
+	}
+}
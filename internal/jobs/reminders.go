@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/services"
+)
+
+const (
+	ReminderJobName     = "reminders"
+	NotificationJobName = "notifications"
+)
+
+// NewReminderJob returns the job that writes due reminders to the
+// notification outbox
+func NewReminderJob(reminderService services.IReminderService, interval time.Duration) Job {
+	return Job{
+		Name:     ReminderJobName,
+		Schedule: Every(interval),
+		Timeout:  interval,
+		Run: func(ctx context.Context) error {
+			_, err := reminderService.Enqueue(ctx)
+			return err
+		},
+	}
+}
+
+// NewNotificationJob returns the job that delivers the notification outbox
+func NewNotificationJob(reminderService services.IReminderService, interval time.Duration) Job {
+	return Job{
+		Name:     NotificationJobName,
+		Schedule: Every(interval),
+		Timeout:  interval,
+		Run: func(ctx context.Context) error {
+			_, err := reminderService.Deliver(ctx)
+			return err
+		},
+	}
+}
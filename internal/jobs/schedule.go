@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Schedule returns the next time a job should run after the given time
+type Schedule interface {
+	Next(after time.Time) time.Time
+	String() string
+}
+
+type everySchedule struct {
+	interval time.Duration
+}
+
+// Every runs a job at a fixed interval
+func Every(interval time.Duration) Schedule {
+	return everySchedule{interval}
+}
+
+func (s everySchedule) Next(after time.Time) time.Time {
+	return after.Add(s.interval)
+}
+
+func (s everySchedule) String() string {
+	return "every " + s.interval.String()
+}
+
+type cronSchedule struct {
+	expr     string
+	schedule cron.Schedule
+}
+
+// Cron parses a standard five field cron expression, e.g. "0 7 * * 1-5".
+// Descriptors such as "@daily" and "CRON_TZ=Europe/Berlin" prefixes are
+// accepted too.
+func Cron(expr string) (Schedule, error) {
+	schedule, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, err
+	}
+	return cronSchedule{expr, schedule}, nil
+}
+
+func (s cronSchedule) Next(after time.Time) time.Time {
+	return s.schedule.Next(after)
+}
+
+func (s cronSchedule) String() string {
+	return "cron " + s.expr
+}
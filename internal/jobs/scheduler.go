@@ -0,0 +1,307 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = telemetry.Tracer("todo-api/internal/jobs")
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is already running")
+	ErrJobExists   = errors.New("job with given name already registered")
+	ErrNotStarted  = errors.New("scheduler is not running")
+)
+
+// Job is a named unit of background work
+type Job struct {
+	Name     string
+	Schedule Schedule
+	// Random delay of up to Jitter is added to every scheduled run
+	Jitter time.Duration
+	// Run is cancelled after Timeout, zero means no timeout
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+// JobStatus is a snapshot of a job's state
+type JobStatus struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule"`
+	Running   bool       `json:"running"`
+	NextRun   *time.Time `json:"next_run"`
+	LastStart *time.Time `json:"last_start"`
+	LastEnd   *time.Time `json:"last_end"`
+	// End of the last run that did not fail
+	LastSuccess  *time.Time `json:"last_success"`
+	LastDuration string     `json:"last_duration,omitempty"`
+	LastError    *string    `json:"last_error"`
+	Runs         int        `json:"runs"`
+	Failures     int        `json:"failures"`
+	Skipped      int        `json:"skipped"`
+	// When the scheduler started, stale checks count from here before
+	// the first run
+	Started time.Time `json:"-"`
+}
+
+type IScheduler interface {
+	Register(job Job) error
+	Start(ctx context.Context)
+	Wait()
+	RunNow(name string) error
+	SetSchedule(name string, schedule Schedule) error
+	Status() []JobStatus
+	JobStatus(name string) (JobStatus, error)
+}
+
+type scheduledJob struct {
+	Job
+	mu         sync.Mutex
+	status     JobStatus
+	running    bool
+	reschedule chan struct{}
+}
+
+type Scheduler struct {
+	Clock   clock.Clock
+	mu      sync.Mutex
+	jobs    map[string]*scheduledJob
+	ctx     context.Context
+	started bool
+	// Set by Wait, no runs start after it
+	stopped bool
+	// Tracks job loops and running jobs, only added to while holding mu
+	// and before stopped is set
+	wg sync.WaitGroup
+}
+
+func NewScheduler(clock clock.Clock) IScheduler {
+	return &Scheduler{Clock: clock, jobs: map[string]*scheduledJob{}}
+}
+
+// Register adds a job. Jobs registered after Start are started immediately.
+func (s *Scheduler) Register(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return ErrJobExists
+	}
+	sj := &scheduledJob{Job: job, reschedule: make(chan struct{}, 1)}
+	sj.status.Name = job.Name
+	sj.status.Schedule = job.Schedule.String()
+	s.jobs[job.Name] = sj
+	if s.started && !s.stopped {
+		s.startJob(sj)
+	}
+	return nil
+}
+
+// Start runs every registered job on its schedule until ctx is cancelled.
+// Job runs get contexts derived from ctx.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return
+	}
+	s.started = true
+	s.ctx = ctx
+	for _, sj := range s.jobs {
+		s.startJob(sj)
+	}
+}
+
+// Wait blocks until the scheduler has stopped and running jobs have
+// returned. It returns at once if the scheduler was never started. Jobs
+// cannot be run once Wait was called.
+func (s *Scheduler) Wait() {
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) startJob(sj *scheduledJob) {
+	sj.mu.Lock()
+	sj.status.Started = s.Clock.Now()
+	sj.mu.Unlock()
+	s.wg.Add(1)
+	go s.loop(sj)
+}
+
+func (s *Scheduler) loop(sj *scheduledJob) {
+	defer s.wg.Done()
+	for {
+		now := s.Clock.Now()
+		sj.mu.Lock()
+		next := sj.Schedule.Next(now)
+		if sj.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(sj.Jitter))))
+		}
+		sj.status.NextRun = &next
+		sj.mu.Unlock()
+
+		timer := s.Clock.NewTimer(next.Sub(now))
+		select {
+		case <-s.ctx.Done():
+			timer.Stop()
+			log.Logger.Info().Str("job", sj.Name).Msg("Job stopped")
+			return
+		case <-sj.reschedule:
+			timer.Stop()
+		case <-timer.C():
+			s.execute(sj)
+		}
+	}
+}
+
+// RunNow triggers a job outside its schedule without waiting for it. It
+// fails with ErrNotStarted before Start and once the scheduler is stopping.
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sj, ok := s.jobs[name]
+	if !ok {
+		return ErrJobNotFound
+	}
+	if !s.started || s.stopped || s.ctx.Err() != nil {
+		return ErrNotStarted
+	}
+	sj.mu.Lock()
+	running := sj.running
+	sj.mu.Unlock()
+	if running {
+		return ErrJobRunning
+	}
+	// Added under mu, so Wait either sees this run or RunNow sees stopped
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(sj)
+	}()
+	return nil
+}
+
+// SetSchedule replaces the schedule of a job, e.g. after a config reload
+func (s *Scheduler) SetSchedule(name string, schedule Schedule) error {
+	s.mu.Lock()
+	sj, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return ErrJobNotFound
+	}
+	sj.mu.Lock()
+	sj.Schedule = schedule
+	sj.status.Schedule = schedule.String()
+	sj.mu.Unlock()
+	select {
+	case sj.reschedule <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// execute runs the job once unless it is already running
+func (s *Scheduler) execute(sj *scheduledJob) {
+	sj.mu.Lock()
+	if sj.running {
+		sj.status.Skipped++
+		sj.mu.Unlock()
+		log.Logger.Warn().Str("job", sj.Name).Msg("Job still running, skipping run")
+		return
+	}
+	sj.running = true
+	start := s.Clock.Now()
+	sj.status.LastStart = &start
+	timeout := sj.Timeout
+	sj.mu.Unlock()
+
+	ctx := s.ctx
+	cancel := context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	// Every run is traced as its own root span
+	ctx, span := tracer.Start(ctx, "Scheduler.run", trace.WithNewRoot(),
+		trace.WithAttributes(attribute.String("job.name", sj.Name)))
+
+	err := runSafely(ctx, sj.Run)
+	telemetry.RecordError(span, err)
+	span.End()
+	cancel()
+
+	end := s.Clock.Now()
+	sj.mu.Lock()
+	sj.running = false
+	sj.status.LastEnd = &end
+	sj.status.LastDuration = end.Sub(start).String()
+	sj.status.Runs++
+	if err != nil {
+		msg := err.Error()
+		sj.status.LastError = &msg
+		sj.status.Failures++
+	} else {
+		sj.status.LastError = nil
+		sj.status.LastSuccess = &end
+	}
+	sj.mu.Unlock()
+
+	if err != nil {
+		log.Logger.Error().Err(err).Str("job", sj.Name).Msg("Job failed")
+	}
+}
+
+// runSafely turns a panic in run into an error
+func runSafely(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+func (s *Scheduler) JobStatus(name string) (JobStatus, error) {
+	s.mu.Lock()
+	sj, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	sj.mu.Lock()
+	defer sj.mu.Unlock()
+	status := sj.status
+	status.Running = sj.running
+	return status, nil
+}
+
+// Status returns the state of every job sorted by name
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	statuses := make([]JobStatus, 0, len(names))
+	for _, name := range names {
+		if status, err := s.JobStatus(name); err == nil {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
@@ -0,0 +1,189 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/clock/fakeclock"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWaitBeforeStart(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		NewScheduler(clock.New()).Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("Wait blocked on a scheduler that was never started")
+	}
+}
+
+func TestScheduleFollowsClock(t *testing.T) {
+	start := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	fake := fakeclock.New(start)
+	var runs atomic.Int32
+	s := NewScheduler(fake)
+	s.Register(Job{Name: "hourly", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	defer s.Wait()
+	defer cancel()
+
+	waitFor(t, func() bool { return fake.Timers() == 1 })
+	fake.Advance(59 * time.Minute)
+	if runs.Load() != 0 || fake.Timers() != 1 {
+		t.Fatalf("Expected no run before the hour, got %d", runs.Load())
+	}
+	fake.Advance(time.Minute)
+	waitFor(t, func() bool {
+		status, _ := s.JobStatus("hourly")
+		return status.Runs == 1 && fake.Timers() == 1
+	})
+	status, _ := s.JobStatus("hourly")
+	if want := start.Add(2 * time.Hour); status.NextRun == nil || !status.NextRun.Equal(want) {
+		t.Errorf("Expected next run at %s, got %v", want, status.NextRun)
+	}
+	if runs.Load() != 1 {
+		t.Errorf("Expected a single run, got %d", runs.Load())
+	}
+}
+
+func TestPanicIsRecovered(t *testing.T) {
+	s := NewScheduler(clock.New())
+	s.Register(Job{Name: "panics", Schedule: Every(5 * time.Millisecond), Run: func(ctx context.Context) error {
+		panic("boom")
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	waitFor(t, func() bool {
+		status, _ := s.JobStatus("panics")
+		return status.Failures >= 2
+	})
+	cancel()
+	s.Wait()
+
+	status, _ := s.JobStatus("panics")
+	if status.LastError == nil || *status.LastError != "job panicked: boom" {
+		t.Errorf("Unexpected last error %v", status.LastError)
+	}
+}
+
+func TestOverlapPreventionAndShutdownWaits(t *testing.T) {
+	release := make(chan struct{})
+	var finished atomic.Bool
+	s := NewScheduler(clock.New())
+	s.Register(Job{Name: "slow", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		<-release
+		finished.Store(true)
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	if err := s.RunNow("slow"); err != nil {
+		t.Fatalf("Error triggering job: %v", err)
+	}
+	waitFor(t, func() bool {
+		status, _ := s.JobStatus("slow")
+		return status.Running
+	})
+	if err := s.RunNow("slow"); !errors.Is(err, ErrJobRunning) {
+		t.Errorf("Expected ErrJobRunning, got %v", err)
+	}
+	if err := s.RunNow("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Expected ErrJobNotFound, got %v", err)
+	}
+
+	cancel()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	s.Wait()
+	if !finished.Load() {
+		t.Errorf("Wait returned before the running job finished")
+	}
+}
+
+func TestNoRunsAfterStop(t *testing.T) {
+	var runs atomic.Int32
+	s := NewScheduler(clock.New())
+	s.Register(Job{Name: "quick", Schedule: Every(time.Hour), Run: func(ctx context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	// Runs triggered while shutting down either finish before Wait returns
+	// or are rejected
+	triggered := make(chan struct{})
+	go func() {
+		defer close(triggered)
+		for i := 0; i < 100; i++ {
+			s.RunNow("quick")
+		}
+	}()
+	cancel()
+	s.Wait()
+	<-triggered
+	started := runs.Load()
+	if err := s.RunNow("quick"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("Expected ErrNotStarted after stop, got %v", err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if runs.Load() != started {
+		t.Errorf("Expected no runs after Wait returned")
+	}
+}
+
+func TestTimeoutIsDerivedFromParent(t *testing.T) {
+	errs := make(chan error, 1)
+	s := NewScheduler(clock.New())
+	s.Register(Job{Name: "timeout", Schedule: Every(time.Hour), Timeout: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		errs <- ctx.Err()
+		return ctx.Err()
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.Start(ctx)
+	s.RunNow("timeout")
+	if err := <-errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestCron(t *testing.T) {
+	schedule, err := Cron("0 7 * * 1-5")
+	if err != nil {
+		t.Fatalf("Error parsing cron: %v", err)
+	}
+	// Saturday morning, next weekday run is Monday at 07:00
+	next := schedule.Next(time.Date(2024, 11, 23, 8, 0, 0, 0, time.UTC))
+	if want := time.Date(2024, 11, 25, 7, 0, 0, 0, time.UTC); !next.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, next)
+	}
+	if _, err := Cron("not a cron"); err == nil {
+		t.Errorf("Expected error for invalid cron expression")
+	}
+}
@@ -0,0 +1,27 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+var fileSeq atomic.Int64
+
+// FileDrop writes every message as an .eml file into Dir instead of
+// sending it, for tests and local development
+type FileDrop struct {
+	Dir string
+}
+
+func (f FileDrop) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(f.Dir, 0o755); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d.eml", now.UTC().Format("20060102T150405.000000000"), fileSeq.Add(1))
+	return os.WriteFile(filepath.Join(f.Dir, name), msg.Bytes(now), 0o644)
+}
@@ -0,0 +1,188 @@
+// Package markdown renders the Markdown subset used in comments to HTML.
+// Input is escaped before any markup is added, so the output only holds
+// the tags the renderer writes itself and needs no further sanitizing.
+//
+// Supported: paragraphs, hard line breaks, # headings, - and 1. lists,
+// > quotes, ``` fenced code, `code`, **bold**, *italic*, _italic_ and
+// [links](https://example.com) to http, https and mailto URLs.
+package markdown
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe   = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	bulletRe    = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	numberedRe  = regexp.MustCompile(`^\s*\d+[.)]\s+(.*)$`)
+	quoteRe     = regexp.MustCompile(`^>\s?(.*)$`)
+	codeSpanRe  = regexp.MustCompile("`([^`]+)`")
+	linkRe      = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRe    = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	emStarRe    = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	emUnderRe   = regexp.MustCompile(`(^|[^\w])_(\S(?:.*?\S)?)_([^\w]|$)`)
+	placeholder = regexp.MustCompile("\x00(\\d+)\x00")
+	mentionRe   = regexp.MustCompile(`(^|[^\w@.])@([A-Za-z0-9]{1,50})\b`)
+	safeSchemes = []string{"http://", "https://", "mailto:"}
+)
+
+// block is a run of lines rendered as one element
+type block struct {
+	kind  string
+	lines []string
+}
+
+// ToHTML renders src to HTML
+func ToHTML(src string) string {
+	var out strings.Builder
+	for _, b := range blocks(src) {
+		switch b.kind {
+		case "code":
+			fmt.Fprintf(&out, "<pre><code>%s</code></pre>\n", html.EscapeString(strings.Join(b.lines, "\n")))
+		case "heading":
+			m := headingRe.FindStringSubmatch(b.lines[0])
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+		case "ul", "ol":
+			re := bulletRe
+			if b.kind == "ol" {
+				re = numberedRe
+			}
+			fmt.Fprintf(&out, "<%s>\n", b.kind)
+			for _, line := range b.lines {
+				fmt.Fprintf(&out, "<li>%s</li>\n", inline(re.FindStringSubmatch(line)[1]))
+			}
+			fmt.Fprintf(&out, "</%s>\n", b.kind)
+		case "quote":
+			text := make([]string, len(b.lines))
+			for i, line := range b.lines {
+				text[i] = quoteRe.FindStringSubmatch(line)[1]
+			}
+			fmt.Fprintf(&out, "<blockquote><p>%s</p></blockquote>\n", paragraph(text))
+		default:
+			fmt.Fprintf(&out, "<p>%s</p>\n", paragraph(b.lines))
+		}
+	}
+	return out.String()
+}
+
+// Mentions returns the usernames mentioned with @username outside code,
+// each once, in order of first mention
+func Mentions(src string) []string {
+	mentions := []string{}
+	seen := map[string]bool{}
+	for _, b := range blocks(src) {
+		if b.kind == "code" {
+			continue
+		}
+		text := codeSpanRe.ReplaceAllString(strings.Join(b.lines, "\n"), "")
+		for _, m := range mentionRe.FindAllStringSubmatch(text, -1) {
+			if !seen[m[2]] {
+				seen[m[2]] = true
+				mentions = append(mentions, m[2])
+			}
+		}
+	}
+	return mentions
+}
+
+// blocks splits src into block elements
+func blocks(src string) []block {
+	src = strings.ReplaceAll(strings.ReplaceAll(src, "\r\n", "\n"), "\x00", "")
+	var result []block
+	var current *block
+	flush := func() {
+		if current != nil {
+			result = append(result, *current)
+			current = nil
+		}
+	}
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			code := block{kind: "code"}
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code.lines = append(code.lines, lines[i])
+			}
+			result = append(result, code)
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+		kind := "p"
+		switch {
+		case headingRe.MatchString(line):
+			kind = "heading"
+		case bulletRe.MatchString(line):
+			kind = "ul"
+		case numberedRe.MatchString(line):
+			kind = "ol"
+		case quoteRe.MatchString(line):
+			kind = "quote"
+		}
+		if kind == "heading" || current == nil || current.kind != kind {
+			flush()
+			current = &block{kind: kind}
+		}
+		current.lines = append(current.lines, line)
+		if kind == "heading" {
+			flush()
+		}
+	}
+	flush()
+	return result
+}
+
+// paragraph renders lines as inline text joined by line breaks
+func paragraph(lines []string) string {
+	rendered := make([]string, len(lines))
+	for i, line := range lines {
+		rendered[i] = inline(strings.TrimSpace(line))
+	}
+	return strings.Join(rendered, "<br>\n")
+}
+
+// inline renders the inline markup of one line. Code spans and links are
+// swapped for placeholders first so emphasis never reaches into them.
+func inline(text string) string {
+	var saved []string
+	save := func(s string) string {
+		saved = append(saved, s)
+		return fmt.Sprintf("\x00%d\x00", len(saved)-1)
+	}
+	text = codeSpanRe.ReplaceAllStringFunc(text, func(m string) string {
+		return save("<code>" + html.EscapeString(m[1:len(m)-1]) + "</code>")
+	})
+	text = html.EscapeString(text)
+	text = linkRe.ReplaceAllStringFunc(text, func(m string) string {
+		parts := linkRe.FindStringSubmatch(m)
+		if !safeURL(html.UnescapeString(parts[2])) {
+			return m
+		}
+		return save(fmt.Sprintf(`<a href="%s" rel="nofollow noopener">`, parts[2])) + parts[1] + save("</a>")
+	})
+	text = strongRe.ReplaceAllString(text, "<strong>$1</strong>")
+	text = emStarRe.ReplaceAllString(text, "<em>$1</em>")
+	text = emUnderRe.ReplaceAllString(text, "$1<em>$2</em>$3")
+	return placeholder.ReplaceAllStringFunc(text, func(m string) string {
+		var i int
+		fmt.Sscanf(placeholder.FindStringSubmatch(m)[1], "%d", &i)
+		return saved[i]
+	})
+}
+
+func safeURL(url string) bool {
+	lower := strings.ToLower(url)
+	for _, scheme := range safeSchemes {
+		if strings.HasPrefix(lower, scheme) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package markdown
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one<br>\ntwo</p>\n<p>three</p>\n"},
+		{"emphasis", "**bold** and *it* and _it_ but snake_case_name", "<p><strong>bold</strong> and <em>it</em> and <em>it</em> but snake_case_name</p>\n"},
+		{"heading", "## Plan", "<h2>Plan</h2>\n"},
+		{"lists", "- a\n- b\n1. c", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n<ol>\n<li>c</li>\n</ol>\n"},
+		{"quote", "> said\n> so", "<blockquote><p>said<br>\nso</p></blockquote>\n"},
+		{"code", "`a*b*c` and\n```\n<b>*x*</b>\n```", "<p><code>a*b*c</code> and</p>\n<pre><code>&lt;b&gt;*x*&lt;/b&gt;</code></pre>\n"},
+		{"link", "[docs](https://example.com/a_b?x=1&y=*2*)", `<p><a href="https://example.com/a_b?x=1&amp;y=*2*" rel="nofollow noopener">docs</a></p>` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ToHTML(tt.src); got != tt.want {
+				t.Errorf("ToHTML(%q)\n got %q\nwant %q", tt.src, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToHTMLIsSanitized(t *testing.T) {
+	for _, src := range []string{
+		`<script>alert(1)</script>`,
+		`<img src=x onerror=alert(1)>`,
+		`[click](javascript:alert(1))`,
+		`[click](JaVaScRiPt:alert(1))`,
+		`[x](https://a.com" onclick="alert(1))`,
+		"`</code><script>`",
+		"\x00" + `0` + "\x00<script>",
+	} {
+		got := ToHTML(src)
+		for _, bad := range []string{"<script", "<img", `href="javascript`, `href="JaVaScRiPt`, `" onclick`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("ToHTML(%q) = %q contains %s", src, got, bad)
+			}
+		}
+	}
+}
+
+func TestMentions(t *testing.T) {
+	src := "@ada can you look? cc @bob, @ada\nmail me at bob@example.com\n`@carol` and\n```\n@dave\n```"
+	if got := fmt.Sprint(Mentions(src)); got != "[ada bob]" {
+		t.Errorf("Unexpected mentions %s", got)
+	}
+}
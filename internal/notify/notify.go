@@ -0,0 +1,56 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"todo-api/internal/db/models"
+
+	"github.com/rs/zerolog"
+)
+
+// Notifier delivers a notification from the outbox
+type Notifier interface {
+	Notify(ctx context.Context, n models.Notification) error
+}
+
+const (
+	KindLog     = "log"
+	KindWebhook = "webhook"
+	KindSMTP    = "smtp"
+)
+
+// Subject is the one line summary of a notification
+func Subject(n models.Notification) string {
+	if n.Kind == models.NotificationAssignment {
+		return fmt.Sprintf("Assigned: %s", n.Title)
+	}
+	return fmt.Sprintf("Reminder: %s is due in %s", n.Title, models.FormatDuration(n.LeadTime))
+}
+
+// Body is the plain text message of a notification
+func Body(n models.Notification) string {
+	body := fmt.Sprintf("Task %d %q", n.TaskID, n.Title)
+	if n.Kind == models.NotificationAssignment && n.UserName != nil {
+		body += " was assigned to " + *n.UserName
+		if n.DueDate != nil {
+			body += " and"
+		}
+	}
+	if n.DueDate != nil {
+		body += " is due " + n.DueDate.UTC().Format(time.RFC1123)
+	}
+	return body + ".\n"
+}
+
+// Log writes notifications to the logger in the context
+type Log struct{}
+
+func (Log) Notify(ctx context.Context, n models.Notification) error {
+	zerolog.Ctx(ctx).Info().
+		Int("notification_id", n.ID).
+		Int("task_id", n.TaskID).
+		Time("remind_at", n.RemindAt).
+		Msg(Subject(n))
+	return nil
+}
@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/mail"
+	"todo-api/internal/notify/smtptest"
+)
+
+func newNotification() models.Notification {
+	dueDate := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	return models.Notification{
+		ID:       1,
+		TaskID:   7,
+		Kind:     models.NotificationReminder,
+		Title:    "Write report",
+		DueDate:  &dueDate,
+		LeadTime: time.Hour,
+		RemindAt: dueDate.Add(-time.Hour),
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Error decoding payload: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	if err := (Webhook{URL: server.URL}).Notify(context.TODO(), newNotification()); err != nil {
+		t.Fatalf("Error notifying: %v", err)
+	}
+	if got["subject"] != "Reminder: Write report is due in 1h" || got["task_id"] != float64(7) {
+		t.Errorf("Unexpected payload %v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	if err := (Webhook{URL: failing.URL}).Notify(context.TODO(), newNotification()); err == nil {
+		t.Errorf("Expected an error for a failing webhook")
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	server, err := smtptest.NewServer("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error starting SMTP server: %v", err)
+	}
+	defer server.Close()
+
+	notifier := SMTP{
+		SMTP: mail.SMTP{Addr: server.Addr, Username: "user", Password: "secret"},
+		From: "todo-api@localhost",
+		To:   []string{"a@example.com", "b@example.com"},
+	}
+	if err := notifier.Notify(context.TODO(), newNotification()); err != nil {
+		t.Fatalf("Error notifying: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(messages))
+	}
+	msg := messages[0]
+	if msg.From != "todo-api@localhost" || strings.Join(msg.To, ",") != "a@example.com,b@example.com" {
+		t.Errorf("Unexpected envelope %s -> %v", msg.From, msg.To)
+	}
+	if !strings.Contains(msg.Data, "Subject: Reminder: Write report is due in 1h\r\n") {
+		t.Errorf("Expected the subject in the message, got %q", msg.Data)
+	}
+
+	// Titles are user input, line breaks must not start new headers
+	n := newNotification()
+	n.Title = "Überweisung prüfen\r\nBcc: victim@example.com\nX-Injected: yes"
+	if err := notifier.Notify(context.TODO(), n); err != nil {
+		t.Fatalf("Error notifying: %v", err)
+	}
+	messages = server.Messages()
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+	headers, _, _ := strings.Cut(messages[1].Data, "\r\n\r\n")
+	var subject string
+	for _, line := range strings.Split(headers, "\r\n") {
+		if strings.HasPrefix(line, "Bcc:") || strings.HasPrefix(line, "X-Injected:") {
+			t.Errorf("Expected no injected header, got %q", line)
+		}
+		if value, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject = value
+		}
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(subject)
+	if err != nil {
+		t.Fatalf("Error decoding subject %q: %v", subject, err)
+	}
+	if want := "Reminder: Überweisung prüfen Bcc: victim@example.com X-Injected: yes is due in 1h"; decoded != want {
+		t.Errorf("Expected subject %q, got %q (%q)", want, decoded, subject)
+	}
+}
@@ -0,0 +1,23 @@
+package notify
+
+import (
+	"context"
+	"todo-api/internal/db/models"
+	"todo-api/internal/mail"
+)
+
+// SMTP mails notifications to every address in To
+type SMTP struct {
+	mail.SMTP
+	From string
+	To   []string
+}
+
+func (s SMTP) Notify(ctx context.Context, n models.Notification) error {
+	return s.Send(ctx, mail.Message{
+		From:    s.From,
+		To:      s.To,
+		Subject: Subject(n),
+		Text:    Body(n),
+	})
+}
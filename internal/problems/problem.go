@@ -0,0 +1,268 @@
+package problems
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/jobs"
+	"todo-api/internal/query"
+	"todo-api/internal/services"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+const ContentType = "application/problem+json"
+
+// Problem types, relative to the API root
+const (
+	TypeBlank         = "about:blank"
+	TypeValidation    = "/problems/validation-error"
+	TypeMalformed     = "/problems/malformed-request"
+	TypeTaskNotFound  = "/problems/task-not-found"
+	TypeAlreadyExists = "/problems/task-already-exists"
+	TypeRateLimited   = "/problems/rate-limited"
+	TypeTooLarge      = "/problems/request-too-large"
+	TypeJobNotFound   = "/problems/job-not-found"
+	TypeJobRunning    = "/problems/job-running"
+	TypeJobsStopped   = "/problems/jobs-stopped"
+	TypeUserNotFound  = "/problems/user-not-found"
+	TypeUsernameTaken = "/problems/username-taken"
+
+	TypeProjectNotFound    = "/problems/project-not-found"
+	TypeProjectKeyTaken    = "/problems/project-key-taken"
+	TypeIllegalTransition  = "/problems/illegal-transition"
+	TypeStatusChanged      = "/problems/status-changed"
+	TypeBadPlacement       = "/problems/bad-placement"
+	TypeMoveConflict       = "/problems/move-conflict"
+	TypeUnknownUser        = "/problems/unknown-user"
+	TypeNotMember          = "/problems/not-a-project-member"
+	TypeCommentNotFound    = "/problems/comment-not-found"
+	TypeNotAuthor          = "/problems/not-the-author"
+	TypeCallerRequired     = "/problems/caller-required"
+	TypeAttachmentNotFound = "/problems/attachment-not-found"
+	TypeFileTooLarge       = "/problems/file-too-large"
+	TypeAttachmentQuota    = "/problems/attachment-quota-exceeded"
+	TypeTimeEntryNotFound  = "/problems/time-entry-not-found"
+	TypeTimerRunning       = "/problems/timer-running"
+	TypeNoRunningTimer     = "/problems/no-running-timer"
+	TypeNotEntryOwner      = "/problems/not-the-entry-owner"
+	TypeSprintNotFound     = "/problems/sprint-not-found"
+	TypeSprintProject      = "/problems/sprint-of-another-project"
+	TypeViewNotFound       = "/problems/view-not-found"
+)
+
+// FieldError describes a single invalid request field. Position points at
+// the offending character of a task query, counted from 1.
+type FieldError struct {
+	Field    string `json:"field"`
+	Rule     string `json:"rule,omitempty"`
+	Message  string `json:"message"`
+	Position int    `json:"position,omitempty"`
+}
+
+// Problem is an RFC 7807 problem details object. It implements error so
+// handlers can return it directly.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+	cause    error
+}
+
+func New(status int, problemType string, detail string) *Problem {
+	return &Problem{
+		Type:   problemType,
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// InvalidQuery reports an error in the task query of field
+func InvalidQuery(field string, err *query.Error) *Problem {
+	p := InvalidField(field, err.Msg)
+	p.Errors[0].Position = err.Pos
+	p.cause = err
+	return p
+}
+
+// InvalidField reports a single invalid field that the validator can't check,
+// e.g. a path parameter or a date that failed to parse
+func InvalidField(field string, message string) *Problem {
+	p := New(http.StatusBadRequest, TypeValidation, "request validation failed")
+	p.Errors = []FieldError{{Field: field, Message: message}}
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", p.Title, p.Detail, p.cause)
+	}
+	return fmt.Sprintf("%s: %s", p.Title, p.Detail)
+}
+
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// From maps err to a Problem. Unknown errors become a 500 without leaking
+// their message to the client.
+func From(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+	var queryErr *query.Error
+	if errors.As(err, &queryErr) {
+		return InvalidQuery("query", queryErr)
+	}
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		p = New(http.StatusBadRequest, TypeValidation, "request validation failed")
+		for _, fe := range validationErrs {
+			p.Errors = append(p.Errors, FieldError{
+				Field:   fe.Field(),
+				Rule:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+		p.cause = err
+		return p
+	}
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		problemType := TypeBlank
+		switch httpErr.Code {
+		case http.StatusBadRequest:
+			problemType = TypeMalformed
+		case http.StatusRequestEntityTooLarge:
+			problemType = TypeTooLarge
+		}
+		p = New(httpErr.Code, problemType, fmt.Sprint(httpErr.Message))
+		p.cause = err
+		return p
+	}
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		p = New(http.StatusNotFound, TypeTaskNotFound, "task not found")
+	case errors.Is(err, repository.ErrNoTitle):
+		p = InvalidField("title", "title is required")
+	case errors.Is(err, repository.ErrAlreadyExists):
+		p = New(http.StatusConflict, TypeAlreadyExists, "task already exists")
+	case errors.Is(err, repository.ErrUserNotFound):
+		p = New(http.StatusNotFound, TypeUserNotFound, "user not found")
+	case errors.Is(err, repository.ErrUsernameTaken):
+		p = New(http.StatusConflict, TypeUsernameTaken, "username is already taken")
+	case errors.Is(err, repository.ErrProjectNotFound):
+		p = New(http.StatusNotFound, TypeProjectNotFound, "project not found")
+	case errors.Is(err, repository.ErrProjectKeyTaken):
+		p = New(http.StatusConflict, TypeProjectKeyTaken, "project key is already taken")
+	case errors.Is(err, models.ErrUnknownStatus):
+		p = InvalidField("status", "status is not a state of the task's workflow")
+	case errors.Is(err, models.ErrInvalidTag):
+		p = InvalidField("tags", models.ErrInvalidTag.Error())
+	case errors.Is(err, models.ErrIllegalTransition):
+		p = New(http.StatusConflict, TypeIllegalTransition, "the workflow does not allow this transition")
+	case errors.Is(err, repository.ErrStatusChanged):
+		p = New(http.StatusConflict, TypeStatusChanged, "task status was changed by another request")
+	case errors.Is(err, repository.ErrBadPlacement):
+		p = New(http.StatusConflict, TypeBadPlacement, "before and after must be cards of the target column, in board order")
+	case errors.Is(err, repository.ErrMoveConflict):
+		p = New(http.StatusConflict, TypeMoveConflict, "the column kept changing, try again")
+	case errors.Is(err, services.ErrUnknownUser):
+		p = New(http.StatusUnprocessableEntity, TypeUnknownUser, "user does not exist")
+	case errors.Is(err, services.ErrNotMember):
+		p = New(http.StatusUnprocessableEntity, TypeNotMember, "user is not a member of the task's project")
+	case errors.Is(err, repository.ErrCommentNotFound):
+		p = New(http.StatusNotFound, TypeCommentNotFound, "comment not found")
+	case errors.Is(err, services.ErrNotAuthor):
+		p = New(http.StatusForbidden, TypeNotAuthor, "only the author can change a comment")
+	case errors.Is(err, repository.ErrAttachmentNotFound):
+		p = New(http.StatusNotFound, TypeAttachmentNotFound, "attachment not found")
+	case errors.Is(err, services.ErrFileTooLarge):
+		p = New(http.StatusRequestEntityTooLarge, TypeFileTooLarge, "file is larger than the per-file limit")
+	case errors.Is(err, services.ErrAttachmentQuota):
+		p = New(http.StatusRequestEntityTooLarge, TypeAttachmentQuota,
+			"attachments of the task would exceed the per-task limit")
+	case errors.Is(err, repository.ErrTimeEntryNotFound):
+		p = New(http.StatusNotFound, TypeTimeEntryNotFound, "time entry not found")
+	case errors.Is(err, repository.ErrTimerRunning):
+		p = New(http.StatusConflict, TypeTimerRunning, "a timer of the user is already running, stop it first")
+	case errors.Is(err, repository.ErrNoRunningTimer):
+		p = New(http.StatusConflict, TypeNoRunningTimer, "no timer of the user is running on this task")
+	case errors.Is(err, services.ErrNotEntryOwner):
+		p = New(http.StatusForbidden, TypeNotEntryOwner, "only the user who logged a time entry can change it")
+	case errors.Is(err, services.ErrInvalidInterval):
+		p = InvalidField("ended_at", "ended_at must be after started_at")
+	case errors.Is(err, repository.ErrSprintNotFound):
+		p = New(http.StatusNotFound, TypeSprintNotFound, "sprint not found")
+	case errors.Is(err, services.ErrSprintProject):
+		p = New(http.StatusUnprocessableEntity, TypeSprintProject, "task and sprint belong to different projects")
+	case errors.Is(err, repository.ErrViewNotFound):
+		p = New(http.StatusNotFound, TypeViewNotFound, "view not found")
+	case errors.Is(err, jobs.ErrJobNotFound):
+		p = New(http.StatusNotFound, TypeJobNotFound, "job not found")
+	case errors.Is(err, jobs.ErrJobRunning):
+		p = New(http.StatusConflict, TypeJobRunning, "job is already running")
+	case errors.Is(err, jobs.ErrNotStarted):
+		p = New(http.StatusServiceUnavailable, TypeJobsStopped, "background jobs are not running")
+	default:
+		p = New(http.StatusInternalServerError, TypeBlank, "internal server error")
+	}
+	p.cause = err
+	return p
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return fe.Field() + " is required"
+	case "max":
+		return fe.Field() + " must be at most " + fe.Param() + " characters long"
+	case "oneof":
+		return fe.Field() + " must be one of: " + fe.Param()
+	case "email":
+		return fe.Field() + " must be an email address"
+	case "alphanum":
+		return fe.Field() + " must contain only letters and digits"
+	case "datetime":
+		return fe.Field() + " must have the format " + fe.Param()
+	}
+	return fe.Field() + " failed the " + fe.Tag() + " rule"
+}
+
+// HTTPErrorHandler renders every error returned by a handler as
+// application/problem+json
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+	p := From(err)
+	if p.Instance == "" {
+		p.Instance = c.Request().URL.Path
+	}
+
+	logger := zerolog.Ctx(c.Request().Context())
+	if p.Status >= http.StatusInternalServerError {
+		logger.Error().Err(err).Int("status", p.Status).Msg("request failed")
+	} else {
+		logger.Debug().Err(err).Int("status", p.Status).Msg("request rejected")
+	}
+
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(p.Status)
+	} else {
+		c.Response().Header().Set(echo.HeaderContentType, ContentType)
+		err = c.JSON(p.Status, p)
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to write error response")
+	}
+}
@@ -0,0 +1,335 @@
+package query
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"todo-api/internal/db/models"
+)
+
+// Env is what a query is compiled against
+type Env struct {
+	// Relative times such as 7d count from Now
+	Now time.Time
+	// Dates such as 2024-12-01 and today are days in Location
+	Location *time.Location
+	// The user assignee:me stands for, nil when the caller is unknown
+	Caller *int
+}
+
+// SortKey orders the matching tasks by a column of the task table
+type SortKey struct {
+	Column string
+	Desc   bool
+}
+
+// Filter is a compiled query. Where is a condition on the task table with
+// ? placeholders for Args, empty when the query has no conditions.
+type Filter struct {
+	Where string
+	Args  []interface{}
+	Sort  []SortKey
+	// Set when Where compares with times relative to Env.Now, such as
+	// due<7d, so compiling again later gives other Args
+	Relative bool
+}
+
+// Fields lists the fields a term may name
+var Fields = []string{"status", "is", "priority", "project", "assignee", "sprint", "due", "created", "completed",
+	"points", "title", "tag", "sort"}
+
+// Columns sort:field orders by
+var sortFields = map[string]string{
+	"id":       "id",
+	"title":    "title",
+	"due":      "due_date",
+	"priority": "priority",
+	"overdue":  "overdue",
+	"position": "position",
+}
+
+// Columns of the time fields
+var timeFields = map[string]string{
+	"due":       "due_date",
+	"created":   "created_at",
+	"completed": "completed_at",
+}
+
+var relativeTime = regexp.MustCompile(`^(-?\d+)([hdw])$`)
+
+// Compile turns a parsed query into a SQL condition, every value is passed
+// as an argument
+func Compile(q *Query, env Env) (*Filter, error) {
+	filter := &Filter{Args: []interface{}{}}
+	conditions := []string{}
+	for _, term := range q.Terms {
+		if term.Field == "sort" {
+			keys, err := compileSort(term)
+			if err != nil {
+				return nil, err
+			}
+			filter.Sort = append(filter.Sort, keys...)
+			continue
+		}
+		condition, args, err := compileTerm(term, env)
+		if err != nil {
+			return nil, err
+		}
+		if term.Negated {
+			// Comparisons with NULL are neither true nor false, count them
+			// as false so -due<7d matches tasks without a due date
+			condition = "NOT COALESCE((" + condition + "), false)"
+		}
+		conditions = append(conditions, condition)
+		filter.Args = append(filter.Args, args...)
+		if _, ok := timeFields[term.Field]; ok && relativeTime.MatchString(term.Value) {
+			filter.Relative = true
+		}
+	}
+	filter.Where = strings.Join(conditions, " AND ")
+	return filter, nil
+}
+
+// Validate reports the first error of src that does not depend on who runs
+// the query or when, as a saved query is compiled each time it runs
+func Validate(src string) error {
+	q, err := Parse(src)
+	if err != nil {
+		return err
+	}
+	anyone := 0
+	_, err = Compile(q, Env{Now: time.Now(), Location: time.UTC, Caller: &anyone})
+	return err
+}
+
+func compileSort(term Term) ([]SortKey, error) {
+	if term.Negated {
+		return nil, errorAt(term.Pos, "sort cannot be negated, use sort:-%s to sort descending", term.Value)
+	}
+	if term.Op != ":" {
+		return nil, errorAt(term.FieldPos, "sort takes :")
+	}
+	keys := []SortKey{}
+	pos := term.ValuePos
+	for _, field := range strings.Split(term.Value, ",") {
+		key := SortKey{}
+		name := field
+		if strings.HasPrefix(name, "-") {
+			name, key.Desc = name[1:], true
+		}
+		column, ok := sortFields[name]
+		if !ok {
+			return nil, errorAt(pos, "cannot sort by %q, use id, title, due, priority, overdue or position", name)
+		}
+		key.Column = column
+		keys = append(keys, key)
+		pos += len([]rune(field)) + 1
+	}
+	return keys, nil
+}
+
+// values splits a comma separated value, with the position of each part
+func values(term Term) ([]string, []int) {
+	parts := strings.Split(term.Value, ",")
+	positions := make([]int, len(parts))
+	pos := term.ValuePos
+	for i, part := range parts {
+		positions[i] = pos
+		pos += len([]rune(part)) + 1
+	}
+	return parts, positions
+}
+
+// anyOf joins the conditions of each value of a : term with OR
+func anyOf(term Term, compile func(value string, pos int) (string, []interface{}, error)) (string, []interface{}, error) {
+	if term.Op != ":" && term.Op != "=" {
+		return "", nil, errorAt(term.FieldPos, "%s takes : or =", term.Field)
+	}
+	parts, positions := values(term)
+	conditions := []string{}
+	args := []interface{}{}
+	for i, part := range parts {
+		condition, partArgs, err := compile(part, positions[i])
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, partArgs...)
+	}
+	if len(conditions) == 1 {
+		return conditions[0], args, nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args, nil
+}
+
+// like escapes text for a LIKE pattern that matches it anywhere
+func like(text string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text) + "%"
+}
+
+func compileTerm(term Term, env Env) (string, []interface{}, error) {
+	switch term.Field {
+	case "":
+		pattern := like(term.Value)
+		return `(title LIKE ? ESCAPE '\' OR COALESCE(description, '') LIKE ? ESCAPE '\')`,
+			[]interface{}{pattern, pattern}, nil
+	case "title":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			return `title LIKE ? ESCAPE '\'`, []interface{}{like(value)}, nil
+		})
+	case "status":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			switch value {
+			case "open":
+				return "completed = false", nil, nil
+			case "completed":
+				return "completed = true", nil, nil
+			}
+			return "status = ?", []interface{}{value}, nil
+		})
+	case "is":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			switch value {
+			case "open":
+				return "completed = false", nil, nil
+			case "completed":
+				return "completed = true", nil, nil
+			case "overdue":
+				return "overdue = true", nil, nil
+			case "unassigned":
+				return "NOT EXISTS (SELECT 1 FROM task_assignee WHERE task_id = task.id)", nil, nil
+			}
+			return "", nil, errorAt(pos, "unknown value %q, is takes open, completed, overdue or unassigned", value)
+		})
+	case "priority":
+		if term.Op == ":" || term.Op == "=" {
+			return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+				priority, err := priorityAt(value, pos)
+				return "priority = ?", []interface{}{priority}, err
+			})
+		}
+		priority, err := priorityAt(term.Value, term.ValuePos)
+		return "priority " + term.Op + " ?", []interface{}{priority}, err
+	case "project":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			if id, err := strconv.Atoi(value); err == nil {
+				return "project_id = ?", []interface{}{id}, nil
+			}
+			return "project_id IN (SELECT id FROM project WHERE key = ?)", []interface{}{value}, nil
+		})
+	case "assignee":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			const assigned = "id IN (SELECT task_id FROM task_assignee WHERE user_id = ?)"
+			switch value {
+			case "none":
+				return "NOT EXISTS (SELECT 1 FROM task_assignee WHERE task_id = task.id)", nil, nil
+			case "me":
+				if env.Caller == nil {
+					return "", nil, errorAt(pos, "assignee:me needs to know the caller")
+				}
+				return assigned, []interface{}{*env.Caller}, nil
+			}
+			if id, err := strconv.Atoi(value); err == nil {
+				return assigned, []interface{}{id}, nil
+			}
+			return "id IN (SELECT a.task_id FROM task_assignee a JOIN user u ON u.id = a.user_id WHERE u.username = ?)",
+				[]interface{}{value}, nil
+		})
+	case "tag":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			if value == "none" {
+				return "NOT EXISTS (SELECT 1 FROM task_tag WHERE task_id = task.id)", nil, nil
+			}
+			return "EXISTS (SELECT 1 FROM task_tag WHERE task_id = task.id AND tag = ?)",
+				[]interface{}{strings.ToLower(value)}, nil
+		})
+	case "sprint":
+		return anyOf(term, func(value string, pos int) (string, []interface{}, error) {
+			if value == "none" {
+				return "sprint_id IS NULL", nil, nil
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return "", nil, errorAt(pos, "sprint must be a sprint id or none")
+			}
+			return "sprint_id = ?", []interface{}{id}, nil
+		})
+	case "points":
+		if term.Value == "none" && (term.Op == ":" || term.Op == "=") {
+			return "estimate_points IS NULL", nil, nil
+		}
+		points, err := strconv.Atoi(term.Value)
+		if err != nil {
+			return "", nil, errorAt(term.ValuePos, "points must be a number or none")
+		}
+		op := term.Op
+		if op == ":" {
+			op = "="
+		}
+		return "estimate_points " + op + " ?", []interface{}{points}, nil
+	case "due", "created", "completed":
+		return compileTime(term, timeFields[term.Field], env)
+	}
+	return "", nil, errorAt(term.FieldPos, "unknown field %q, use one of %s", term.Field, strings.Join(Fields, ", "))
+}
+
+func priorityAt(value string, pos int) (int, error) {
+	priority, err := models.ParsePriority(value)
+	if err != nil {
+		return 0, errorAt(pos, "unknown priority %q, use none, low, medium, high or urgent", value)
+	}
+	return int(priority), nil
+}
+
+// compileTime compares a time column with a day or with a time relative to
+// now. A day compares as the whole day: due<2024-12-01 is before it starts,
+// due<=2024-12-01 before it ends and due:2024-12-01 during it.
+func compileTime(term Term, column string, env Env) (string, []interface{}, error) {
+	if term.Value == "none" {
+		if term.Op != ":" && term.Op != "=" {
+			return "", nil, errorAt(term.ValuePos, "none only takes : or =")
+		}
+		return column + " IS NULL", nil, nil
+	}
+	if match := relativeTime.FindStringSubmatch(term.Value); match != nil {
+		if term.Op == ":" || term.Op == "=" {
+			return "", nil, errorAt(term.ValuePos, "compare relative times with < or >, e.g. %s<%s", term.Field, term.Value)
+		}
+		n, _ := strconv.Atoi(match[1])
+		unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[match[2]]
+		return column + " " + term.Op + " ?", []interface{}{env.Now.Add(time.Duration(n) * unit).UTC()}, nil
+	}
+
+	today := env.Now.In(env.Location)
+	midnight := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, env.Location)
+	var day time.Time
+	switch term.Value {
+	case "today":
+		day = midnight
+	case "tomorrow":
+		day = midnight.AddDate(0, 0, 1)
+	case "yesterday":
+		day = midnight.AddDate(0, 0, -1)
+	default:
+		var err error
+		day, err = time.ParseInLocation("2006-01-02", term.Value, env.Location)
+		if err != nil {
+			return "", nil, errorAt(term.ValuePos,
+				"%s takes a YYYY-MM-DD date, today, tomorrow, yesterday, a relative time such as 7d or -2w, or none",
+				term.Field)
+		}
+	}
+	start, end := day.UTC(), day.AddDate(0, 0, 1).UTC()
+	switch term.Op {
+	case "<":
+		return column + " < ?", []interface{}{start}, nil
+	case "<=":
+		return column + " < ?", []interface{}{end}, nil
+	case ">":
+		return column + " >= ?", []interface{}{end}, nil
+	case ">=":
+		return column + " >= ?", []interface{}{start}, nil
+	}
+	return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{start, end}, nil
+}
@@ -0,0 +1,98 @@
+// Package query implements the task query language, e.g.
+//
+//	status:open due<7d -priority:low "login page" sort:due
+//
+// A query is a list of terms that must all match. A term is a bare or
+// quoted text searched in titles and descriptions, or a field, an operator
+// and a value; a leading - negates it. Queries are parsed into terms and
+// compiled to a parameterized SQL condition on the task table.
+package query
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is a syntax or semantic error at a position of the query, counted
+// in characters from 1
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("at %d: %s", e.Pos, e.Msg)
+}
+
+func errorAt(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenString
+	tokenOp
+	tokenMinus
+	tokenEOF
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	// Position right after the token, to tell adjacent tokens apart
+	end int
+}
+
+// Characters that end a bare word
+const separators = " \t\r\n\":<>="
+
+// lex splits a query into tokens. Operators are :, =, <, <=, > and >=; a -
+// starts a negation or a negative value unless it is part of a word.
+func lex(src string) ([]token, error) {
+	runes := []rune(src)
+	tokens := []token{}
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case strings.ContainsRune(" \t\r\n", r):
+			i++
+			continue
+		case r == '"':
+			text := strings.Builder{}
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, errorAt(start+1, "unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{tokenString, text.String(), start + 1, i + 1})
+		case r == ':' || r == '=':
+			i++
+			tokens = append(tokens, token{tokenOp, string(r), start + 1, i + 1})
+		case r == '<' || r == '>':
+			i++
+			if i < len(runes) && runes[i] == '=' {
+				i++
+			}
+			tokens = append(tokens, token{tokenOp, string(runes[start:i]), start + 1, i + 1})
+		case r == '-':
+			i++
+			tokens = append(tokens, token{tokenMinus, "-", start + 1, i + 1})
+		default:
+			for i < len(runes) && !strings.ContainsRune(separators, runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[start:i]), start + 1, i + 1})
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes) + 1, len(runes) + 1}), nil
+}
@@ -0,0 +1,117 @@
+package query
+
+// Most terms a query may have
+const MaxTerms = 50
+
+// Term is one condition of a query. Text terms have no Field.
+type Term struct {
+	// Position of the term, including a leading -
+	Pos      int
+	Negated  bool
+	Field    string
+	FieldPos int
+	// One of :, =, <, <=, >, >= for field terms
+	Op       string
+	Value    string
+	ValuePos int
+}
+
+// Query is a parsed query, its terms must all match
+type Query struct {
+	Terms []Term
+}
+
+type parser struct {
+	tokens []token
+	next   int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.next]
+}
+
+func (p *parser) take() token {
+	t := p.tokens[p.next]
+	if t.kind != tokenEOF {
+		p.next++
+	}
+	return t
+}
+
+// describe names a token in error messages
+func describe(t token) string {
+	if t.kind == tokenEOF {
+		return "end of query"
+	}
+	return "\"" + t.text + "\""
+}
+
+// Parse parses a query, an empty one matches every task
+func Parse(src string) (*Query, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	q := &Query{Terms: []Term{}}
+	for p.peek().kind != tokenEOF {
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		if len(q.Terms) == MaxTerms {
+			return nil, errorAt(term.Pos, "a query has at most %d terms", MaxTerms)
+		}
+		q.Terms = append(q.Terms, term)
+	}
+	return q, nil
+}
+
+// term parses ["-"] (text | field op value)
+func (p *parser) term() (Term, error) {
+	t := p.take()
+	term := Term{Pos: t.pos}
+	if t.kind == tokenMinus {
+		next := p.peek()
+		if next.pos != t.end || (next.kind != tokenWord && next.kind != tokenString) {
+			return term, errorAt(t.pos, "- must be directly followed by a term")
+		}
+		term.Negated = true
+		t = p.take()
+	}
+	switch t.kind {
+	case tokenString:
+		term.Value, term.ValuePos = t.text, t.pos
+	case tokenWord:
+		if p.peek().kind != tokenOp {
+			term.Value, term.ValuePos = t.text, t.pos
+			break
+		}
+		term.Field, term.FieldPos = t.text, t.pos
+		term.Op = p.take().text
+		value, pos, err := p.value(term.Op)
+		if err != nil {
+			return term, err
+		}
+		term.Value, term.ValuePos = value, pos
+	default:
+		return term, errorAt(t.pos, "unexpected %s, expected a term", describe(t))
+	}
+	return term, nil
+}
+
+// value parses ["-"] (word | string), the - makes a negative value such as
+// -7d
+func (p *parser) value(op string) (string, int, error) {
+	t := p.take()
+	sign := ""
+	pos := t.pos
+	if t.kind == tokenMinus && p.peek().pos == t.end && p.peek().kind == tokenWord {
+		sign = "-"
+		t = p.take()
+	}
+	if t.kind != tokenWord && t.kind != tokenString {
+		return "", 0, errorAt(t.pos, "unexpected %s, expected a value after %s", describe(t), op)
+	}
+	return sign + t.text, pos, nil
+}
@@ -0,0 +1,117 @@
+package query
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`status:open due<-7d -priority:low "login page" sort:due`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []Term{
+		{Pos: 1, Field: "status", FieldPos: 1, Op: ":", Value: "open", ValuePos: 8},
+		{Pos: 13, Field: "due", FieldPos: 13, Op: "<", Value: "-7d", ValuePos: 17},
+		{Pos: 21, Negated: true, Field: "priority", FieldPos: 22, Op: ":", Value: "low", ValuePos: 31},
+		{Pos: 35, Value: "login page", ValuePos: 35},
+		{Pos: 48, Field: "sort", FieldPos: 48, Op: ":", Value: "due", ValuePos: 53},
+	}
+	if !reflect.DeepEqual(q.Terms, want) {
+		t.Errorf("Parse:\n got %+v\nwant %+v", q.Terms, want)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		src string
+		pos int
+	}{
+		{`"login page`, 1},
+		{`status:`, 8},
+		{`- status:open`, 1},
+		{`:open`, 1},
+		{`status<open`, 1},
+		{`label:bug`, 1},
+		{`status:open priority:asap`, 22},
+		{`priority:low,asap`, 14},
+		{`due:7d`, 5},
+		{`due<someday`, 5},
+		{`is:open -sort:due`, 9},
+		{`sort:due,size`, 10},
+		{`assignee:me`, 10},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.src)
+		if err == nil {
+			_, err = Compile(q, Env{Now: time.Now(), Location: time.UTC})
+		}
+		var qErr *Error
+		if !errors.As(err, &qErr) {
+			t.Errorf("%q: expected an error, got %v", tt.src, err)
+			continue
+		}
+		if qErr.Pos != tt.pos {
+			t.Errorf("%q: expected error at %d, got %v", tt.src, tt.pos, qErr)
+		}
+	}
+}
+
+func TestCompile(t *testing.T) {
+	now := time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	caller := 3
+	env := Env{Now: now, Location: tokyo, Caller: &caller}
+	const text = `(title LIKE ? ESCAPE '\' OR COALESCE(description, '') LIKE ? ESCAPE '\')`
+	tests := []struct {
+		src   string
+		where string
+		args  []interface{}
+	}{
+		{``, ``, []interface{}{}},
+		{`status:open,review`, `(completed = false OR status = ?)`, []interface{}{"review"}},
+		{`priority>=high`, `priority >= ?`, []interface{}{3}},
+		{`project:OPS assignee:me`, `project_id IN (SELECT id FROM project WHERE key = ?) AND ` +
+			`id IN (SELECT task_id FROM task_assignee WHERE user_id = ?)`, []interface{}{"OPS", 3}},
+		{`due<7d`, `due_date < ?`, []interface{}{now.Add(7 * 24 * time.Hour)}},
+		{`-due<7d`, `NOT COALESCE((due_date < ?), false)`, []interface{}{now.Add(7 * 24 * time.Hour)}},
+		{`tag:Bug -tag:wontfix`, `EXISTS (SELECT 1 FROM task_tag WHERE task_id = task.id AND tag = ?) AND ` +
+			`NOT COALESCE((EXISTS (SELECT 1 FROM task_tag WHERE task_id = task.id AND tag = ?)), false)`,
+			[]interface{}{"bug", "wontfix"}},
+		// Days are in the caller's zone, where it is already evening
+		{`due:today`, `(due_date >= ? AND due_date < ?)`, []interface{}{
+			time.Date(2024, 11, 19, 15, 0, 0, 0, time.UTC), time.Date(2024, 11, 20, 15, 0, 0, 0, time.UTC)}},
+		{`created<=2024-11-01 completed:none`, `created_at < ? AND completed_at IS NULL`, []interface{}{
+			time.Date(2024, 11, 1, 15, 0, 0, 0, time.UTC)}},
+		// Text is matched literally and never becomes SQL
+		{`"100%_done"`, text, []interface{}{`%100\%\_done%`, `%100\%\_done%`}},
+		{`"'; DROP TABLE task; --"`, text, []interface{}{`%'; DROP TABLE task; --%`, `%'; DROP TABLE task; --%`}},
+	}
+	for _, tt := range tests {
+		q, err := Parse(tt.src)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.src, err)
+		}
+		filter, err := Compile(q, env)
+		if err != nil {
+			t.Fatalf("Compile(%q): %v", tt.src, err)
+		}
+		if filter.Where != tt.where || !reflect.DeepEqual(filter.Args, tt.args) {
+			t.Errorf("Compile(%q):\n got %s %v\nwant %s %v", tt.src, filter.Where, filter.Args, tt.where, tt.args)
+		}
+	}
+}
+
+func TestCompileSort(t *testing.T) {
+	q, _ := Parse(`sort:-priority,due sort:id`)
+	filter, err := Compile(q, Env{Now: time.Now(), Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	want := []SortKey{{"priority", true}, {"due_date", false}, {"id", false}}
+	if filter.Where != "" || !reflect.DeepEqual(filter.Sort, want) {
+		t.Errorf("Expected only sort keys %+v, got %+v", want, filter)
+	}
+}
@@ -0,0 +1,102 @@
+// Package rank generates ordering keys for manually sorted lists. Keys are
+// base 36 fractions compared as plain strings, so a key between any two
+// others can be made without renumbering the rest of the list.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidRange = errors.New("rank: keys are not in order")
+
+// Between returns a key that sorts after a and before b. An empty a means
+// the start of the list and an empty b its end.
+func Between(a string, b string) (string, error) {
+	if !valid(a) || !valid(b) || (b != "" && a >= b) {
+		return "", ErrInvalidRange
+	}
+	return midpoint(a, b), nil
+}
+
+// After returns a key that sorts after a
+func After(a string) (string, error) {
+	return Between(a, "")
+}
+
+// midpoint follows the fractional indexing scheme: copy the common prefix,
+// then pick the middle digit or descend one digit further
+func midpoint(a string, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(tail(a, n), b[n:])
+		}
+	}
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(digits, a[0])
+	}
+	digitB := len(digits)
+	if b != "" {
+		digitB = strings.IndexByte(digits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(digits[(digitA+digitB+1)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(digits[digitA]) + midpoint(tail(a, 1), "")
+}
+
+// Spread returns n ascending keys of equal length spaced evenly over the
+// key space, used to rebalance a list whose keys have grown long
+func Spread(n int) []string {
+	width, space := 1, len(digits)
+	for space <= n {
+		width++
+		space *= len(digits)
+	}
+	keys := make([]string, n)
+	for i := range keys {
+		value := (i + 1) * space / (n + 1)
+		key := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			key[j] = digits[value%len(digits)]
+			value /= len(digits)
+		}
+		keys[i] = strings.TrimRight(string(key), "0")
+	}
+	return keys
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return '0'
+}
+
+func tail(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
+
+// valid reports whether s only uses key digits and does not end in the
+// smallest one, which would leave no room before it
+func valid(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(digits, s[i]) < 0 {
+			return false
+		}
+	}
+	return !strings.HasSuffix(s, "0")
+}
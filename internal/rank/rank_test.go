@@ -0,0 +1,83 @@
+package rank
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", "i"},
+		{"i", "", "r"},
+		{"", "i", "9"},
+		{"a", "b", "ai"},
+		{"a", "a1", "a0i"},
+		{"z", "", "zi"},
+		{"az", "b", "azi"},
+		{"a1", "a2", "a1i"},
+	}
+	for _, tt := range tests {
+		got, err := Between(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("Between(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+	for _, bad := range [][2]string{{"b", "a"}, {"a", "a"}, {"a0", ""}, {"A", ""}} {
+		if _, err := Between(bad[0], bad[1]); err != ErrInvalidRange {
+			t.Errorf("Between(%q, %q): expected ErrInvalidRange, got %v", bad[0], bad[1], err)
+		}
+	}
+}
+
+func TestRandomInserts(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	keys := []string{}
+	for i := 0; i < 2000; i++ {
+		at := rng.Intn(len(keys) + 1)
+		a, b := "", ""
+		if at > 0 {
+			a = keys[at-1]
+		}
+		if at < len(keys) {
+			b = keys[at]
+		}
+		key, err := Between(a, b)
+		if err != nil {
+			t.Fatalf("Between(%q, %q): %v", a, b, err)
+		}
+		if key <= a || (b != "" && key >= b) {
+			t.Fatalf("Between(%q, %q) = %q is out of order", a, b, key)
+		}
+		keys = append(keys[:at], append([]string{key}, keys[at:]...)...)
+	}
+	if !sort.StringsAreSorted(keys) {
+		t.Errorf("Keys are not sorted")
+	}
+}
+
+func TestSpread(t *testing.T) {
+	for _, n := range []int{0, 1, 35, 36, 1000} {
+		keys := Spread(n)
+		if len(keys) != n {
+			t.Fatalf("Spread(%d) returned %d keys", n, len(keys))
+		}
+		for i, key := range keys {
+			if !valid(key) || key == "" {
+				t.Fatalf("Spread(%d) returned invalid key %q", n, key)
+			}
+			if i > 0 && keys[i-1] >= key {
+				t.Fatalf("Spread(%d) keys %q and %q are out of order", n, keys[i-1], key)
+			}
+		}
+	}
+	if keys := Spread(1000); len(keys[500]) > 2 {
+		t.Errorf("Expected 1000 keys to fit in 2 digits, got %q", keys[500])
+	}
+}
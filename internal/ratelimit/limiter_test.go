@@ -0,0 +1,144 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"todo-api/internal/clock/fakeclock"
+	"todo-api/internal/problems"
+
+	"github.com/labstack/echo/v4"
+)
+
+func newServer(clock *fakeclock.Clock, read Limit, write Limit) *echo.Echo {
+	limiter := NewLimiter("test", NewMemoryStore(), read, write)
+	limiter.Clock = clock
+	limiter.SetAPIKeys([]string{"secret"})
+	e := echo.New()
+	e.HTTPErrorHandler = problems.HTTPErrorHandler
+	g := e.Group("", limiter.Middleware())
+	ok := func(c echo.Context) error { return c.NoContent(http.StatusOK) }
+	g.GET("/tasks", ok)
+	g.POST("/tasks", ok)
+	return e
+}
+
+func do(e *echo.Echo, method string, apiKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/tasks", nil)
+	if apiKey != "" {
+		req.Header.Set(HeaderAPIKey, apiKey)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := fakeclock.New(time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC))
+	e := newServer(clock, Limit{Rate: 10, Burst: 10}, Limit{Rate: 1, Burst: 2})
+
+	// Burst of writes is allowed, then rejected
+	for i, remaining := range []string{"1", "0"} {
+		rec := do(e, http.MethodPost, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Write %d: expected 200, got %d", i, rec.Code)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("Write %d: expected RateLimit-Remaining %s, got %s", i, remaining, got)
+		}
+	}
+	rec := do(e, http.MethodPost, "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("Expected Retry-After 1, got %s", got)
+	}
+	if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+		t.Errorf("Expected RateLimit-Limit 2, got %s", got)
+	}
+
+	// Reads have their own bucket
+	if rec := do(e, http.MethodGet, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected read to be allowed, got %d", rec.Code)
+	}
+	// A known API key has its own bucket
+	if rec := do(e, http.MethodPost, "secret"); rec.Code != http.StatusOK {
+		t.Errorf("Expected other client to be allowed, got %d", rec.Code)
+	}
+
+	// One token is refilled after a second
+	clock.Advance(time.Second)
+	if rec := do(e, http.MethodPost, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected write after refill, got %d", rec.Code)
+	}
+	if rec := do(e, http.MethodPost, ""); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 after using refilled token, got %d", rec.Code)
+	}
+}
+
+func TestSetLimits(t *testing.T) {
+	clock := fakeclock.New(time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC))
+	limiter := NewLimiter("test", NewMemoryStore(), Limit{}, Limit{Rate: 1, Burst: 1})
+	limiter.Clock = clock
+	e := echo.New()
+	e.HTTPErrorHandler = problems.HTTPErrorHandler
+	e.POST("/tasks", func(c echo.Context) error { return c.NoContent(http.StatusOK) }, limiter.Middleware())
+
+	do(e, http.MethodPost, "")
+	if rec := do(e, http.MethodPost, ""); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d", rec.Code)
+	}
+	// Zero rate disables the limit
+	limiter.SetLimits(Limit{}, Limit{})
+	if rec := do(e, http.MethodPost, ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected disabled limit to allow, got %d", rec.Code)
+	}
+}
+
+func TestUnknownClientKeysShareTheIPBucket(t *testing.T) {
+	clock := fakeclock.New(time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC))
+	e := newServer(clock, Limit{}, Limit{Rate: 1, Burst: 2})
+
+	// Rotating the API key or X-Forwarded-For does not reset the limit
+	for i, header := range []string{HeaderAPIKey, HeaderAPIKey, echo.HeaderXForwardedFor} {
+		req := httptest.NewRequest(http.MethodPost, "/tasks", nil)
+		req.Header.Set(header, fmt.Sprintf("10.0.0.%d", i))
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if want := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}[i]; rec.Code != want {
+			t.Errorf("Request %d with a new %s: expected %d, got %d", i, header, want, rec.Code)
+		}
+	}
+}
+
+func TestTrustedProxy(t *testing.T) {
+	extract, err := NewIPExtractor([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatalf("NewIPExtractor: %v", err)
+	}
+	tests := []struct {
+		remote string
+		xff    string
+		want   string
+	}{
+		{"10.1.2.3:1234", "203.0.113.7", "203.0.113.7"},
+		{"192.168.1.1:1234", "203.0.113.7, 10.0.0.1", "203.0.113.7"},
+		// Other peers cannot claim an address
+		{"198.51.100.1:1234", "203.0.113.7", "198.51.100.1"},
+		{"192.168.1.2:1234", "203.0.113.7", "192.168.1.2"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+		req.RemoteAddr = tt.remote
+		req.Header.Set(echo.HeaderXForwardedFor, tt.xff)
+		if got := extract(req); got != tt.want {
+			t.Errorf("%s via %s: expected %s, got %s", tt.xff, tt.remote, tt.want, got)
+		}
+	}
+	if _, err := NewIPExtractor([]string{"proxy.local"}); err == nil {
+		t.Errorf("Expected an error for a host name")
+	}
+}
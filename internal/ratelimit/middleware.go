@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/problems"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/zerolog"
+)
+
+const HeaderAPIKey = "X-API-Key"
+
+// Limiter applies separate read and write limits to one route group.
+// Limits and API keys can be swapped at runtime with SetLimits and
+// SetAPIKeys.
+type Limiter struct {
+	Group string
+	Store Store
+	Clock clock.Clock
+	// Finds the client's address, the connection's remote address unless
+	// set to trust a proxy
+	IPExtractor echo.IPExtractor
+	read        atomic.Pointer[Limit]
+	write       atomic.Pointer[Limit]
+	apiKeys     atomic.Pointer[map[[sha256.Size]byte]bool]
+}
+
+func NewLimiter(group string, store Store, read Limit, write Limit) *Limiter {
+	l := &Limiter{Group: group, Store: store, Clock: clock.New(), IPExtractor: echo.ExtractIPDirect()}
+	l.SetLimits(read, write)
+	l.SetAPIKeys(nil)
+	return l
+}
+
+func (l *Limiter) SetLimits(read Limit, write Limit) {
+	l.read.Store(&read)
+	l.write.Store(&write)
+}
+
+// SetAPIKeys sets the API keys that get a bucket of their own, any other
+// X-API-Key is ignored
+func (l *Limiter) SetAPIKeys(keys []string) {
+	hashes := map[[sha256.Size]byte]bool{}
+	for _, key := range keys {
+		hashes[sha256.Sum256([]byte(key))] = true
+	}
+	l.apiKeys.Store(&hashes)
+}
+
+// clientKey identifies the caller by API key if it is a known one,
+// otherwise by IP, so made up keys cannot get fresh buckets. API keys are
+// hashed so they never end up in a shared store.
+func (l *Limiter) clientKey(c echo.Context) string {
+	if apiKey := c.Request().Header.Get(HeaderAPIKey); apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		if (*l.apiKeys.Load())[sum] {
+			return "key:" + hex.EncodeToString(sum[:8])
+		}
+	}
+	return "ip:" + l.IPExtractor(c.Request())
+}
+
+// NewIPExtractor finds client addresses behind the given proxies, as IPs
+// or CIDRs: X-Forwarded-For is only read from them. Without proxies it is
+// the connection's remote address.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range trustedProxies {
+		ipNet, err := ParseProxy(proxy)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// ParseProxy parses a trusted proxy given as an IP or a CIDR
+func ParseProxy(proxy string) (*net.IPNet, error) {
+	if ip := net.ParseIP(proxy); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip.To4() != nil {
+			ip, bits = ip.To4(), 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy %q, use an IP or a CIDR", proxy)
+	}
+	return ipNet, nil
+}
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func (l *Limiter) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			kind, limit := "read", l.read.Load()
+			if !isRead(c.Request().Method) {
+				kind, limit = "write", l.write.Load()
+			}
+			// Non-positive rate disables the limit
+			if limit.Rate <= 0 {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			key := l.Group + ":" + kind + ":" + l.clientKey(c)
+			res, err := l.Store.Take(ctx, key, *limit, l.Clock.Now())
+			if err != nil {
+				// Fail open, an unavailable store must not take the API down
+				zerolog.Ctx(ctx).Error().Err(err).Msg("failed to check rate limit")
+				return next(c)
+			}
+
+			header := c.Response().Header()
+			header.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			header.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			header.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+			if !res.Allowed {
+				header.Set("Retry-After", ceilSeconds(res.RetryAfter))
+				return problems.New(http.StatusTooManyRequests, problems.TypeRateLimited, "rate limit exceeded")
+			}
+			return next(c)
+		}
+	}
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
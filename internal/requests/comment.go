@@ -0,0 +1,6 @@
+package requests
+
+// CommentRequest carries the Markdown body of a new or edited comment
+type CommentRequest struct {
+	Body *string `json:"body" validate:"required,max=10000"`
+}
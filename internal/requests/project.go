@@ -0,0 +1,24 @@
+package requests
+
+type PostProjectRequest struct {
+	Key  *string `json:"key" validate:"required,alphanum,max=20"`
+	Name *string `json:"name" validate:"required,max=200"`
+	// Missing uses the default backlog, todo, in_progress, review, done
+	Workflow *WorkflowRequest `json:"workflow"`
+}
+
+type WorkflowRequest struct {
+	States      []StateRequest      `json:"states" validate:"required,max=20,dive"`
+	Transitions []TransitionRequest `json:"transitions" validate:"max=200,dive"`
+}
+
+type StateRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Initial  bool   `json:"initial"`
+	Terminal bool   `json:"terminal"`
+}
+
+type TransitionRequest struct {
+	From string `json:"from" validate:"required"`
+	To   string `json:"to" validate:"required"`
+}
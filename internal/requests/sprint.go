@@ -0,0 +1,12 @@
+package requests
+
+type PostSprintRequest struct {
+	// Missing uses the default project
+	ProjectID *int    `json:"project_id" validate:"omitempty,min=1"`
+	Name      *string `json:"name" validate:"required,max=200"`
+	// YYYY-MM-DD, both days included
+	StartDate *string `json:"start_date" validate:"required"`
+	EndDate   *string `json:"end_date" validate:"required"`
+	// Zone the dates are in, missing uses the caller's zone
+	TimeZone *string `json:"time_zone"`
+}
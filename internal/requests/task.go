@@ -1,17 +1,66 @@
 package requests
 
 type PutTaskRequest struct {
-	Title       *string `json:"title" validate:"required"`
-	Description *string `json:"description" validate:"required"`
+	Title       *string `json:"title" validate:"required,max=200"`
+	Description *string `json:"description" validate:"required,max=5000"`
 	DueDate     *string `json:"due_date" validate:"required"`
+	TimeZone    *string `json:"time_zone"`
+	// Lead times such as 24h or 90m, missing keeps the current ones
+	Reminders *[]string `json:"reminders" validate:"omitempty,max=10"`
+	Priority  *string   `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+	// Missing keeps the current estimate
+	EstimatePoints  *int `json:"estimate_points" validate:"omitempty,min=0,max=1000"`
+	EstimateMinutes *int `json:"estimate_minutes" validate:"omitempty,min=0,max=100000"`
 }
 
 type PostTaskRequest struct {
-	Title       *string `json:"title" validate:"required"`
-	Description *string `json:"description"`
+	// Missing uses the default project
+	ProjectID   *int    `json:"project_id" validate:"omitempty,min=1"`
+	Title       *string `json:"title" validate:"required,max=200"`
+	Description *string `json:"description" validate:"omitempty,max=5000"`
 	DueDate     *string `json:"due_date"`
+	TimeZone    *string `json:"time_zone"`
+	// Lead times such as 24h or 90m, missing uses the configured default
+	Reminders *[]string `json:"reminders" validate:"omitempty,max=10"`
+	Priority  *string   `json:"priority" validate:"omitempty,oneof=none low medium high urgent"`
+	// Missing leaves the task unestimated
+	EstimatePoints  *int `json:"estimate_points" validate:"omitempty,min=0,max=1000"`
+	EstimateMinutes *int `json:"estimate_minutes" validate:"omitempty,min=0,max=100000"`
 }
 
 type PatchTaskRequest struct {
 	Completed bool `json:"completed" validate:"required"`
 }
+
+// MoveTaskRequest places a task on the board, directly below the After card
+// or above the Before card, at the end of the column when neither is set
+type MoveTaskRequest struct {
+	// Target column, missing keeps the current one
+	Status *string `json:"status" validate:"omitempty,max=50"`
+	After  *int    `json:"after"`
+	Before *int    `json:"before"`
+}
+
+type TransitionTaskRequest struct {
+	Status *string `json:"status" validate:"required,max=50"`
+}
+
+type AssignTaskRequest struct {
+	UserID *int `json:"user_id" validate:"required,min=1"`
+}
+
+// SetSprintRequest puts a task into a sprint, a null sprint_id takes it out
+type SetSprintRequest struct {
+	SprintID *int `json:"sprint_id" validate:"omitempty,min=1"`
+}
+
+// SetTagsRequest replaces the tags of a task, an empty list removes them
+type SetTagsRequest struct {
+	Tags *[]string `json:"tags" validate:"required,max=20"`
+}
+
+// ParseDateRequest previews the due date a task request would get
+type ParseDateRequest struct {
+	DueDate  *string `json:"due_date" validate:"required,max=100"`
+	TimeZone *string `json:"time_zone"`
+}
@@ -0,0 +1,13 @@
+package requests
+
+type StartTimerRequest struct {
+	Note *string `json:"note" validate:"omitempty,max=1000"`
+}
+
+// TimeEntryRequest logs or corrects work on a task, times are RFC 3339
+type TimeEntryRequest struct {
+	StartedAt *string `json:"started_at" validate:"required"`
+	// Required for new entries, missing on an update keeps a timer running
+	EndedAt *string `json:"ended_at"`
+	Note    *string `json:"note" validate:"omitempty,max=1000"`
+}
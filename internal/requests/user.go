@@ -0,0 +1,15 @@
+package requests
+
+type PostUserRequest struct {
+	Username *string `json:"username" validate:"required,alphanum,max=50"`
+	Name     *string `json:"name" validate:"required,max=200"`
+	Email    *string `json:"email" validate:"required,email,max=320"`
+	TimeZone *string `json:"time_zone"`
+}
+
+type PutDigestRequest struct {
+	Enabled *bool `json:"enabled" validate:"required"`
+	// Local send time as HH:MM
+	Time     *string `json:"time" validate:"omitempty,datetime=15:04"`
+	TimeZone *string `json:"time_zone"`
+}
@@ -1,6 +1,9 @@
 package requests
 
 import (
+	"reflect"
+	"strings"
+
 	"github.com/go-playground/validator/v10"
 )
 
@@ -8,6 +11,19 @@ type CustomValidator struct {
 	Validator *validator.Validate
 }
 
+// NewValidator returns a validator that reports fields by their JSON names
+func NewValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return &CustomValidator{Validator: v}
+}
+
 func (cv *CustomValidator) Validate(i interface{}) error {
 	return cv.Validator.Struct(i)
 }
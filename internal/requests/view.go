@@ -0,0 +1,7 @@
+package requests
+
+type ViewRequest struct {
+	Name *string `json:"name" validate:"required,max=100"`
+	// A task query such as status:open due<7d
+	Query *string `json:"query" validate:"required,max=1000"`
+}
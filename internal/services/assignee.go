@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrUnknownUser = errors.New("user does not exist")
+	ErrNotMember   = errors.New("user is not a member of the task's project")
+)
+
+type IAssigneeService interface {
+	Assign(ctx context.Context, taskID int, userID int) (*models.Task, error)
+	Unassign(ctx context.Context, taskID int, userID int) (*models.Task, error)
+}
+
+type AssigneeService struct {
+	Tasks     repository.ITaskRepo
+	Assignees repository.IAssigneeRepo
+	Users     repository.IUserRepo
+	// Projects restricts assignees to project members, nil allows every
+	// user
+	Projects repository.IProjectRepo
+	Clock    clock.Clock
+}
+
+func NewAssigneeService(taskRepo repository.ITaskRepo, assigneeRepo repository.IAssigneeRepo,
+	userRepo repository.IUserRepo, projectRepo repository.IProjectRepo, clock clock.Clock) IAssigneeService {
+	return AssigneeService{taskRepo, assigneeRepo, userRepo, projectRepo, clock}
+}
+
+// checkUser fails with ErrUnknownUser for users that do not exist
+func checkUser(ctx context.Context, users repository.IUserRepo, userID int) error {
+	if _, err := users.GetByID(ctx, userID); err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			return ErrUnknownUser
+		}
+		return err
+	}
+	return nil
+}
+
+// Assign makes a user responsible for a task. A new assignment writes an
+// assignment notification to the outbox, assigning a user twice does
+// nothing.
+func (s AssigneeService) Assign(ctx context.Context, taskID int, userID int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "AssigneeService.Assign")
+	defer span.End()
+	task, err := s.Tasks.GetByID(ctx, taskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if err := checkUser(ctx, s.Users, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if s.Projects != nil {
+		allowed, err := s.Projects.CanAssign(ctx, *task.ProjectID, userID)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return nil, err
+		}
+		if !allowed {
+			return nil, ErrNotMember
+		}
+	}
+
+	assigned, err := s.Assignees.Assign(ctx, taskID, userID, s.Clock.Now())
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to assign task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if assigned {
+		zerolog.Ctx(ctx).Info().Int("task", taskID).Int("user", userID).Msg("task assigned")
+	}
+	return s.Tasks.GetByID(ctx, taskID)
+}
+
+// Unassign removes a user from the assignees of a task
+func (s AssigneeService) Unassign(ctx context.Context, taskID int, userID int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "AssigneeService.Unassign")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	removed, err := s.Assignees.Unassign(ctx, taskID, userID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to unassign task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if removed {
+		zerolog.Ctx(ctx).Info().Int("task", taskID).Int("user", userID).Msg("task unassigned")
+	}
+	return s.Tasks.GetByID(ctx, taskID)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+func TestAssign(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	projects := repository.NewProjectRepo(db)
+	s := NewAssigneeService(repository.NewTaskRepo(db), repository.NewAssigneeRepo(db), users, projects, clock)
+	for _, username := range []string{"ada", "bob"} {
+		name, email := username+" lovelace", username+"@example.com"
+		if err := users.Create(context.TODO(), &models.User{Username: &username, Name: &name, Email: &email}); err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+	}
+	first := createTask(t, tasks, nil, false)
+	createTask(t, tasks, nil, false)
+
+	assignees := func(task *models.Task) string {
+		names := []string{}
+		for _, assignee := range task.Assignees {
+			names = append(names, fmt.Sprintf("%d:%s", assignee.ID, assignee.Name))
+		}
+		return fmt.Sprint(names)
+	}
+	task, err := s.Assign(context.TODO(), *first.ID, 1)
+	if err != nil {
+		t.Fatalf("Error assigning task: %v", err)
+	}
+	if _, err := s.Assign(context.TODO(), *first.ID, 1); err != nil {
+		t.Fatalf("Error assigning task again: %v", err)
+	}
+	if task, err = s.Assign(context.TODO(), *first.ID, 2); err != nil {
+		t.Fatalf("Error assigning task: %v", err)
+	}
+	if got := assignees(task); got != "[1:ada lovelace 2:bob lovelace]" {
+		t.Errorf("Unexpected assignees %s", got)
+	}
+
+	// Every new assignment is in the outbox once
+	pending, err := repository.NewNotificationRepo(db).GetPending(context.TODO(), 5, 10)
+	if err != nil {
+		t.Fatalf("Error getting notifications: %v", err)
+	}
+	if len(pending) != 2 || pending[0].Kind != models.NotificationAssignment || pending[1].UserID != 2 ||
+		*pending[1].UserName != "bob lovelace" {
+		t.Errorf("Expected an assignment notification per user, got %+v", pending)
+	}
+
+	bob := 2
+	for _, tt := range []struct {
+		q    repository.TaskQuery
+		want int
+	}{
+		{repository.TaskQuery{Assignee: &bob}, 1},
+		{repository.TaskQuery{Unassigned: true}, 1},
+	} {
+		got, err := tasks.GetTasks(context.TODO(), tt.q)
+		if err != nil {
+			t.Fatalf("Error getting tasks: %v", err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("Expected %d tasks for %+v, got %d", tt.want, tt.q, len(got))
+		}
+	}
+
+	if task, err = s.Unassign(context.TODO(), *first.ID, 1); err != nil {
+		t.Fatalf("Error unassigning task: %v", err)
+	}
+	if got := assignees(task); got != "[2:bob lovelace]" {
+		t.Errorf("Unexpected assignees after unassigning %s", got)
+	}
+
+	if _, err := s.Assign(context.TODO(), *first.ID, 99); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+	if _, err := s.Assign(context.TODO(), 99, 1); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+
+	// Once the project has members only they can be assigned
+	if err := NewProjectService(projects, users).AddMember(context.TODO(), 1, 2); err != nil {
+		t.Fatalf("Error adding member: %v", err)
+	}
+	if _, err := s.Assign(context.TODO(), *first.ID, 1); !errors.Is(err, ErrNotMember) {
+		t.Errorf("Expected ErrNotMember, got %v", err)
+	}
+	if err := NewProjectService(projects, users).AddMember(context.TODO(), 1, 99); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser for an unknown member, got %v", err)
+	}
+
+	// Removing a member unassigns them from the project's tasks only
+	key, name := "OPS", "Operations"
+	other := &models.Project{Key: &key, Name: &name}
+	if err := NewProjectService(projects, users).CreateProject(context.TODO(), other); err != nil {
+		t.Fatalf("Error creating project: %v", err)
+	}
+	title := "elsewhere"
+	elsewhere := &models.Task{ProjectID: other.ID, Title: &title}
+	if err := tasks.CreateTask(context.TODO(), elsewhere); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	if _, err := s.Assign(context.TODO(), *elsewhere.ID, 2); err != nil {
+		t.Fatalf("Error assigning task: %v", err)
+	}
+	if err := NewProjectService(projects, users).RemoveMember(context.TODO(), 1, 2); err != nil {
+		t.Fatalf("Error removing member: %v", err)
+	}
+	for _, tt := range []struct {
+		id   int
+		want string
+	}{{*first.ID, "[]"}, {*elsewhere.ID, "[2:bob lovelace]"}} {
+		task, err := tasks.GetTask(context.TODO(), tt.id)
+		if err != nil {
+			t.Fatalf("Error getting task: %v", err)
+		}
+		if got := assignees(task); got != tt.want {
+			t.Errorf("Expected assignees %s of task %d after removing the member, got %s", tt.want, tt.id, got)
+		}
+	}
+}
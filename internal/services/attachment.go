@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/storage"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrFileTooLarge       = errors.New("file is larger than the per-file limit")
+	ErrAttachmentQuota    = errors.New("attachments of the task would exceed the per-task limit")
+	ErrAttachmentContents = errors.New("attachment content is missing from the blob store")
+)
+
+// AttachmentLimits caps attachment sizes in bytes
+type AttachmentLimits struct {
+	MaxFileSize int64
+	MaxTaskSize int64
+}
+
+type IAttachmentService interface {
+	CreateAttachment(ctx context.Context, attachment *models.Attachment, content io.ReadSeeker) error
+	GetAttachments(ctx context.Context, taskID int) ([]models.Attachment, error)
+	OpenAttachment(ctx context.Context, taskID int, id int) (*models.Attachment, io.ReadSeekCloser, error)
+	DeleteAttachment(ctx context.Context, taskID int, id int) error
+	DeleteWithTask(ctx context.Context, taskID int, deleteTask func(ctx context.Context) error) error
+}
+
+type AttachmentService struct {
+	Attachments repository.IAttachmentRepo
+	Tasks       repository.ITaskRepo
+	Blobs       storage.BlobStore
+	Clock       clock.Clock
+	Limits      AttachmentLimits
+	// mu orders storing and removing blobs so a blob is never removed
+	// between an upload finding it and referencing it, and keeps concurrent
+	// uploads from overrunning the per-task limit together
+	mu *sync.Mutex
+}
+
+func NewAttachmentService(attachmentRepo repository.IAttachmentRepo, taskRepo repository.ITaskRepo,
+	blobs storage.BlobStore, clock clock.Clock, limits AttachmentLimits) IAttachmentService {
+	return AttachmentService{attachmentRepo, taskRepo, blobs, clock, limits, &sync.Mutex{}}
+}
+
+// digest hashes and measures content and sniffs its MIME type, it reads at
+// most one byte past the per-file limit
+func (s AttachmentService) digest(content io.ReadSeeker) (string, int64, string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(content, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", 0, "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", 0, "", err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, io.LimitReader(content, s.Limits.MaxFileSize+1))
+	if err != nil {
+		return "", 0, "", err
+	}
+	if size > s.Limits.MaxFileSize {
+		return "", 0, "", ErrFileTooLarge
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", 0, "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, http.DetectContentType(head[:n]), nil
+}
+
+// CreateAttachment stores content in the blob store, unless a blob with
+// the same content exists already, and records its metadata for the task
+func (s AttachmentService) CreateAttachment(ctx context.Context, attachment *models.Attachment,
+	content io.ReadSeeker) error {
+	ctx, span := tracer.Start(ctx, "AttachmentService.CreateAttachment")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, attachment.TaskID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	digest, size, mimeType, err := s.digest(content)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	attachment.SHA256, attachment.Size, attachment.MimeType = digest, size, mimeType
+	attachment.CreatedAt = s.Clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total, err := s.Attachments.TotalSize(ctx, attachment.TaskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if total+size > s.Limits.MaxTaskSize {
+		return ErrAttachmentQuota
+	}
+	if err := s.Blobs.Put(ctx, digest, content); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to store blob %s", digest)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Attachments.Create(ctx, attachment); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to attach file to task with id %d", attachment.TaskID)
+		telemetry.RecordError(span, err)
+		// A new blob nobody references would never be removed
+		s.release(ctx, digest)
+		return err
+	}
+	return nil
+}
+
+func (s AttachmentService) GetAttachments(ctx context.Context, taskID int) ([]models.Attachment, error) {
+	ctx, span := tracer.Start(ctx, "AttachmentService.GetAttachments")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	attachments, err := s.Attachments.GetByTask(ctx, taskID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get attachments of task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// OpenAttachment returns an attachment's metadata and content, the caller
+// closes the content
+func (s AttachmentService) OpenAttachment(ctx context.Context, taskID int, id int) (*models.Attachment,
+	io.ReadSeekCloser, error) {
+	ctx, span := tracer.Start(ctx, "AttachmentService.OpenAttachment")
+	defer span.End()
+	attachment, err := s.Attachments.GetByID(ctx, taskID, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, nil, err
+	}
+	content, err := s.Blobs.Open(ctx, attachment.SHA256)
+	if err != nil {
+		if errors.Is(err, storage.ErrBlobNotFound) {
+			zerolog.Ctx(ctx).Error().Msgf("blob %s of attachment with id %d is missing", attachment.SHA256, id)
+			err = ErrAttachmentContents
+		}
+		telemetry.RecordError(span, err)
+		return nil, nil, err
+	}
+	return attachment, content, nil
+}
+
+func (s AttachmentService) DeleteAttachment(ctx context.Context, taskID int, id int) error {
+	ctx, span := tracer.Start(ctx, "AttachmentService.DeleteAttachment")
+	defer span.End()
+	attachment, err := s.Attachments.GetByID(ctx, taskID, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.Attachments.Delete(ctx, id); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to delete attachment with id %d", id)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	s.release(ctx, attachment.SHA256)
+	return nil
+}
+
+// DeleteWithTask runs deleteTask, which removes the task together with its
+// attachments, and then removes the blobs no other attachment shares
+func (s AttachmentService) DeleteWithTask(ctx context.Context, taskID int, deleteTask func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, "AttachmentService.DeleteWithTask")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	attachments, err := s.Attachments.GetByTask(ctx, taskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := deleteTask(ctx); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	for _, attachment := range attachments {
+		s.release(ctx, attachment.SHA256)
+	}
+	return nil
+}
+
+// release removes a blob once no attachment references it. Failures are
+// only logged, the attachment is gone either way. Callers hold mu.
+func (s AttachmentService) release(ctx context.Context, digest string) {
+	referenced, err := s.Attachments.IsReferenced(ctx, digest)
+	if err == nil && !referenced {
+		err = s.Blobs.Delete(ctx, digest)
+	}
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to release blob %s", digest)
+	}
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/storage"
+)
+
+func TestAttachments(t *testing.T) {
+	_, db, clock := newServiceDB(t)
+	blobs, err := storage.NewFileStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatalf("Error creating blob store: %v", err)
+	}
+	taskRepo := repository.NewTaskRepo(db)
+	s := NewAttachmentService(repository.NewAttachmentRepo(db), taskRepo, blobs, clock,
+		AttachmentLimits{MaxFileSize: 16, MaxTaskSize: 24})
+	tasks := NewTaskService(taskRepo, nil, nil, s, clock)
+	first := createTask(t, tasks, nil, false)
+	second := createTask(t, tasks, nil, false)
+
+	attach := func(taskID int, name string, content string) (*models.Attachment, error) {
+		attachment := &models.Attachment{TaskID: taskID, Name: name}
+		return attachment, s.CreateAttachment(context.TODO(), attachment, strings.NewReader(content))
+	}
+	blobExists := func(digest string) bool {
+		blob, err := blobs.Open(context.TODO(), digest)
+		if err == nil {
+			blob.Close()
+		}
+		return err == nil
+	}
+
+	log, err := attach(*first.ID, "build.log", "build failed\n")
+	if err != nil {
+		t.Fatalf("Error attaching file: %v", err)
+	}
+	if log.Size != 13 || log.MimeType != "text/plain; charset=utf-8" || len(log.SHA256) != 64 || !log.CreatedAt.Equal(now) {
+		t.Errorf("Unexpected attachment %+v", log)
+	}
+	png, err := attach(*first.ID, "shot.png", "\x89PNG\r\n\x1a\n")
+	if err != nil {
+		t.Fatalf("Error attaching file: %v", err)
+	}
+	if png.MimeType != "image/png" {
+		t.Errorf("Expected image/png, got %s", png.MimeType)
+	}
+
+	// The same content on another task shares the blob
+	copied, err := attach(*second.ID, "copy.log", "build failed\n")
+	if err != nil {
+		t.Fatalf("Error attaching file: %v", err)
+	}
+	if copied.SHA256 != log.SHA256 {
+		t.Errorf("Expected equal digests for equal content")
+	}
+
+	for _, tt := range []struct {
+		name    string
+		taskID  int
+		content string
+		want    error
+	}{
+		{"unknown task", 99, "a", repository.ErrTaskNotFound},
+		{"file too large", *second.ID, strings.Repeat("a", 17), ErrFileTooLarge},
+		{"task limit", *first.ID, "0123", ErrAttachmentQuota},
+	} {
+		if _, err := attach(tt.taskID, "f", tt.content); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	_, content, err := s.OpenAttachment(context.TODO(), *second.ID, copied.ID)
+	if err != nil {
+		t.Fatalf("Error opening attachment: %v", err)
+	}
+	got, _ := io.ReadAll(content)
+	content.Close()
+	if string(got) != "build failed\n" {
+		t.Errorf("Unexpected content %q", got)
+	}
+	if _, _, err := s.OpenAttachment(context.TODO(), *first.ID, copied.ID); !errors.Is(err, repository.ErrAttachmentNotFound) {
+		t.Errorf("Expected ErrAttachmentNotFound for another task's attachment, got %v", err)
+	}
+
+	// Blobs go once the last attachment using them does
+	if err := s.DeleteAttachment(context.TODO(), *second.ID, copied.ID); err != nil {
+		t.Fatalf("Error deleting attachment: %v", err)
+	}
+	if !blobExists(log.SHA256) {
+		t.Errorf("Expected the shared blob to stay")
+	}
+	if err := tasks.DeleteTask(context.TODO(), *first.ID); err != nil {
+		t.Fatalf("Error deleting task: %v", err)
+	}
+	if blobExists(log.SHA256) || blobExists(png.SHA256) {
+		t.Errorf("Expected the blobs of the deleted task to be removed")
+	}
+	if attachments, err := s.GetAttachments(context.TODO(), *second.ID); err != nil || len(attachments) != 0 {
+		t.Errorf("Expected no attachments left, got %v %v", attachments, err)
+	}
+
+	// Attachment rows go in the same transaction as their task, a task
+	// created again with its id does not inherit them
+	if _, err := attach(*second.ID, "notes.txt", "notes\n"); err != nil {
+		t.Fatalf("Error attaching file: %v", err)
+	}
+	if err := NewTaskService(taskRepo, nil, nil, nil, clock).DeleteTask(context.TODO(), *second.ID); err != nil {
+		t.Fatalf("Error deleting task: %v", err)
+	}
+	title := "again"
+	if err := tasks.CreateTask(context.TODO(), &models.Task{ID: second.ID, Title: &title}); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	if attachments, err := s.GetAttachments(context.TODO(), *second.ID); err != nil || len(attachments) != 0 {
+		t.Errorf("Expected a recreated task without attachments, got %v %v", attachments, err)
+	}
+}
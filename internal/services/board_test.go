@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+// column returns the ids of the tasks in a board column, in order
+func column(t *testing.T, s ITaskService, status string) []int {
+	board, err := s.GetBoard(context.TODO(), 1)
+	if err != nil {
+		t.Fatalf("Error getting board: %v", err)
+	}
+	for _, column := range board.Columns {
+		if column.Status == status {
+			ids := []int{}
+			for _, task := range column.Tasks {
+				ids = append(ids, *task.ID)
+			}
+			return ids
+		}
+	}
+	t.Fatalf("Board has no %s column", status)
+	return nil
+}
+
+func TestMove(t *testing.T) {
+	s, _ := newService(t)
+	for i := 0; i < 4; i++ {
+		createTask(t, s, nil, false)
+	}
+	if got := fmt.Sprint(column(t, s, "backlog")); got != "[1 2 3 4]" {
+		t.Fatalf("Expected new tasks in creation order, got %s", got)
+	}
+
+	id := func(v int) *int { return &v }
+	steps := []struct {
+		id      int
+		status  string
+		at      repository.Placement
+		wantErr error
+		backlog string
+		todo    string
+	}{
+		{4, "", repository.Placement{Before: id(1)}, nil, "[4 1 2 3]", "[]"},
+		{1, "", repository.Placement{After: id(3)}, nil, "[4 2 3 1]", "[]"},
+		{2, "", repository.Placement{After: id(4), Before: id(3)}, nil, "[4 2 3 1]", "[]"},
+		{3, "todo", repository.Placement{}, nil, "[4 2 1]", "[3]"},
+		{4, "todo", repository.Placement{Before: id(3)}, nil, "[2 1]", "[4 3]"},
+		{1, "", repository.Placement{Before: id(3)}, repository.ErrBadPlacement, "[2 1]", "[4 3]"},
+		{1, "", repository.Placement{After: id(1)}, repository.ErrBadPlacement, "[2 1]", "[4 3]"},
+		{2, "", repository.Placement{After: id(1), Before: id(2)}, repository.ErrBadPlacement, "[2 1]", "[4 3]"},
+		{2, "todo", repository.Placement{After: id(4), Before: id(3)}, nil, "[1]", "[4 2 3]"},
+		{1, "todo", repository.Placement{After: id(4), Before: id(3)}, repository.ErrBadPlacement, "[1]", "[4 2 3]"},
+		{4, "done", repository.Placement{}, models.ErrIllegalTransition, "[1]", "[4 2 3]"},
+	}
+	for i, step := range steps {
+		_, err := s.Move(context.TODO(), step.id, step.status, step.at)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("Step %d: expected error %v, got %v", i, step.wantErr, err)
+		}
+		if got := fmt.Sprint(column(t, s, "backlog")); got != step.backlog {
+			t.Errorf("Step %d: expected backlog %s, got %s", i, step.backlog, got)
+		}
+		if got := fmt.Sprint(column(t, s, "todo")); got != step.todo {
+			t.Errorf("Step %d: expected todo %s, got %s", i, step.todo, got)
+		}
+	}
+}
+
+func TestConcurrentMoves(t *testing.T) {
+	s, db, _ := newServiceDB(t)
+	for i := 0; i < 3; i++ {
+		createTask(t, s, nil, false)
+	}
+	// Every mover drops its card directly below the first one
+	first := 1
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			title := "card"
+			task := &models.Task{Title: &title}
+			if err := s.CreateTask(context.TODO(), task); err != nil {
+				errs <- err
+				return
+			}
+			_, err := s.Move(context.TODO(), *task.ID, "", repository.Placement{After: &first})
+			if err != nil && !errors.Is(err, repository.ErrMoveConflict) {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	var duplicates int
+	if err := db.Get(&duplicates, `
+        SELECT COUNT(*) FROM (SELECT position FROM task GROUP BY project_id, status, position HAVING COUNT(*) > 1)`); err != nil {
+		t.Fatalf("Error counting duplicates: %v", err)
+	}
+	if duplicates != 0 {
+		t.Errorf("Expected unique positions, got %d duplicates", duplicates)
+	}
+	if got := column(t, s, "backlog"); len(got) != 23 || got[0] != first {
+		t.Errorf("Expected 23 cards starting with %d, got %v", first, got)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	s, db, _ := newServiceDB(t)
+	for i := 0; i < 3; i++ {
+		createTask(t, s, nil, false)
+	}
+	// Moving cards into the same gap over and over grows the keys
+	after := 1
+	for i := 0; i < 30; i++ {
+		task := createTask(t, s, nil, false)
+		if _, err := s.Move(context.TODO(), *task.ID, "", repository.Placement{After: &after}); err != nil {
+			t.Fatalf("Error moving task: %v", err)
+		}
+		after = *task.ID
+	}
+	before := column(t, s, "backlog")
+
+	maxLength := func() int {
+		var length int
+		if err := db.Get(&length, `SELECT MAX(length(position)) FROM task`); err != nil {
+			t.Fatalf("Error reading positions: %v", err)
+		}
+		return length
+	}
+	if maxLength() <= 4 {
+		t.Fatalf("Expected keys longer than 4, got %d", maxLength())
+	}
+	moved, err := s.Rebalance(context.TODO(), 4)
+	if err != nil {
+		t.Fatalf("Error rebalancing: %v", err)
+	}
+	if moved != 33 {
+		t.Errorf("Expected 33 tasks moved, got %d", moved)
+	}
+	if maxLength() > 2 {
+		t.Errorf("Expected short keys after rebalancing, got length %d", maxLength())
+	}
+	if got := column(t, s, "backlog"); fmt.Sprint(got) != fmt.Sprint(before) {
+		t.Errorf("Rebalancing changed the order from %v to %v", before, got)
+	}
+	if moved, _ := s.Rebalance(context.TODO(), 4); moved != 0 {
+		t.Errorf("Expected nothing to rebalance, got %d", moved)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/markdown"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrNotAuthor = errors.New("only the author can change a comment")
+
+// CommentPage is one page of a task's comments, Next is the id to continue
+// after and nil on the last page
+type CommentPage struct {
+	Comments []models.Comment
+	Next     *int
+}
+
+type ICommentService interface {
+	CreateComment(ctx context.Context, comment *models.Comment) error
+	GetComments(ctx context.Context, taskID int, after int, limit int) (*CommentPage, error)
+	EditComment(ctx context.Context, comment *models.Comment, authorID int) error
+	DeleteComment(ctx context.Context, taskID int, id int, authorID int) error
+}
+
+type CommentService struct {
+	Comments repository.ICommentRepo
+	Tasks    repository.ITaskRepo
+	Users    repository.IUserRepo
+	Clock    clock.Clock
+}
+
+func NewCommentService(commentRepo repository.ICommentRepo, taskRepo repository.ITaskRepo,
+	userRepo repository.IUserRepo, clock clock.Clock) ICommentService {
+	return CommentService{commentRepo, taskRepo, userRepo, clock}
+}
+
+// CreateComment adds a comment to a task, the Markdown body is rendered to
+// sanitized HTML and the @usernames it mentions are recorded
+func (s CommentService) CreateComment(ctx context.Context, comment *models.Comment) error {
+	ctx, span := tracer.Start(ctx, "CommentService.CreateComment")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, comment.TaskID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := checkUser(ctx, s.Users, comment.AuthorID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	comment.BodyHTML = markdown.ToHTML(comment.Body)
+	comment.CreatedAt = s.Clock.Now()
+	if err := s.Comments.Create(ctx, comment, markdown.Mentions(comment.Body)); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to comment on task with id %d", comment.TaskID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// GetComments returns up to limit comments of a task, oldest first,
+// starting after the comment with id after
+func (s CommentService) GetComments(ctx context.Context, taskID int, after int, limit int) (*CommentPage, error) {
+	ctx, span := tracer.Start(ctx, "CommentService.GetComments")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	// One more than asked for tells whether another page follows
+	comments, err := s.Comments.GetPage(ctx, taskID, after, limit+1)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get comments of task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	page := &CommentPage{Comments: comments}
+	if len(comments) > limit {
+		page.Comments = comments[:limit]
+		page.Next = &page.Comments[limit-1].ID
+	}
+	return page, nil
+}
+
+// author loads a comment and fails with ErrNotAuthor unless it was written
+// by authorID
+func (s CommentService) author(ctx context.Context, taskID int, id int, authorID int) error {
+	comment, err := s.Comments.GetByID(ctx, taskID, id)
+	if err != nil {
+		return err
+	}
+	if comment.AuthorID != authorID {
+		return ErrNotAuthor
+	}
+	return nil
+}
+
+// EditComment replaces the body of a comment written by authorID
+func (s CommentService) EditComment(ctx context.Context, comment *models.Comment, authorID int) error {
+	ctx, span := tracer.Start(ctx, "CommentService.EditComment")
+	defer span.End()
+	if err := s.author(ctx, comment.TaskID, comment.ID, authorID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+
+	editedAt := s.Clock.Now()
+	comment.BodyHTML = markdown.ToHTML(comment.Body)
+	comment.EditedAt = &editedAt
+	if err := s.Comments.Update(ctx, comment, markdown.Mentions(comment.Body)); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to edit comment with id %d", comment.ID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteComment removes a comment written by authorID
+func (s CommentService) DeleteComment(ctx context.Context, taskID int, id int, authorID int) error {
+	ctx, span := tracer.Start(ctx, "CommentService.DeleteComment")
+	defer span.End()
+	if err := s.author(ctx, taskID, id, authorID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Comments.Delete(ctx, id); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to delete comment with id %d", id)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+func TestComments(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	s := NewCommentService(repository.NewCommentRepo(db), repository.NewTaskRepo(db), users, clock)
+	for _, username := range []string{"ada", "bob"} {
+		name, email := username+" lovelace", username+"@example.com"
+		if err := users.Create(context.TODO(), &models.User{Username: &username, Name: &name, Email: &email}); err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+	}
+	task := createTask(t, tasks, nil, false)
+	other := createTask(t, tasks, nil, false)
+
+	comment := &models.Comment{TaskID: *task.ID, AuthorID: 1,
+		Body: "Ping @bob and @nobody, see `@ada` <script>x</script>"}
+	if err := s.CreateComment(context.TODO(), comment); err != nil {
+		t.Fatalf("Error creating comment: %v", err)
+	}
+	if comment.AuthorName != "ada lovelace" || fmt.Sprint(comment.Mentions) != "[bob]" {
+		t.Errorf("Unexpected comment %+v", comment)
+	}
+	if want := "<p>Ping @bob and @nobody, see <code>@ada</code> &lt;script&gt;x&lt;/script&gt;</p>\n"; comment.BodyHTML != want {
+		t.Errorf("Expected %s, got %s", want, comment.BodyHTML)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		comment models.Comment
+		want    error
+	}{
+		{"unknown task", models.Comment{TaskID: 99, AuthorID: 1, Body: "a"}, repository.ErrTaskNotFound},
+		{"unknown author", models.Comment{TaskID: *task.ID, AuthorID: 9, Body: "a"}, ErrUnknownUser},
+	} {
+		if err := s.CreateComment(context.TODO(), &tt.comment); !errors.Is(err, tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, err)
+		}
+	}
+
+	// Only the author edits and deletes, and only on the comment's task
+	edit := &models.Comment{ID: comment.ID, TaskID: *task.ID, Body: "**done**, thanks @ada"}
+	if err := s.EditComment(context.TODO(), edit, 2); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Expected ErrNotAuthor, got %v", err)
+	}
+	if err := s.DeleteComment(context.TODO(), *other.ID, comment.ID, 1); !errors.Is(err, repository.ErrCommentNotFound) {
+		t.Errorf("Expected ErrCommentNotFound, got %v", err)
+	}
+	clock.Advance(time.Minute)
+	if err := s.EditComment(context.TODO(), edit, 1); err != nil {
+		t.Fatalf("Error editing comment: %v", err)
+	}
+	if edit.BodyHTML != "<p><strong>done</strong>, thanks @ada</p>\n" || fmt.Sprint(edit.Mentions) != "[ada]" ||
+		edit.EditedAt == nil || !edit.EditedAt.Equal(now.Add(time.Minute)) || !edit.CreatedAt.Equal(now) {
+		t.Errorf("Unexpected edited comment %+v", edit)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := s.CreateComment(context.TODO(), &models.Comment{TaskID: *task.ID, AuthorID: 2, Body: "more"}); err != nil {
+			t.Fatalf("Error creating comment: %v", err)
+		}
+	}
+	if err := s.DeleteComment(context.TODO(), *task.ID, comment.ID, 2); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("Expected ErrNotAuthor, got %v", err)
+	}
+	if err := s.DeleteComment(context.TODO(), *task.ID, comment.ID, 1); err != nil {
+		t.Fatalf("Error deleting comment: %v", err)
+	}
+
+	// Pages continue after the last comment of the previous one
+	pages, after := []string{}, 0
+	for {
+		page, err := s.GetComments(context.TODO(), *task.ID, after, 3)
+		if err != nil {
+			t.Fatalf("Error getting comments: %v", err)
+		}
+		ids := []int{}
+		for _, comment := range page.Comments {
+			ids = append(ids, comment.ID)
+		}
+		pages = append(pages, fmt.Sprint(ids))
+		if page.Next == nil {
+			break
+		}
+		after = *page.Next
+	}
+	if got := fmt.Sprint(pages); got != "[[2 3 4] [5]]" {
+		t.Errorf("Unexpected pages %s", got)
+	}
+
+	got, err := tasks.GetTasks(context.TODO(), repository.TaskQuery{})
+	if err != nil {
+		t.Fatalf("Error getting tasks: %v", err)
+	}
+	counts := map[int]int{}
+	for _, task := range got {
+		counts[*task.ID] = task.CommentCount
+	}
+	if counts[*task.ID] != 4 || counts[*other.ID] != 0 {
+		t.Errorf("Unexpected comment counts %v", counts)
+	}
+}
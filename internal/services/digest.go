@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/digest"
+	"todo-api/internal/mail"
+	"todo-api/internal/telemetry"
+	"todo-api/internal/timezone"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+type IDigestService interface {
+	SendDue(ctx context.Context) (int, error)
+}
+
+type DigestService struct {
+	Users  repository.IUserRepo
+	Tasks  repository.ITaskRepo
+	Mailer mail.Mailer
+	Clock  clock.Clock
+	From   string
+}
+
+func NewDigestService(userRepo repository.IUserRepo, taskRepo repository.ITaskRepo, mailer mail.Mailer,
+	clock clock.Clock, from string) IDigestService {
+	return DigestService{userRepo, taskRepo, mailer, clock, from}
+}
+
+// SendDue mails the digest of every opted-in user whose send time has
+// passed today and who has not had today's digest yet, and returns how many
+// were mailed. The send time is stored per user before the digest is
+// mailed, so neither a restart nor a failed write sends a digest twice; it
+// is cleared again when mailing fails. Users with nothing to list get no
+// email.
+func (s DigestService) SendDue(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "DigestService.SendDue")
+	defer span.End()
+	users, err := s.Users.GetDigestEnabled(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get digest recipients")
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+
+	now := s.Clock.Now()
+	sent := 0
+	var errs []error
+	for _, user := range users {
+		mailed, err := s.sendDigest(ctx, user, now)
+		if err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to send digest to user with id %d", *user.ID)
+			errs = append(errs, err)
+			continue
+		}
+		if mailed {
+			sent++
+		}
+	}
+
+	span.SetAttributes(attribute.Int("digest.sent", sent))
+	if sent > 0 {
+		zerolog.Ctx(ctx).Info().Int("sent", sent).Msg("digests sent")
+	}
+	if err := errors.Join(errs...); err != nil {
+		telemetry.RecordError(span, err)
+		return sent, err
+	}
+	return sent, nil
+}
+
+func (s DigestService) sendDigest(ctx context.Context, user models.User, now time.Time) (bool, error) {
+	loc, err := timezone.Load(*user.TimeZone)
+	if err != nil {
+		return false, err
+	}
+	sendAt, err := sendTime(*user.DigestTime, now, loc)
+	if err != nil {
+		return false, err
+	}
+	if now.Before(sendAt) || (user.DigestSentAt != nil && !user.DigestSentAt.Before(sendAt)) {
+		return false, nil
+	}
+
+	// Claim the digest before mailing it, a failed write then means no
+	// email rather than a second one on the next run
+	claimed, err := s.Users.ClaimDigest(ctx, *user.ID, sendAt, now)
+	if err != nil || !claimed {
+		return false, err
+	}
+	mailed, err := s.mailDigest(ctx, user, now, loc)
+	if err != nil {
+		if releaseErr := s.Users.ReleaseDigest(ctx, *user.ID, now, user.DigestSentAt); releaseErr != nil {
+			zerolog.Ctx(ctx).Error().Err(releaseErr).Msgf("failed to release digest of user with id %d", *user.ID)
+		}
+		return false, err
+	}
+	return mailed, nil
+}
+
+// mailDigest mails the user's digest, unless there is nothing to list
+func (s DigestService) mailDigest(ctx context.Context, user models.User, now time.Time, loc *time.Location) (bool, error) {
+	tasks, err := s.Tasks.GetOpenDueBefore(ctx, *user.ID, digest.Horizon(now, loc))
+	if err != nil {
+		return false, err
+	}
+	d := digest.Build(user, tasks, now, loc)
+	if d.Empty() {
+		return false, nil
+	}
+	text, html, err := d.Render()
+	if err != nil {
+		return false, err
+	}
+	msg := mail.Message{From: s.From, To: []string{*user.Email}, Subject: d.Subject(), Text: text, HTML: html}
+	if err := s.Mailer.Send(ctx, msg); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// sendTime is today's send time in loc, given as HH:MM
+func sendTime(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	at, err := time.Parse("15:04", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid digest time %q", value)
+	}
+	local := now.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), at.Hour(), at.Minute(), 0, 0, loc), nil
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/mail"
+)
+
+// createDigestUser creates a user in Berlin with the digest at 08:00
+func createDigestUser(t *testing.T, users repository.IUserRepo, username string, enabled bool) int {
+	name, email, zone, at := "Ada", username+"@example.com", "Europe/Berlin", "08:00"
+	user := &models.User{Username: &username, Name: &name, Email: &email, TimeZone: &zone}
+	if err := users.Create(context.TODO(), user); err != nil {
+		t.Fatalf("Error creating user: %v", err)
+	}
+	user.DigestEnabled, user.DigestTime = &enabled, &at
+	if err := users.UpdateDigest(context.TODO(), user); err != nil {
+		t.Fatalf("Error updating digest: %v", err)
+	}
+	return *user.ID
+}
+
+func TestDigestIsSentOncePerDay(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	dir := t.TempDir()
+	newDigestService := func() IDigestService {
+		return NewDigestService(users, repository.NewTaskRepo(db), mail.FileDrop{Dir: dir}, clock, "todo-api@localhost")
+	}
+
+	ada := createDigestUser(t, users, "ada", true)
+	bob := createDigestUser(t, users, "bob", false)
+	task := createTask(t, tasks, due(2*time.Hour), false)
+	assignees := repository.NewAssigneeRepo(db)
+	for _, userID := range []int{ada, bob} {
+		if _, err := assignees.Assign(context.TODO(), *task.ID, userID, now); err != nil {
+			t.Fatalf("Error assigning task: %v", err)
+		}
+	}
+
+	// 08:00 in Berlin is 07:00 UTC
+	steps := []struct {
+		name string
+		at   time.Time
+		want int
+	}{
+		{"before the send time", time.Date(2024, 11, 20, 6, 59, 0, 0, time.UTC), 0},
+		{"after the send time", time.Date(2024, 11, 20, 7, 1, 0, 0, time.UTC), 1},
+		{"same day", time.Date(2024, 11, 20, 9, 0, 0, 0, time.UTC), 0},
+		{"next day", time.Date(2024, 11, 21, 7, 0, 0, 0, time.UTC), 1},
+	}
+	for _, step := range steps {
+		clock.Set(step.at)
+		// A new service each time, as after a restart
+		sent, err := newDigestService().SendDue(context.TODO())
+		if err != nil {
+			t.Fatalf("Error sending digests: %v", err)
+		}
+		if sent != step.want {
+			t.Errorf("%s: expected %d digests, got %d", step.name, step.want, sent)
+		}
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected 2 dropped messages, got %d %v", len(files), err)
+	}
+	msg, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatalf("Error reading message: %v", err)
+	}
+	for _, want := range []string{"To: ada@example.com", "multipart/alternative", "text/plain", "text/html", "Due today"} {
+		if !strings.Contains(string(msg), want) {
+			t.Errorf("Expected %q in message:\n%s", want, msg)
+		}
+	}
+}
+
+func TestDigestOnlyListsAssignedTasks(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	assignees := repository.NewAssigneeRepo(db)
+	dir := t.TempDir()
+	s := NewDigestService(users, repository.NewTaskRepo(db), mail.FileDrop{Dir: dir}, clock, "todo-api@localhost")
+
+	for _, username := range []string{"ada", "bob"} {
+		userID := createDigestUser(t, users, username, true)
+		title := username + "'s private task"
+		task := &models.Task{Title: &title, DueDate: due(2 * time.Hour)}
+		if err := tasks.CreateTask(context.TODO(), task); err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		if _, err := assignees.Assign(context.TODO(), *task.ID, userID, now); err != nil {
+			t.Fatalf("Error assigning task: %v", err)
+		}
+	}
+	// Unassigned tasks are nobody's digest
+	createTask(t, tasks, due(2*time.Hour), false)
+
+	clock.Set(time.Date(2024, 11, 20, 7, 1, 0, 0, time.UTC))
+	if sent, err := s.SendDue(context.TODO()); err != nil || sent != 2 {
+		t.Fatalf("Expected 2 digests, got %d %v", sent, err)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Expected 2 dropped messages, got %d %v", len(files), err)
+	}
+	for _, file := range files {
+		msg, err := os.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			t.Fatalf("Error reading message: %v", err)
+		}
+		own, other := "ada", "bob"
+		if strings.Contains(string(msg), "To: bob@example.com") {
+			own, other = other, own
+		}
+		if !strings.Contains(string(msg), own+"'s private task") || strings.Contains(string(msg), other+"'s private task") {
+			t.Errorf("Expected only %s's task in message:\n%s", own, msg)
+		}
+	}
+}
+
+// flakyMailer fails the first fails sends, then drops messages into next
+type flakyMailer struct {
+	fails int
+	next  mail.Mailer
+}
+
+func (m *flakyMailer) Send(ctx context.Context, msg mail.Message) error {
+	if m.fails > 0 {
+		m.fails--
+		return errors.New("smtp: connection refused")
+	}
+	return m.next.Send(ctx, msg)
+}
+
+// unclaimableUsers fails to record digests as sent
+type unclaimableUsers struct {
+	repository.IUserRepo
+}
+
+func (unclaimableUsers) ClaimDigest(ctx context.Context, id int, sendAt time.Time, at time.Time) (bool, error) {
+	return false, errors.New("database is locked")
+}
+
+func TestDigestIsClaimedBeforeSending(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	ada := createDigestUser(t, users, "ada", true)
+	task := createTask(t, tasks, due(2*time.Hour), false)
+	if _, err := repository.NewAssigneeRepo(db).Assign(context.TODO(), *task.ID, ada, now); err != nil {
+		t.Fatalf("Error assigning task: %v", err)
+	}
+	dir := t.TempDir()
+	clock.Set(time.Date(2024, 11, 20, 7, 1, 0, 0, time.UTC))
+
+	// Without the sent mark nothing is mailed
+	s := NewDigestService(unclaimableUsers{users}, repository.NewTaskRepo(db), mail.FileDrop{Dir: dir}, clock,
+		"todo-api@localhost")
+	if sent, err := s.SendDue(context.TODO()); err == nil || sent != 0 {
+		t.Errorf("Expected the failed claim to be reported, got %d %v", sent, err)
+	}
+
+	// A failed send is retried on the next run, exactly once
+	mailer := &flakyMailer{fails: 1, next: mail.FileDrop{Dir: dir}}
+	s = NewDigestService(users, repository.NewTaskRepo(db), mailer, clock, "todo-api@localhost")
+	if sent, err := s.SendDue(context.TODO()); err == nil || sent != 0 {
+		t.Errorf("Expected the failed send to be reported, got %d %v", sent, err)
+	}
+	clock.Advance(time.Minute)
+	for _, want := range []int{1, 0} {
+		if sent, err := s.SendDue(context.TODO()); err != nil || sent != want {
+			t.Errorf("Expected %d digests, got %d %v", want, sent, err)
+		}
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected 1 dropped message, got %d", len(files))
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+type IProjectService interface {
+	CreateProject(ctx context.Context, project *models.Project) error
+	GetProject(ctx context.Context, id int) (*models.Project, error)
+	GetProjects(ctx context.Context) ([]models.Project, error)
+	AddMember(ctx context.Context, projectID int, userID int) error
+	RemoveMember(ctx context.Context, projectID int, userID int) error
+	GetMembers(ctx context.Context, projectID int) ([]models.User, error)
+}
+
+type ProjectService struct {
+	Repo  repository.IProjectRepo
+	Users repository.IUserRepo
+}
+
+func NewProjectService(projectRepo repository.IProjectRepo, userRepo repository.IUserRepo) IProjectService {
+	return ProjectService{projectRepo, userRepo}
+}
+
+// CreateProject stores a project, projects without a workflow get the
+// default one
+func (s ProjectService) CreateProject(ctx context.Context, project *models.Project) error {
+	ctx, span := tracer.Start(ctx, "ProjectService.CreateProject")
+	defer span.End()
+	if project.Workflow == nil {
+		workflow := models.DefaultWorkflow()
+		project.Workflow = &workflow
+	}
+	if err := project.Workflow.Validate(); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Repo.Create(ctx, project); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to create project")
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s ProjectService) GetProject(ctx context.Context, id int) (*models.Project, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.GetProject")
+	defer span.End()
+	project, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get project with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return project, nil
+}
+
+func (s ProjectService) GetProjects(ctx context.Context) ([]models.Project, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.GetProjects")
+	defer span.End()
+	projects, err := s.Repo.GetAll(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get projects")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return projects, nil
+}
+
+// AddMember adds a user to a project. Once a project has members its tasks
+// can only be assigned to them.
+func (s ProjectService) AddMember(ctx context.Context, projectID int, userID int) error {
+	ctx, span := tracer.Start(ctx, "ProjectService.AddMember")
+	defer span.End()
+	if _, err := s.Repo.GetByID(ctx, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := checkUser(ctx, s.Users, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Repo.AddMember(ctx, projectID, userID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to add member to project %d", projectID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s ProjectService) RemoveMember(ctx context.Context, projectID int, userID int) error {
+	ctx, span := tracer.Start(ctx, "ProjectService.RemoveMember")
+	defer span.End()
+	if err := s.Repo.RemoveMember(ctx, projectID, userID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to remove member from project %d", projectID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s ProjectService) GetMembers(ctx context.Context, projectID int) ([]models.User, error) {
+	ctx, span := tracer.Start(ctx, "ProjectService.GetMembers")
+	defer span.End()
+	if _, err := s.Repo.GetByID(ctx, projectID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	members, err := s.Repo.GetMembers(ctx, projectID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get members of project %d", projectID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return members, nil
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+func TestTransition(t *testing.T) {
+	s, _ := newService(t)
+	task := createTask(t, s, nil, false)
+	if *task.Status != "backlog" || *task.Completed {
+		t.Fatalf("Expected a new open task in backlog, got %s", *task.Status)
+	}
+
+	steps := []struct {
+		to            string
+		wantErr       error
+		wantStatus    string
+		wantCompleted bool
+	}{
+		{"done", models.ErrIllegalTransition, "backlog", false},
+		{"todo", nil, "todo", false},
+		{"todo", nil, "todo", false},
+		{"in_progress", nil, "in_progress", false},
+		{"shipped", models.ErrUnknownStatus, "in_progress", false},
+		{"review", nil, "review", false},
+		{"done", nil, "done", true},
+		{"todo", nil, "todo", false},
+	}
+	for _, step := range steps {
+		_, err := s.Transition(context.TODO(), *task.ID, step.to)
+		if !errors.Is(err, step.wantErr) {
+			t.Fatalf("Moving to %s: expected error %v, got %v", step.to, step.wantErr, err)
+		}
+		got, err := s.GetTask(context.TODO(), *task.ID)
+		if err != nil {
+			t.Fatalf("Error getting task: %v", err)
+		}
+		if *got.Status != step.wantStatus || *got.Completed != step.wantCompleted {
+			t.Errorf("After moving to %s: expected %s completed=%t, got %s completed=%t",
+				step.to, step.wantStatus, step.wantCompleted, *got.Status, *got.Completed)
+		}
+	}
+}
+
+func TestSetCompletedMovesStatus(t *testing.T) {
+	s, _ := newService(t)
+	task := createTask(t, s, nil, false)
+	if _, err := s.Transition(context.TODO(), *task.ID, "todo"); err != nil {
+		t.Fatalf("Error moving task: %v", err)
+	}
+
+	// Completing skips the transition rules, reopening follows the
+	// workflow's way back out of done
+	for _, step := range []struct {
+		completed  bool
+		wantStatus string
+	}{{true, "done"}, {true, "done"}, {false, "todo"}} {
+		got, err := s.SetCompleted(context.TODO(), *task.ID, step.completed)
+		if err != nil {
+			t.Fatalf("Error setting completed: %v", err)
+		}
+		if *got.Status != step.wantStatus || *got.Completed != step.completed {
+			t.Errorf("Expected %s completed=%t, got %s completed=%t",
+				step.wantStatus, step.completed, *got.Status, *got.Completed)
+		}
+	}
+}
+
+func TestCustomWorkflow(t *testing.T) {
+	_, db, clock := newServiceDB(t)
+	projectRepo := repository.NewProjectRepo(db)
+	s := NewTaskService(repository.NewTaskRepo(db), projectRepo, nil, nil, clock)
+	key, name := "OPS", "Operations"
+	project := &models.Project{Key: &key, Name: &name, Workflow: &models.Workflow{
+		States: []models.WorkflowState{
+			{Name: "open", Initial: true},
+			{Name: "fixed", Terminal: true},
+			{Name: "wontfix", Terminal: true},
+		},
+		Transitions: []models.Transition{{From: "open", To: "fixed"}, {From: "open", To: "wontfix"}},
+	}}
+	if err := NewProjectService(projectRepo, repository.NewUserRepo(db)).CreateProject(context.TODO(), project); err != nil {
+		t.Fatalf("Error creating project: %v", err)
+	}
+	if err := NewProjectService(projectRepo, repository.NewUserRepo(db)).CreateProject(context.TODO(), project); !errors.Is(err, repository.ErrProjectKeyTaken) {
+		t.Errorf("Expected ErrProjectKeyTaken, got %v", err)
+	}
+
+	title := "task"
+	task := &models.Task{ProjectID: project.ID, Title: &title}
+	if err := s.CreateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	if *task.Status != "open" {
+		t.Errorf("Expected status open, got %s", *task.Status)
+	}
+	if _, err := s.Transition(context.TODO(), *task.ID, "todo"); !errors.Is(err, models.ErrUnknownStatus) {
+		t.Errorf("Expected ErrUnknownStatus for a state of another workflow, got %v", err)
+	}
+	got, err := s.Transition(context.TODO(), *task.ID, "wontfix")
+	if err != nil {
+		t.Fatalf("Error moving task: %v", err)
+	}
+	if !*got.Completed {
+		t.Errorf("Expected task in a terminal state to be completed")
+	}
+	// No way out of wontfix, reopening falls back to the initial state
+	got, err = s.SetCompleted(context.TODO(), *task.ID, false)
+	if err != nil {
+		t.Fatalf("Error reopening task: %v", err)
+	}
+	if *got.Status != "open" {
+		t.Errorf("Expected reopened task in open, got %s", *got.Status)
+	}
+
+	unknown := 99
+	task = &models.Task{ProjectID: &unknown, Title: &title}
+	if err := s.CreateTask(context.TODO(), task); !errors.Is(err, repository.ErrProjectNotFound) {
+		t.Errorf("Expected ErrProjectNotFound, got %v", err)
+	}
+}
+
+func TestTransitionCancelsReminders(t *testing.T) {
+	f := newReminderFixture(t)
+	task := f.createTask(t, now.Add(48*time.Hour), nil)
+	for _, status := range []string{"todo", "in_progress", "review", "done"} {
+		if _, err := f.tasks.Transition(context.TODO(), *task.ID, status); err != nil {
+			t.Fatalf("Error moving task to %s: %v", status, err)
+		}
+	}
+	if pending := f.pending(t, *task.ID); len(pending) != 0 {
+		t.Errorf("Expected no reminders for a done task, got %v", pending)
+	}
+	if _, err := f.tasks.Transition(context.TODO(), *task.ID, "todo"); err != nil {
+		t.Fatalf("Error reopening task: %v", err)
+	}
+	if pending := f.pending(t, *task.ID); len(pending) != 2 {
+		t.Errorf("Expected reminders re-armed, got %v", pending)
+	}
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		workflow models.Workflow
+		valid    bool
+	}{
+		{"default", models.DefaultWorkflow(), true},
+		{"no states", models.Workflow{}, false},
+		{"no initial", models.Workflow{States: []models.WorkflowState{{Name: "a"}, {Name: "b", Terminal: true}}}, false},
+		{"no terminal", models.Workflow{States: []models.WorkflowState{{Name: "a", Initial: true}}}, false},
+		{"duplicate", models.Workflow{States: []models.WorkflowState{
+			{Name: "a", Initial: true}, {Name: "a", Terminal: true}}}, false},
+		{"unknown transition", models.Workflow{
+			States:      []models.WorkflowState{{Name: "a", Initial: true}, {Name: "b", Terminal: true}},
+			Transitions: []models.Transition{{From: "a", To: "c"}},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.workflow.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Expected valid=%t, got %v", tt.valid, err)
+			}
+		})
+	}
+}
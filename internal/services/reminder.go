@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/notify"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// Notifications are retried on every run until they have failed this
+	// many times
+	maxDeliveryAttempts = 5
+	deliveryBatchSize   = 100
+)
+
+type IReminderService interface {
+	Arm(ctx context.Context, task *models.Task) error
+	Cancel(ctx context.Context, taskID int) error
+	Enqueue(ctx context.Context) (int, error)
+	Deliver(ctx context.Context) (DeliverySummary, error)
+}
+
+// DeliverySummary counts the notifications handled by one delivery pass
+type DeliverySummary struct {
+	Sent   int `json:"sent"`
+	Failed int `json:"failed"`
+}
+
+type ReminderService struct {
+	Reminders     repository.IReminderRepo
+	Notifications repository.INotificationRepo
+	Notifier      notify.Notifier
+	Clock         clock.Clock
+	// Lead times for tasks that do not set their own
+	Defaults models.Offsets
+}
+
+func NewReminderService(reminderRepo repository.IReminderRepo, notificationRepo repository.INotificationRepo,
+	notifier notify.Notifier, clock clock.Clock, defaults models.Offsets) IReminderService {
+	return ReminderService{reminderRepo, notificationRepo, notifier, clock, defaults}
+}
+
+// Arm schedules the reminders of a task from its due date, replacing any
+// pending ones. Reminders that would already have fired are skipped, and
+// completed tasks or tasks without a due date have none.
+func (s ReminderService) Arm(ctx context.Context, task *models.Task) error {
+	ctx, span := tracer.Start(ctx, "ReminderService.Arm")
+	defer span.End()
+	if task.DueDate == nil || (task.Completed != nil && *task.Completed) {
+		return s.Cancel(ctx, *task.ID)
+	}
+
+	offsets := s.Defaults
+	if task.Reminders != nil {
+		offsets = *task.Reminders
+	}
+	now := s.Clock.Now()
+	reminders := []models.Reminder{}
+	seen := map[time.Duration]bool{}
+	for _, lead := range offsets {
+		remindAt := task.DueDate.Add(-lead)
+		if seen[lead] || !remindAt.After(now) {
+			continue
+		}
+		seen[lead] = true
+		reminders = append(reminders, models.Reminder{TaskID: *task.ID, LeadTime: lead, RemindAt: remindAt})
+	}
+
+	if err := s.Reminders.Arm(ctx, *task.ID, reminders); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to arm reminders of task with id %d", *task.ID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// Cancel drops the pending reminders of a task
+func (s ReminderService) Cancel(ctx context.Context, taskID int) error {
+	ctx, span := tracer.Start(ctx, "ReminderService.Cancel")
+	defer span.End()
+	if err := s.Reminders.Cancel(ctx, taskID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to cancel reminders of task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// Enqueue writes the reminders that are due to the notification outbox
+func (s ReminderService) Enqueue(ctx context.Context) (int, error) {
+	ctx, span := tracer.Start(ctx, "ReminderService.Enqueue")
+	defer span.End()
+	enqueued, err := s.Reminders.Enqueue(ctx, s.Clock.Now())
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to enqueue reminders")
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("reminders.enqueued", enqueued))
+	if enqueued > 0 {
+		zerolog.Ctx(ctx).Info().Int("enqueued", enqueued).Msg("reminders enqueued")
+	}
+	return enqueued, nil
+}
+
+// Deliver sends pending notifications from the outbox. A notification is
+// marked sent only after the notifier accepted it, so delivery is at least
+// once; failed notifications are retried by later passes.
+func (s ReminderService) Deliver(ctx context.Context) (DeliverySummary, error) {
+	ctx, span := tracer.Start(ctx, "ReminderService.Deliver")
+	defer span.End()
+	summary := DeliverySummary{}
+	notifications, err := s.Notifications.GetPending(ctx, maxDeliveryAttempts, deliveryBatchSize)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get pending notifications")
+		telemetry.RecordError(span, err)
+		return summary, err
+	}
+
+	var errs []error
+	for _, n := range notifications {
+		if err := s.Notifier.Notify(ctx, n); err != nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Int("notification_id", n.ID).Msg("failed to deliver notification")
+			summary.Failed++
+			errs = append(errs, err)
+			if err := s.Notifications.MarkFailed(ctx, n.ID, err.Error()); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if err := s.Notifications.MarkSent(ctx, n.ID, s.Clock.Now()); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		summary.Sent++
+	}
+
+	span.SetAttributes(
+		attribute.Int("notifications.sent", summary.Sent),
+		attribute.Int("notifications.failed", summary.Failed),
+	)
+	if err := errors.Join(errs...); err != nil {
+		telemetry.RecordError(span, err)
+		return summary, err
+	}
+	return summary, nil
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"todo-api/internal/clock/fakeclock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+type recordingNotifier struct {
+	err           error
+	notifications []models.Notification
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, notification models.Notification) error {
+	if n.err != nil {
+		return n.err
+	}
+	n.notifications = append(n.notifications, notification)
+	return nil
+}
+
+type reminderFixture struct {
+	tasks     ITaskService
+	reminders IReminderService
+	repo      repository.IReminderRepo
+	notifier  *recordingNotifier
+	clock     *fakeclock.Clock
+}
+
+func newReminderFixture(t *testing.T) reminderFixture {
+	_, db, clock := newServiceDB(t)
+	f := reminderFixture{repo: repository.NewReminderRepo(db), notifier: &recordingNotifier{}, clock: clock}
+	f.reminders = NewReminderService(f.repo, repository.NewNotificationRepo(db), f.notifier, clock,
+		models.Offsets{24 * time.Hour, time.Hour})
+	f.tasks = NewTaskService(repository.NewTaskRepo(db), repository.NewProjectRepo(db), f.reminders, nil, clock)
+	return f
+}
+
+func (f reminderFixture) createTask(t *testing.T, dueDate time.Time, reminders *models.Offsets) *models.Task {
+	title := "task"
+	task := &models.Task{Title: &title, DueDate: &dueDate, Reminders: reminders}
+	if err := f.tasks.CreateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	return task
+}
+
+func (f reminderFixture) pending(t *testing.T, taskID int) []time.Time {
+	reminders, err := f.repo.GetByTask(context.TODO(), taskID)
+	if err != nil {
+		t.Fatalf("Error getting reminders: %v", err)
+	}
+	remindAt := []time.Time{}
+	for _, reminder := range reminders {
+		remindAt = append(remindAt, reminder.RemindAt)
+	}
+	return remindAt
+}
+
+func (f reminderFixture) enqueue(t *testing.T) int {
+	enqueued, err := f.reminders.Enqueue(context.TODO())
+	if err != nil {
+		t.Fatalf("Error enqueueing reminders: %v", err)
+	}
+	return enqueued
+}
+
+func TestRemindersAreEnqueuedOnce(t *testing.T) {
+	f := newReminderFixture(t)
+	f.createTask(t, now.Add(25*time.Hour), nil)
+
+	steps := []struct {
+		at   time.Time
+		want int
+	}{
+		{now, 0},
+		{now.Add(time.Hour), 1},
+		{now.Add(2 * time.Hour), 0},
+		{now.Add(24 * time.Hour), 1},
+		{now.Add(26 * time.Hour), 0},
+	}
+	for _, step := range steps {
+		f.clock.Set(step.at)
+		if got := f.enqueue(t); got != step.want {
+			t.Errorf("At %s expected %d reminders enqueued, got %d", step.at, step.want, got)
+		}
+	}
+
+	summary, err := f.reminders.Deliver(context.TODO())
+	if err != nil {
+		t.Fatalf("Error delivering notifications: %v", err)
+	}
+	if summary.Sent != 2 || len(f.notifier.notifications) != 2 {
+		t.Fatalf("Expected 2 notifications, got %+v", summary)
+	}
+	if lead := f.notifier.notifications[1].LeadTime; lead != time.Hour {
+		t.Errorf("Expected the second reminder 1h before, got %s", lead)
+	}
+}
+
+func TestRemindersFollowTask(t *testing.T) {
+	f := newReminderFixture(t)
+	offsets := models.Offsets{time.Hour}
+	task := f.createTask(t, now.Add(2*time.Hour), &offsets)
+	if got := f.pending(t, *task.ID); len(got) != 1 || !got[0].Equal(now.Add(time.Hour)) {
+		t.Fatalf("Expected a reminder at %s, got %v", now.Add(time.Hour), got)
+	}
+
+	// Moving the due date re-arms
+	dueDate := now.Add(5 * time.Hour)
+	task.DueDate = &dueDate
+	if err := f.tasks.UpdateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error updating task: %v", err)
+	}
+	if got := f.pending(t, *task.ID); len(got) != 1 || !got[0].Equal(now.Add(4*time.Hour)) {
+		t.Fatalf("Expected the reminder moved to %s, got %v", now.Add(4*time.Hour), got)
+	}
+
+	// Completing cancels, reopening re-arms
+	if _, err := f.tasks.SetCompleted(context.TODO(), *task.ID, true); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	if got := f.pending(t, *task.ID); len(got) != 0 {
+		t.Fatalf("Expected no reminders for a completed task, got %v", got)
+	}
+	f.clock.Set(now.Add(4 * time.Hour))
+	if got := f.enqueue(t); got != 0 {
+		t.Errorf("Expected no reminders enqueued, got %d", got)
+	}
+	f.clock.Set(now)
+	if _, err := f.tasks.SetCompleted(context.TODO(), *task.ID, false); err != nil {
+		t.Fatalf("Error reopening task: %v", err)
+	}
+	if got := f.pending(t, *task.ID); len(got) != 1 {
+		t.Fatalf("Expected the reminder re-armed, got %v", got)
+	}
+
+	// Reminders already in the past are skipped
+	f.clock.Set(now.Add(4*time.Hour + time.Minute))
+	if err := f.tasks.UpdateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error updating task: %v", err)
+	}
+	if got := f.pending(t, *task.ID); len(got) != 0 {
+		t.Errorf("Expected no reminders after the lead time passed, got %v", got)
+	}
+}
+
+func TestDeliverRetriesFailures(t *testing.T) {
+	f := newReminderFixture(t)
+	offsets := models.Offsets{time.Hour}
+	f.createTask(t, now.Add(2*time.Hour), &offsets)
+	f.clock.Advance(time.Hour)
+	f.enqueue(t)
+
+	f.notifier.err = errors.New("unreachable")
+	summary, err := f.reminders.Deliver(context.TODO())
+	if err == nil || summary.Failed != 1 {
+		t.Fatalf("Expected a failed delivery, got %+v %v", summary, err)
+	}
+
+	f.notifier.err = nil
+	for _, want := range []int{1, 0} {
+		summary, err = f.reminders.Deliver(context.TODO())
+		if err != nil {
+			t.Fatalf("Error delivering notifications: %v", err)
+		}
+		if summary.Sent != want {
+			t.Errorf("Expected %d notifications sent, got %d", want, summary.Sent)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+	"todo-api/internal/timezone"
+
+	"github.com/rs/zerolog"
+)
+
+var ErrSprintProject = errors.New("task and sprint belong to different projects")
+
+type ISprintService interface {
+	CreateSprint(ctx context.Context, sprint *models.Sprint) error
+	GetSprint(ctx context.Context, id int) (*models.Sprint, error)
+	GetSprints(ctx context.Context, projectID *int) ([]models.Sprint, error)
+	SetTaskSprint(ctx context.Context, taskID int, sprintID *int) (*models.Task, error)
+	Burndown(ctx context.Context, id int) (*models.Burndown, error)
+}
+
+type SprintService struct {
+	Sprints repository.ISprintRepo
+	Tasks   repository.ITaskRepo
+	// Projects checks that sprints belong to a project, nil skips the check
+	Projects repository.IProjectRepo
+	Clock    clock.Clock
+}
+
+func NewSprintService(sprintRepo repository.ISprintRepo, taskRepo repository.ITaskRepo,
+	projectRepo repository.IProjectRepo, clock clock.Clock) ISprintService {
+	return SprintService{sprintRepo, taskRepo, projectRepo, clock}
+}
+
+// CreateSprint adds a sprint to a project, the handler has checked its
+// dates and zone
+func (s SprintService) CreateSprint(ctx context.Context, sprint *models.Sprint) error {
+	ctx, span := tracer.Start(ctx, "SprintService.CreateSprint")
+	defer span.End()
+	if s.Projects != nil {
+		if _, err := s.Projects.GetByID(ctx, sprint.ProjectID); err != nil {
+			telemetry.RecordError(span, err)
+			return err
+		}
+	}
+	if err := s.Sprints.Create(ctx, sprint); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to create sprint")
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s SprintService) GetSprint(ctx context.Context, id int) (*models.Sprint, error) {
+	ctx, span := tracer.Start(ctx, "SprintService.GetSprint")
+	defer span.End()
+	sprint, err := s.Sprints.GetByID(ctx, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return sprint, nil
+}
+
+func (s SprintService) GetSprints(ctx context.Context, projectID *int) ([]models.Sprint, error) {
+	ctx, span := tracer.Start(ctx, "SprintService.GetSprints")
+	defer span.End()
+	sprints, err := s.Sprints.GetAll(ctx, projectID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get sprints")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return sprints, nil
+}
+
+// SetTaskSprint puts a task into a sprint of its project, nil takes it out
+// of its sprint
+func (s SprintService) SetTaskSprint(ctx context.Context, taskID int, sprintID *int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "SprintService.SetTaskSprint")
+	defer span.End()
+	task, err := s.Tasks.GetByID(ctx, taskID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if sprintID != nil {
+		sprint, err := s.Sprints.GetByID(ctx, *sprintID)
+		if err != nil {
+			telemetry.RecordError(span, err)
+			return nil, err
+		}
+		if sprint.ProjectID != *task.ProjectID {
+			return nil, ErrSprintProject
+		}
+	}
+	if err := s.Sprints.SetTaskSprint(ctx, taskID, sprintID); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to set sprint of task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return s.Tasks.GetByID(ctx, taskID)
+}
+
+// Burndown computes the points left and done at the end of each sprint day
+// up to today from the completion times of the sprint's current tasks.
+// Tasks completed before completion times were recorded count as done from
+// the first day.
+func (s SprintService) Burndown(ctx context.Context, id int) (*models.Burndown, error) {
+	ctx, span := tracer.Start(ctx, "SprintService.Burndown")
+	defer span.End()
+	sprint, err := s.Sprints.GetByID(ctx, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	loc, err := timezone.Load(sprint.TimeZone)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	start, err := time.ParseInLocation("2006-01-02", sprint.StartDate, loc)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	end, err := time.ParseInLocation("2006-01-02", sprint.EndDate, loc)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	tasks, err := s.Tasks.GetAll(ctx, repository.TaskQuery{SprintID: &id})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get tasks of sprint with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	burndown := &models.Burndown{SprintID: id, Days: []models.BurndownDay{}}
+	for _, task := range tasks {
+		if task.EstimatePoints == nil {
+			burndown.Unestimated++
+			continue
+		}
+		burndown.TotalPoints += *task.EstimatePoints
+	}
+	days := 0
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days++
+	}
+	now := s.Clock.Now()
+	for i, day := 0, start; i < days && day.Before(now); i, day = i+1, day.AddDate(0, 0, 1) {
+		// AddDate keeps days whole across DST changes
+		dayEnd := day.AddDate(0, 0, 1)
+		completed := 0
+		for _, task := range tasks {
+			if task.EstimatePoints == nil || task.Completed == nil || !*task.Completed {
+				continue
+			}
+			if task.CompletedAt == nil || task.CompletedAt.Before(dayEnd) {
+				completed += *task.EstimatePoints
+			}
+		}
+		ideal := 0.0
+		if days > 1 {
+			ideal = float64(burndown.TotalPoints) * float64(days-1-i) / float64(days-1)
+		}
+		burndown.Days = append(burndown.Days, models.BurndownDay{
+			Date:      day.Format("2006-01-02"),
+			Remaining: burndown.TotalPoints - completed,
+			Completed: completed,
+			Ideal:     ideal,
+		})
+	}
+	return burndown, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+func TestSprintBurndown(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	projectRepo := repository.NewProjectRepo(db)
+	s := NewSprintService(repository.NewSprintRepo(db), repository.NewTaskRepo(db), projectRepo, clock)
+
+	sprint := &models.Sprint{ProjectID: models.DefaultProjectID, Name: "Sprint 1",
+		StartDate: "2024-11-18", EndDate: "2024-11-22", TimeZone: "UTC"}
+	if err := s.CreateSprint(context.TODO(), sprint); err != nil {
+		t.Fatalf("Error creating sprint: %v", err)
+	}
+	unknown := &models.Sprint{ProjectID: 99, Name: "Nowhere", StartDate: "2024-11-18", EndDate: "2024-11-22", TimeZone: "UTC"}
+	if err := s.CreateSprint(context.TODO(), unknown); !errors.Is(err, repository.ErrProjectNotFound) {
+		t.Errorf("Expected ErrProjectNotFound, got %v", err)
+	}
+
+	newTask := func(projectID *int, points *int) *models.Task {
+		title, minutes := "task", 90
+		task := &models.Task{ProjectID: projectID, Title: &title, EstimatePoints: points, EstimateMinutes: &minutes}
+		if err := tasks.CreateTask(context.TODO(), task); err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		return task
+	}
+	three, five := 3, 5
+	small := newTask(nil, &three)
+	large := newTask(nil, &five)
+	unestimated := newTask(nil, nil)
+	for _, task := range []*models.Task{small, large, unestimated} {
+		got, err := s.SetTaskSprint(context.TODO(), *task.ID, &sprint.ID)
+		if err != nil {
+			t.Fatalf("Error adding task to sprint: %v", err)
+		}
+		if got.SprintID == nil || *got.SprintID != sprint.ID || *got.EstimateMinutes != 90 {
+			t.Errorf("Unexpected task %+v", got)
+		}
+	}
+
+	// Sprints only hold tasks of their project
+	key, name := "OPS", "Operations"
+	project := &models.Project{Key: &key, Name: &name}
+	if err := NewProjectService(projectRepo, repository.NewUserRepo(db)).CreateProject(context.TODO(), project); err != nil {
+		t.Fatalf("Error creating project: %v", err)
+	}
+	other := newTask(project.ID, &five)
+	if _, err := s.SetTaskSprint(context.TODO(), *other.ID, &sprint.ID); !errors.Is(err, ErrSprintProject) {
+		t.Errorf("Expected ErrSprintProject, got %v", err)
+	}
+	missing := 99
+	if _, err := s.SetTaskSprint(context.TODO(), *other.ID, &missing); !errors.Is(err, repository.ErrSprintNotFound) {
+		t.Errorf("Expected ErrSprintNotFound, got %v", err)
+	}
+
+	// Completing stamps the completion time, reopening clears it
+	completed, err := tasks.SetCompleted(context.TODO(), *small.ID, true)
+	if err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	if completed.CompletedAt == nil || !completed.CompletedAt.Equal(now) {
+		t.Errorf("Expected completed_at %v, got %v", now, completed.CompletedAt)
+	}
+	reopened, err := tasks.SetCompleted(context.TODO(), *small.ID, false)
+	if err != nil {
+		t.Fatalf("Error reopening task: %v", err)
+	}
+	if reopened.CompletedAt != nil {
+		t.Errorf("Expected completed_at to be cleared, got %v", reopened.CompletedAt)
+	}
+	if _, err := tasks.SetCompleted(context.TODO(), *small.ID, true); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+
+	burndown, err := s.Burndown(context.TODO(), sprint.ID)
+	if err != nil {
+		t.Fatalf("Error getting burndown: %v", err)
+	}
+	want := &models.Burndown{SprintID: sprint.ID, TotalPoints: 8, Unestimated: 1, Days: []models.BurndownDay{
+		{Date: "2024-11-18", Remaining: 8, Completed: 0, Ideal: 8},
+		{Date: "2024-11-19", Remaining: 8, Completed: 0, Ideal: 6},
+		{Date: "2024-11-20", Remaining: 5, Completed: 3, Ideal: 4},
+	}}
+	if !reflect.DeepEqual(burndown, want) {
+		t.Errorf("Expected burndown %+v, got %+v", want, burndown)
+	}
+
+	// Days after the sprint ends are not reported
+	clock.Advance(24 * time.Hour)
+	if _, err := tasks.SetCompleted(context.TODO(), *large.ID, true); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	clock.Advance(7 * 24 * time.Hour)
+	burndown, err = s.Burndown(context.TODO(), sprint.ID)
+	if err != nil {
+		t.Fatalf("Error getting burndown: %v", err)
+	}
+	if len(burndown.Days) != 5 {
+		t.Fatalf("Expected 5 days, got %+v", burndown.Days)
+	}
+	if day := burndown.Days[3]; day.Date != "2024-11-21" || day.Remaining != 0 || day.Completed != 8 || day.Ideal != 2 {
+		t.Errorf("Unexpected day %+v", day)
+	}
+	if last := burndown.Days[4]; last.Date != "2024-11-22" || last.Ideal != 0 {
+		t.Errorf("Unexpected last day %+v", last)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sync"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+// StatsQuery selects the tasks and the date range of stats
+type StatsQuery struct {
+	Tasks repository.TaskQuery
+	// First and last day of the range, midnight in Location. Zero values
+	// cover the last statsDefaultDays days.
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	// One of models.BucketDay, BucketWeek or BucketMonth
+	Bucket string
+}
+
+// Days covered by stats without a range
+const statsDefaultDays = 30
+
+type IStatsService interface {
+	GetStats(ctx context.Context, q StatsQuery) (*models.Stats, error)
+}
+
+type StatsService struct {
+	Repo  repository.IStatsRepo
+	Clock clock.Clock
+	// How long computed stats are served again, zero disables the cache
+	TTL   time.Duration
+	cache *statsCache
+}
+
+func NewStatsService(statsRepo repository.IStatsRepo, clock clock.Clock, ttl time.Duration) IStatsService {
+	return StatsService{statsRepo, clock, ttl, &statsCache{entries: map[string]cachedStats{}}}
+}
+
+type cachedStats struct {
+	stats   *models.Stats
+	expires time.Time
+}
+
+// statsCache keeps stats per query until they expire
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedStats
+}
+
+func (c *statsCache) get(key string, now time.Time) (*models.Stats, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expires) {
+		return nil, false
+	}
+	return entry.stats, true
+}
+
+// put stores stats and drops the expired ones, so the cache only holds the
+// queries of the last TTL
+func (c *statsCache) put(key string, stats *models.Stats, expires time.Time, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cachedStats{stats, expires}
+}
+
+// cacheKey identifies the stats of a query, the sort order does not change
+// them
+func (q StatsQuery) cacheKey() (string, error) {
+	tasks := q.Tasks
+	tasks.Sort = nil
+	key, err := json.Marshal(struct {
+		Tasks    repository.TaskQuery
+		From, To string
+		Location string
+		Bucket   string
+	}{tasks, q.From.Format("2006-01-02"), q.To.Format("2006-01-02"), q.Location.String(), q.Bucket})
+	return string(key), err
+}
+
+// bounds splits the range into buckets aligned to calendar days, weeks
+// starting on Monday or months, the first and last one cut to the range
+func (q StatsQuery) bounds() []time.Time {
+	end := q.To.AddDate(0, 0, 1)
+	bounds := []time.Time{q.From}
+	for start := q.From; start.Before(end); {
+		var next time.Time
+		switch q.Bucket {
+		case models.BucketWeek:
+			days := (8 - int(start.Weekday())) % 7
+			if days == 0 {
+				days = 7
+			}
+			next = start.AddDate(0, 0, days)
+		case models.BucketMonth:
+			next = time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, q.Location)
+		default:
+			next = start.AddDate(0, 0, 1)
+		}
+		if next.After(end) {
+			next = end
+		}
+		bounds = append(bounds, next)
+		start = next
+	}
+	return bounds
+}
+
+// percent returns part of whole in percent with two decimals, nil when
+// whole is zero
+func percent(part int, whole int) *float64 {
+	if whole == 0 {
+		return nil
+	}
+	p := math.Round(10000*float64(part)/float64(whole)) / 100
+	return &p
+}
+
+// GetStats computes the stats of a query, or returns those computed for the
+// same query less than TTL ago
+func (s StatsService) GetStats(ctx context.Context, q StatsQuery) (*models.Stats, error) {
+	ctx, span := tracer.Start(ctx, "StatsService.GetStats")
+	defer span.End()
+	now := s.Clock.Now()
+	if q.From.IsZero() || q.To.IsZero() {
+		today := now.In(q.Location)
+		q.To = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, q.Location)
+		q.From = q.To.AddDate(0, 0, 1-statsDefaultDays)
+	}
+	key, err := q.cacheKey()
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if stats, ok := s.cache.get(key, now); ok {
+		return stats, nil
+	}
+
+	stats, err := s.compute(ctx, q)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to compute task stats")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	stats.GeneratedAt = now.In(q.Location)
+	// Task queries with relative times compile to new arguments on every
+	// request, their keys would never be asked for again
+	if s.TTL > 0 && (q.Tasks.Filter == nil || !q.Tasks.Filter.Relative) {
+		s.cache.put(key, stats, now.Add(s.TTL), now)
+	}
+	return stats, nil
+}
+
+func (s StatsService) compute(ctx context.Context, q StatsQuery) (*models.Stats, error) {
+	stats := &models.Stats{
+		From:     q.From.Format("2006-01-02"),
+		To:       q.To.Format("2006-01-02"),
+		Bucket:   q.Bucket,
+		ByStatus: map[string]int{},
+		Series:   []models.StatsBucket{},
+	}
+	counts, err := s.Repo.StatusCounts(ctx, q.Tasks)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		stats.ByStatus[count.Status] = count.Count
+		stats.Total += count.Count
+		stats.Open += count.Open
+		stats.Overdue += count.Overdue
+	}
+	stats.OverduePercent = percent(stats.Overdue, stats.Open)
+
+	completion, err := s.Repo.Completion(ctx, q.Tasks, q.From, q.To.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	stats.Completed = completion.Completed
+	stats.OnTimePercent = percent(completion.OnTime, completion.WithDue)
+	if completion.MedianSeconds != nil {
+		median := int64(math.Round(*completion.MedianSeconds))
+		stats.MedianTimeToComplete = &median
+	}
+
+	bounds := q.bounds()
+	series, err := s.Repo.Series(ctx, q.Tasks, bounds)
+	if err != nil {
+		return nil, err
+	}
+	for i, count := range series {
+		stats.Series = append(stats.Series, models.StatsBucket{
+			Start:     bounds[i].Format("2006-01-02"),
+			End:       bounds[i+1].AddDate(0, 0, -1).Format("2006-01-02"),
+			Created:   count.Created,
+			Completed: count.Completed,
+		})
+	}
+	return stats, nil
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/query"
+)
+
+func TestStats(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	s := NewStatsService(repository.NewStatsRepo(db), clock, time.Minute)
+	at := func(day int, hour int) time.Time {
+		return time.Date(2024, 11, day, hour, 0, 0, 0, time.UTC)
+	}
+	complete := func(task *models.Task) {
+		if _, err := tasks.SetCompleted(context.TODO(), *task.ID, true); err != nil {
+			t.Fatalf("Error completing task: %v", err)
+		}
+	}
+
+	// Three tasks on Monday: one done on time after a day, one without a
+	// due date done after three days and one done late after five days
+	clock.Set(at(18, 9))
+	dueTuesday, dueFriday := at(19, 12), at(22, 12)
+	onTime := createTask(t, tasks, &dueTuesday, false)
+	undated := createTask(t, tasks, nil, false)
+	late := createTask(t, tasks, &dueFriday, false)
+	clock.Set(at(19, 9))
+	complete(onTime)
+	// Two open tasks on Wednesday, one of them overdue since Thursday
+	clock.Set(at(20, 9))
+	dueThursday := at(21, 9)
+	createTask(t, tasks, &dueThursday, false)
+	createTask(t, tasks, nil, false)
+	clock.Set(at(21, 9))
+	complete(undated)
+	clock.Set(at(23, 9))
+	if _, err := tasks.UpdateOverdue(context.TODO()); err != nil {
+		t.Fatalf("Error updating overdue tasks: %v", err)
+	}
+	// Completed after it was flagged, it stays flagged until the next pass
+	// but is not overdue any more
+	complete(late)
+
+	q := StatsQuery{From: at(18, 0), To: at(24, 0), Location: time.UTC, Bucket: models.BucketDay}
+	stats, err := s.GetStats(context.TODO(), q)
+	if err != nil {
+		t.Fatalf("Error getting stats: %v", err)
+	}
+	if !reflect.DeepEqual(stats.ByStatus, map[string]int{"backlog": 2, "done": 3}) ||
+		stats.Total != 5 || stats.Open != 2 || stats.Overdue != 1 || *stats.OverduePercent != 50 {
+		t.Errorf("Unexpected counts %+v", stats)
+	}
+	if stats.Completed != 3 || *stats.MedianTimeToComplete != 3*24*3600 || *stats.OnTimePercent != 50 {
+		t.Errorf("Unexpected completion stats %+v", stats)
+	}
+	wantDays := []models.StatsBucket{
+		{Start: "2024-11-18", End: "2024-11-18", Created: 3},
+		{Start: "2024-11-19", End: "2024-11-19", Completed: 1},
+		{Start: "2024-11-20", End: "2024-11-20", Created: 2},
+		{Start: "2024-11-21", End: "2024-11-21", Completed: 1},
+		{Start: "2024-11-22", End: "2024-11-22"},
+		{Start: "2024-11-23", End: "2024-11-23", Completed: 1},
+		{Start: "2024-11-24", End: "2024-11-24"},
+	}
+	if !reflect.DeepEqual(stats.Series, wantDays) {
+		t.Errorf("Expected series %+v, got %+v", wantDays, stats.Series)
+	}
+
+	// Weeks start on Monday and months on the first, cut to the range
+	buckets := []struct {
+		bucket string
+		from   time.Time
+		want   []models.StatsBucket
+	}{
+		{models.BucketWeek, at(13, 0), []models.StatsBucket{
+			{Start: "2024-11-13", End: "2024-11-17"},
+			{Start: "2024-11-18", End: "2024-11-24", Created: 5, Completed: 3},
+		}},
+		{models.BucketMonth, time.Date(2024, 10, 15, 0, 0, 0, 0, time.UTC), []models.StatsBucket{
+			{Start: "2024-10-15", End: "2024-10-31"},
+			{Start: "2024-11-01", End: "2024-11-24", Created: 5, Completed: 3},
+		}},
+	}
+	for _, tt := range buckets {
+		stats, err := s.GetStats(context.TODO(), StatsQuery{From: tt.from, To: at(24, 0), Location: time.UTC, Bucket: tt.bucket})
+		if err != nil {
+			t.Fatalf("Error getting stats by %s: %v", tt.bucket, err)
+		}
+		if !reflect.DeepEqual(stats.Series, tt.want) {
+			t.Errorf("Expected series by %s %+v, got %+v", tt.bucket, tt.want, stats.Series)
+		}
+	}
+
+	// Filters match the task list
+	other := 99
+	filtered, err := s.GetStats(context.TODO(), StatsQuery{Tasks: repository.TaskQuery{ProjectID: &other},
+		From: at(18, 0), To: at(24, 0), Location: time.UTC, Bucket: models.BucketDay})
+	if err != nil {
+		t.Fatalf("Error getting filtered stats: %v", err)
+	}
+	if filtered.Total != 0 || filtered.Completed != 0 || filtered.OverduePercent != nil || filtered.MedianTimeToComplete != nil {
+		t.Errorf("Expected empty stats, got %+v", filtered)
+	}
+
+	// Stats are reused until the cache entry expires
+	createTask(t, tasks, nil, false)
+	if cached, _ := s.GetStats(context.TODO(), q); cached.Total != 5 || !cached.GeneratedAt.Equal(at(23, 9)) {
+		t.Errorf("Expected cached stats, got %+v", cached)
+	}
+	clock.Advance(time.Minute)
+	if fresh, _ := s.GetStats(context.TODO(), q); fresh.Total != 6 {
+		t.Errorf("Expected fresh stats with 6 tasks, got %+v", fresh)
+	}
+
+	// Relative times compile to new arguments on every request, their
+	// stats are not kept
+	parsed, err := query.Parse("due<7d")
+	if err != nil {
+		t.Fatalf("Error parsing query: %v", err)
+	}
+	cache := s.(StatsService).cache
+	entries := len(cache.entries)
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Second)
+		filter, err := query.Compile(parsed, query.Env{Now: clock.Now(), Location: time.UTC})
+		if err != nil {
+			t.Fatalf("Error compiling query: %v", err)
+		}
+		relative := q
+		relative.Tasks.Filter = filter
+		if _, err := s.GetStats(context.TODO(), relative); err != nil {
+			t.Fatalf("Error getting stats: %v", err)
+		}
+	}
+	if len(cache.entries) != entries {
+		t.Errorf("Expected no cache entries for relative times, got %d more", len(cache.entries)-entries)
+	}
+}
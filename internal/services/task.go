@@ -3,129 +3,420 @@ package services
 import (
 	"context"
 	"time"
+	"todo-api/internal/clock"
 	"todo-api/internal/db/models"
 	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+	"todo-api/internal/timezone"
 
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+var tracer = telemetry.Tracer("todo-api/internal/services")
+
 type ITaskService interface {
 	CreateTask(ctx context.Context, task *models.Task) error
 	GetTask(ctx context.Context, id int) (*models.Task, error)
-	GetTasks(ctx context.Context) ([]models.Task, error)
-	UpdateOverdue(ctx context.Context) error
+	GetTasks(ctx context.Context, q repository.TaskQuery) ([]models.Task, error)
+	UpdateOverdue(ctx context.Context) (repository.OverdueSummary, error)
+	EscalateOverdue(ctx context.Context, after time.Duration) (int, error)
 	UpdateTask(ctx context.Context, task *models.Task) error
 	SetCompleted(ctx context.Context, id int, completed bool) (*models.Task, error)
-	SetOverdue(ctx context.Context, id int, overdue bool) error
+	Transition(ctx context.Context, id int, status string) (*models.Task, error)
+	Move(ctx context.Context, id int, status string, at repository.Placement) (*models.Task, error)
+	GetBoard(ctx context.Context, projectID int) (*models.Board, error)
+	Rebalance(ctx context.Context, maxLength int) (int, error)
 	DeleteTask(ctx context.Context, id int) error
+	SetTags(ctx context.Context, id int, tags []string) (*models.Task, error)
 }
 
 type TaskService struct {
 	Repo repository.ITaskRepo
+	// Projects holds the workflows, nil gives every task the default one
+	Projects repository.IProjectRepo
+	// Reminders are re-armed whenever a task changes, nil disables them
+	Reminders IReminderService
+	// Releases the blobs of deleted tasks' attachments, nil leaves the
+	// blobs in place
+	Attachments IAttachmentService
+	Clock       clock.Clock
 }
 
-func NewTaskService(taskRepo repository.ITaskRepo) ITaskService {
-	return TaskService{taskRepo}
+func NewTaskService(taskRepo repository.ITaskRepo, projectRepo repository.IProjectRepo, reminders IReminderService,
+	attachments IAttachmentService, clock clock.Clock) ITaskService {
+	return TaskService{taskRepo, projectRepo, reminders, attachments, clock}
 }
 
-func (s TaskService) CreateTask(ctx context.Context, task *models.Task) error {
-	err := s.Repo.Create(ctx, task)
+// workflow returns the workflow of a project
+func (s TaskService) workflow(ctx context.Context, projectID *int) (models.Workflow, error) {
+	if s.Projects == nil {
+		return models.DefaultWorkflow(), nil
+	}
+	id := models.DefaultProjectID
+	if projectID != nil {
+		id = *projectID
+	}
+	workflow, err := s.Projects.GetWorkflow(ctx, id)
+	if err != nil {
+		return models.Workflow{}, err
+	}
+	return *workflow, nil
+}
+
+// scheduleDue normalizes the due date to UTC and derives when the task
+// becomes overdue. All-day tasks are due at midnight of their date in the
+// task's zone and overdue once that day has ended there.
+func scheduleDue(task *models.Task) error {
+	if task.DueDate == nil {
+		task.OverdueAt = nil
+		return nil
+	}
+	zone := "UTC"
+	if task.TimeZone != nil && *task.TimeZone != "" {
+		zone = *task.TimeZone
+	}
+	loc, err := timezone.Load(zone)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to create task")
 		return err
 	}
+	task.TimeZone = &zone
+
+	var dueDate, overdueAt time.Time
+	if task.DueAllDay != nil && *task.DueAllDay {
+		local := task.DueDate.In(loc)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		dueDate = midnight.UTC()
+		overdueAt = midnight.AddDate(0, 0, 1).UTC()
+	} else {
+		allDay := false
+		task.DueAllDay = &allDay
+		dueDate = task.DueDate.UTC()
+		overdueAt = dueDate
+	}
+	task.DueDate = &dueDate
+	task.OverdueAt = &overdueAt
 	return nil
 }
 
+func (s TaskService) CreateTask(ctx context.Context, task *models.Task) error {
+	ctx, span := tracer.Start(ctx, "TaskService.CreateTask")
+	defer span.End()
+	if err := scheduleDue(task); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	// New tasks start in the initial state of their project's workflow
+	workflow, err := s.workflow(ctx, task.ProjectID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	status := workflow.Initial()
+	completed := false
+	createdAt := s.Clock.Now()
+	task.Status = &status
+	task.Completed = &completed
+	task.CreatedAt = &createdAt
+
+	err = s.Repo.Create(ctx, task)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to create task")
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return s.armReminders(ctx, task)
+}
+
+// armReminders re-arms the reminders of a task after it was written
+func (s TaskService) armReminders(ctx context.Context, task *models.Task) error {
+	if s.Reminders == nil {
+		return nil
+	}
+	return s.Reminders.Arm(ctx, task)
+}
+
 func (s TaskService) GetTask(ctx context.Context, id int) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetTask")
+	defer span.End()
 	task, err := s.Repo.GetByID(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to get task with id %d", id)
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get task with id %d", id)
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
 	return task, nil
 }
 
-func (s TaskService) GetTasks(ctx context.Context) ([]models.Task, error) {
-	tasks, err := s.Repo.GetAll(ctx)
+func (s TaskService) GetTasks(ctx context.Context, q repository.TaskQuery) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetTasks")
+	defer span.End()
+	tasks, err := s.Repo.GetAll(ctx, q)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to get tasks")
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get tasks")
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
 	return tasks, nil
 }
 
 func (s TaskService) UpdateTask(ctx context.Context, task *models.Task) error {
-	// Check if task is overdue
-	if task.DueDate != nil {
-		var overdue bool
-		if task.DueDate.Before(time.Now()) {
-			overdue = true
-		} else {
-			overdue = false
-		}
+	ctx, span := tracer.Start(ctx, "TaskService.UpdateTask")
+	defer span.End()
+	if err := scheduleDue(task); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	// Check if task is overdue, a new due date also restarts escalation
+	if task.OverdueAt != nil {
+		overdue := task.OverdueAt.Before(s.Clock.Now())
 		task.Overdue = &overdue
+		escalated := false
+		task.Escalated = &escalated
 	}
 
 	err := s.Repo.Update(ctx, task)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to update task with id %d", *task.ID)
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to update task with id %d", *task.ID)
+		telemetry.RecordError(span, err)
 		return err
 	}
-	return nil
+	return s.armReminders(ctx, task)
 }
 
+// SetCompleted moves a task to the first terminal state of its workflow,
+// or back out of it, without checking the transition rules
 func (s TaskService) SetCompleted(ctx context.Context, id int, completed bool) (*models.Task, error) {
-	task := &models.Task{
-		ID:        &id,
-		Completed: &completed,
+	ctx, span := tracer.Start(ctx, "TaskService.SetCompleted")
+	defer span.End()
+	task, workflow, err := s.getWithWorkflow(ctx, id)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to set completed task with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
 	}
-	err := s.Repo.Update(ctx, task)
+	if task.Completed != nil && *task.Completed == completed {
+		return task, nil
+	}
+	status := workflow.Completion()
+	if !completed {
+		status = workflow.Reopening(*task.Status)
+	}
+	return s.setStatus(ctx, task, workflow, status, repository.Placement{})
+}
+
+// Transition moves a task to another state of its workflow. Only the moves
+// the workflow lists are allowed and a task is completed while it is in a
+// terminal state.
+func (s TaskService) Transition(ctx context.Context, id int, status string) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Transition")
+	defer span.End()
+	task, workflow, err := s.getWithWorkflow(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to set completed task with id %d", id)
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to transition task with id %d", id)
+		telemetry.RecordError(span, err)
 		return nil, err
 	}
-	return task, nil
+	if err := workflow.Check(*task.Status, status); err != nil {
+		return nil, err
+	}
+	if *task.Status == status {
+		return task, nil
+	}
+	return s.setStatus(ctx, task, workflow, status, repository.Placement{})
 }
 
-func (s TaskService) SetOverdue(ctx context.Context, id int, overdue bool) error {
-	task := &models.Task{
-		ID:      &id,
-		Overdue: &overdue,
+// Move places a task on its project's board, in the status column or in
+// its current one when status is empty. Moving to another column follows
+// the same rules as Transition.
+func (s TaskService) Move(ctx context.Context, id int, status string, at repository.Placement) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Move")
+	defer span.End()
+	task, workflow, err := s.getWithWorkflow(ctx, id)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to move task with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
 	}
-	err := s.Repo.Update(ctx, task)
+	if status == "" {
+		status = *task.Status
+	}
+	if err := workflow.Check(*task.Status, status); err != nil {
+		return nil, err
+	}
+	return s.setStatus(ctx, task, workflow, status, at)
+}
+
+// getWithWorkflow loads a task and the workflow of its project
+func (s TaskService) getWithWorkflow(ctx context.Context, id int) (*models.Task, models.Workflow, error) {
+	task, err := s.Repo.GetByID(ctx, id)
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to set overdue task with id %d", id)
-		return err
+		return nil, models.Workflow{}, err
 	}
-	return nil
+	workflow, err := s.workflow(ctx, task.ProjectID)
+	if err != nil {
+		return nil, models.Workflow{}, err
+	}
+	return task, workflow, nil
+}
+
+// setStatus writes the new state and board position of a task unless it
+// was moved since it was read, and re-arms its reminders
+func (s TaskService) setStatus(ctx context.Context, task *models.Task, workflow models.Workflow,
+	status string, at repository.Placement) (*models.Task, error) {
+	from := *task.Status
+	state, _ := workflow.State(status)
+	// Completion time is kept while moving between terminal states
+	switch {
+	case !state.Terminal:
+		task.CompletedAt = nil
+	case task.Completed == nil || !*task.Completed:
+		completedAt := s.Clock.Now()
+		task.CompletedAt = &completedAt
+	}
+	task.Status = &status
+	task.Completed = &state.Terminal
+	if err := s.Repo.Move(ctx, task, from, at); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to move task with id %d from %s to %s", *task.ID, from, status)
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Debug().Int("task", *task.ID).Str("from", from).Str("to", status).Msg("task moved")
+	// Completing cancels the reminders, reopening re-arms them
+	if err := s.armReminders(ctx, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// SetTags replaces the tags of a task, they are lowercased and duplicates
+// dropped
+func (s TaskService) SetTags(ctx context.Context, id int, tags []string) (*models.Task, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.SetTags")
+	defer span.End()
+	tags, err := models.ParseTags(tags)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	if err := s.Repo.SetTags(ctx, id, tags); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to set tags of task with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return s.GetTask(ctx, id)
 }
 
 func (s TaskService) DeleteTask(ctx context.Context, id int) error {
-	err := s.Repo.Delete(ctx, id)
+	ctx, span := tracer.Start(ctx, "TaskService.DeleteTask")
+	defer span.End()
+	// The task goes with its attachments, their blobs once it is gone
+	var err error
+	if s.Attachments != nil {
+		err = s.Attachments.DeleteWithTask(ctx, id, func(ctx context.Context) error {
+			return s.Repo.Delete(ctx, id)
+		})
+	} else {
+		err = s.Repo.Delete(ctx, id)
+	}
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to delete task with id %d", id)
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to delete task with id %d", id)
+		telemetry.RecordError(span, err)
 		return err
 	}
+	if s.Reminders != nil {
+		return s.Reminders.Cancel(ctx, id)
+	}
 	return nil
 }
 
-// UpdateOverdue fetches all overdue tasks and sets the overdue flag to true
-func (s TaskService) UpdateOverdue(ctx context.Context) error {
-	tasks, err := s.Repo.GetTasksAfterDue(ctx)
-	log.Logger.Info().Msgf("found overdue tasks: %d", len(tasks))
+// UpdateOverdue reconciles the overdue flag of every task with the current
+// time, flagging tasks that are past due and clearing tasks that are
+// completed or no longer past due
+func (s TaskService) UpdateOverdue(ctx context.Context) (repository.OverdueSummary, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.UpdateOverdue")
+	defer span.End()
+	summary, err := s.Repo.ReconcileOverdue(ctx, s.Clock.Now())
 	if err != nil {
-		log.Logger.Error().Err(err).Msgf("failed to get tasks by due date")
-		return err
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to reconcile overdue tasks")
+		telemetry.RecordError(span, err)
+		return summary, err
+	}
+	span.SetAttributes(
+		attribute.Int("overdue.flagged", summary.Flagged),
+		attribute.Int("overdue.cleared", summary.Cleared),
+	)
+	zerolog.Ctx(ctx).Info().
+		Int("flagged", summary.Flagged).
+		Int("cleared", summary.Cleared).
+		Msg("overdue tasks reconciled")
+	return summary, nil
+}
+
+// EscalateOverdue raises the priority of tasks that have been overdue for
+// longer than after by one level
+func (s TaskService) EscalateOverdue(ctx context.Context, after time.Duration) (int, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.EscalateOverdue")
+	defer span.End()
+	escalated, err := s.Repo.EscalateOverdue(ctx, s.Clock.Now().Add(-after))
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to escalate overdue tasks")
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("overdue.escalated", escalated))
+	if escalated > 0 {
+		zerolog.Ctx(ctx).Info().Int("escalated", escalated).Msg("overdue tasks escalated")
+	}
+	return escalated, nil
+}
+
+// GetBoard lists the tasks of a project by workflow state, in board order
+func (s TaskService) GetBoard(ctx context.Context, projectID int) (*models.Board, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.GetBoard")
+	defer span.End()
+	workflow, err := s.workflow(ctx, &projectID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get board of project %d", projectID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	tasks, err := s.Repo.GetAll(ctx, repository.TaskQuery{
+		ProjectID: &projectID,
+		Sort:      []repository.SortKey{{Column: "position"}},
+	})
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get board of project %d", projectID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	board := &models.Board{ProjectID: projectID, Columns: make([]models.Column, len(workflow.States))}
+	columns := map[string]*models.Column{}
+	for i, state := range workflow.States {
+		board.Columns[i] = models.Column{Status: state.Name, Terminal: state.Terminal, Tasks: []models.Task{}}
+		columns[state.Name] = &board.Columns[i]
 	}
 	for _, task := range tasks {
-		err := s.SetOverdue(ctx, *task.ID, true)
-		if err != nil {
-			log.Logger.Error().Err(err).Msgf("failed to set overdue for task with id %d", *task.ID)
-			return err
+		if column, ok := columns[*task.Status]; ok {
+			column.Tasks = append(column.Tasks, task)
 		}
-		log.Logger.Info().Msgf("task with id %d is overdue", *task.ID)
 	}
-	log.Logger.Info().Msg("overdue tasks updated")
-	return nil
+	return board, nil
+}
+
+// Rebalance shortens the board positions of columns whose keys grew longer
+// than maxLength
+func (s TaskService) Rebalance(ctx context.Context, maxLength int) (int, error) {
+	ctx, span := tracer.Start(ctx, "TaskService.Rebalance")
+	defer span.End()
+	moved, err := s.Repo.Rebalance(ctx, maxLength)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to rebalance board positions")
+		telemetry.RecordError(span, err)
+		return 0, err
+	}
+	span.SetAttributes(attribute.Int("board.rebalanced", moved))
+	if moved > 0 {
+		zerolog.Ctx(ctx).Info().Int("tasks", moved).Msg("board positions rebalanced")
+	}
+	return moved, nil
 }
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+	"todo-api/internal/clock/fakeclock"
+	"todo-api/internal/db/drivers"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+
+	"github.com/jmoiron/sqlx"
+)
+
+var now = time.Date(2024, 11, 20, 12, 0, 0, 0, time.UTC)
+
+func newService(t testing.TB) (ITaskService, *fakeclock.Clock) {
+	s, _, clock := newServiceDB(t)
+	return s, clock
+}
+
+func newServiceDB(t testing.TB) (ITaskService, *sqlx.DB, *fakeclock.Clock) {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "service.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	clock := fakeclock.New(now)
+	return NewTaskService(repository.NewTaskRepo(db), repository.NewProjectRepo(db), nil, nil, clock), db, clock
+}
+
+func due(d time.Duration) *time.Time {
+	dueDate := now.Add(d)
+	return &dueDate
+}
+
+func createTask(t *testing.T, s ITaskService, dueDate *time.Time, completed bool) *models.Task {
+	title := "task"
+	task := &models.Task{Title: &title, DueDate: dueDate}
+	if err := s.CreateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	if completed {
+		if _, err := s.SetCompleted(context.TODO(), *task.ID, true); err != nil {
+			t.Fatalf("Error completing task: %v", err)
+		}
+	}
+	return task
+}
+
+func TestUpdateTaskOverdue(t *testing.T) {
+	tests := []struct {
+		name        string
+		dueDate     *time.Time
+		wantOverdue bool
+	}{
+		{"due in the past", due(-time.Hour), true},
+		{"due in the future", due(time.Hour), false},
+		{"due exactly now", due(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newService(t)
+			// Start from an overdue task so clearing has something to undo
+			task := createTask(t, s, due(-24*time.Hour), false)
+			if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+				t.Fatalf("Error updating overdue: %v", err)
+			}
+
+			task.DueDate = tt.dueDate
+			if err := s.UpdateTask(context.TODO(), task); err != nil {
+				t.Fatalf("Error updating task: %v", err)
+			}
+			got, err := s.GetTask(context.TODO(), *task.ID)
+			if err != nil {
+				t.Fatalf("Error getting task: %v", err)
+			}
+			if *got.Overdue != tt.wantOverdue {
+				t.Errorf("Expected overdue %t, got %t", tt.wantOverdue, *got.Overdue)
+			}
+			if (got.DueDate == nil) != (tt.dueDate == nil) {
+				t.Errorf("Expected due date %v, got %v", tt.dueDate, got.DueDate)
+			}
+		})
+	}
+}
+
+func TestUpdateTaskKeepsMissingFields(t *testing.T) {
+	s, _ := newService(t)
+	title, description := "task", "details"
+	priority, points := models.PriorityHigh, 3
+	task := &models.Task{Title: &title, Description: &description, DueDate: due(time.Hour), Priority: &priority, EstimatePoints: &points}
+	if err := s.CreateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+
+	renamed := "renamed"
+	update := &models.Task{ID: task.ID, Title: &renamed, Description: &description, DueDate: due(2 * time.Hour)}
+	if err := s.UpdateTask(context.TODO(), update); err != nil {
+		t.Fatalf("Error updating task: %v", err)
+	}
+	got, err := s.GetTask(context.TODO(), *task.ID)
+	if err != nil {
+		t.Fatalf("Error getting task: %v", err)
+	}
+	if *got.Title != renamed || !got.DueDate.Equal(*due(2 * time.Hour)) {
+		t.Errorf("Expected the title and due date to change, got %q %v", *got.Title, got.DueDate)
+	}
+	if *got.Priority != priority || got.EstimatePoints == nil || *got.EstimatePoints != points {
+		t.Errorf("Expected priority and estimate to be kept, got %v %v", *got.Priority, got.EstimatePoints)
+	}
+}
+
+func TestUpdateOverdueTransitions(t *testing.T) {
+	tests := []struct {
+		name        string
+		dueDate     *time.Time
+		completed   bool
+		advance     time.Duration
+		wantOverdue bool
+	}{
+		{"due in the past", due(-time.Hour), false, 0, true},
+		{"due in the future", due(time.Hour), false, 0, false},
+		{"future due date passes", due(time.Hour), false, time.Hour + time.Second, true},
+		{"due exactly now", due(0), false, 0, false},
+		{"just after the boundary", due(0), false, time.Nanosecond, true},
+		{"no due date", nil, false, 0, false},
+		{"completed task", due(-time.Hour), true, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newService(t)
+			task := createTask(t, s, tt.dueDate, tt.completed)
+
+			clock.Advance(tt.advance)
+			if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+				t.Fatalf("Error updating overdue: %v", err)
+			}
+			got, err := s.GetTask(context.TODO(), *task.ID)
+			if err != nil {
+				t.Fatalf("Error getting task: %v", err)
+			}
+			if *got.Overdue != tt.wantOverdue {
+				t.Errorf("Expected overdue %t, got %t", tt.wantOverdue, *got.Overdue)
+			}
+		})
+	}
+}
+
+func TestUpdateOverdueClears(t *testing.T) {
+	s, db, clock := newServiceDB(t)
+	later := createTask(t, s, due(time.Hour), false)
+	completed := createTask(t, s, due(time.Hour), false)
+	cleared := createTask(t, s, due(time.Hour), false)
+	createTask(t, s, due(2*time.Hour), false)
+
+	clock.Advance(90 * time.Minute)
+	summary, err := s.UpdateOverdue(context.TODO())
+	if err != nil {
+		t.Fatalf("Error updating overdue: %v", err)
+	}
+	if want := (repository.OverdueSummary{Flagged: 3}); summary != want {
+		t.Errorf("Expected summary %+v, got %+v", want, summary)
+	}
+
+	// Completing, postponing or clearing the due date of an overdue task
+	// without touching the overdue flag leaves it to the next pass
+	if _, err := s.SetCompleted(context.TODO(), *completed.ID, true); err != nil {
+		t.Fatalf("Error completing task: %v", err)
+	}
+	dueDate := now.Add(3 * time.Hour)
+	if _, err := db.ExecContext(context.TODO(), "UPDATE task SET due_date = $1, overdue_at = $1 WHERE id = $2", dueDate, *later.ID); err != nil {
+		t.Fatalf("Error postponing task: %v", err)
+	}
+	if _, err := db.ExecContext(context.TODO(), "UPDATE task SET due_date = NULL, overdue_at = NULL WHERE id = $1", *cleared.ID); err != nil {
+		t.Fatalf("Error clearing due date: %v", err)
+	}
+
+	summary, err = s.UpdateOverdue(context.TODO())
+	if err != nil {
+		t.Fatalf("Error updating overdue: %v", err)
+	}
+	if want := (repository.OverdueSummary{Cleared: 3}); summary != want {
+		t.Errorf("Expected summary %+v, got %+v", want, summary)
+	}
+	summary, err = s.UpdateOverdue(context.TODO())
+	if err != nil {
+		t.Fatalf("Error updating overdue: %v", err)
+	}
+	if summary != (repository.OverdueSummary{}) {
+		t.Errorf("Expected an idempotent second pass, got %+v", summary)
+	}
+}
+
+func createPrioritized(t *testing.T, s ITaskService, title string, dueDate *time.Time, priority models.Priority) int {
+	task := &models.Task{Title: &title, DueDate: dueDate, Priority: &priority}
+	if err := s.CreateTask(context.TODO(), task); err != nil {
+		t.Fatalf("Error creating task: %v", err)
+	}
+	return *task.ID
+}
+
+func TestGetTasksOrder(t *testing.T) {
+	s, _ := newService(t)
+	createPrioritized(t, s, "low soon", due(time.Hour), models.PriorityLow)
+	createPrioritized(t, s, "urgent", nil, models.PriorityUrgent)
+	createPrioritized(t, s, "high later", due(48*time.Hour), models.PriorityHigh)
+	createPrioritized(t, s, "high soon", due(24*time.Hour), models.PriorityHigh)
+	createPrioritized(t, s, "overdue none", due(-time.Hour), models.PriorityNone)
+	createPrioritized(t, s, "high no due", nil, models.PriorityHigh)
+	createPrioritized(t, s, "overdue high", due(-2*time.Hour), models.PriorityHigh)
+	if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+		t.Fatalf("Error updating overdue: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query repository.TaskQuery
+		want  []string
+	}{
+		{"default order", repository.TaskQuery{},
+			[]string{"overdue high", "overdue none", "urgent", "high soon", "high later", "high no due", "low soon"}},
+		{"high and urgent", repository.TaskQuery{Priorities: []models.Priority{models.PriorityHigh, models.PriorityUrgent}},
+			[]string{"overdue high", "urgent", "high soon", "high later", "high no due"}},
+		{"due date descending", repository.TaskQuery{Sort: []repository.SortKey{{Column: "due_date", Desc: true}},
+			Priorities: []models.Priority{models.PriorityHigh}},
+			[]string{"high later", "high soon", "overdue high", "high no due"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks, err := s.GetTasks(context.TODO(), tt.query)
+			if err != nil {
+				t.Fatalf("Error getting tasks: %v", err)
+			}
+			got := []string{}
+			for _, task := range tasks {
+				got = append(got, *task.Title)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEscalateOverdue(t *testing.T) {
+	s, clock := newService(t)
+	late := createPrioritized(t, s, "late", due(-3*24*time.Hour), models.PriorityMedium)
+	recent := createPrioritized(t, s, "recent", due(-time.Hour), models.PriorityMedium)
+	urgent := createPrioritized(t, s, "urgent", due(-3*24*time.Hour), models.PriorityUrgent)
+
+	escalate := func(want int) {
+		t.Helper()
+		if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+			t.Fatalf("Error updating overdue: %v", err)
+		}
+		escalated, err := s.EscalateOverdue(context.TODO(), 2*24*time.Hour)
+		if err != nil {
+			t.Fatalf("Error escalating: %v", err)
+		}
+		if escalated != want {
+			t.Errorf("Expected %d tasks escalated, got %d", want, escalated)
+		}
+	}
+	priority := func(id int) models.Priority {
+		t.Helper()
+		task, err := s.GetTask(context.TODO(), id)
+		if err != nil {
+			t.Fatalf("Error getting task: %v", err)
+		}
+		return *task.Priority
+	}
+
+	escalate(1)
+	// Escalation happens once per overdue period
+	clock.Advance(24 * time.Hour)
+	escalate(0)
+	clock.Advance(24 * time.Hour)
+	escalate(1)
+	for id, want := range map[int]models.Priority{late: models.PriorityHigh, recent: models.PriorityHigh, urgent: models.PriorityUrgent} {
+		if got := priority(id); got != want {
+			t.Errorf("Expected task %d at %s, got %s", id, want, got)
+		}
+	}
+}
+
+func TestDueDatesInTaskZone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatalf("Error loading zone: %v", err)
+	}
+	allDay := true
+	timed := false
+	zone := "Asia/Tokyo"
+
+	tests := []struct {
+		name          string
+		dueDate       time.Time
+		allDay        *bool
+		wantOverdueAt time.Time
+	}{
+		// Overdue when 20 November ends in Tokyo, 15:00 UTC
+		{"all-day", time.Date(2024, 11, 20, 0, 0, 0, 0, tokyo), &allDay, time.Date(2024, 11, 20, 15, 0, 0, 0, time.UTC)},
+		{"time of day", time.Date(2024, 11, 20, 17, 0, 0, 0, tokyo), &timed, time.Date(2024, 11, 20, 8, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, clock := newService(t)
+			title := "task"
+			dueDate := tt.dueDate
+			task := &models.Task{Title: &title, DueDate: &dueDate, DueAllDay: tt.allDay, TimeZone: &zone}
+			if err := s.CreateTask(context.TODO(), task); err != nil {
+				t.Fatalf("Error creating task: %v", err)
+			}
+			if !task.OverdueAt.Equal(tt.wantOverdueAt) {
+				t.Errorf("Expected overdue at %s, got %s", tt.wantOverdueAt, task.OverdueAt)
+			}
+
+			for _, step := range []struct {
+				at          time.Time
+				wantOverdue bool
+			}{
+				{tt.wantOverdueAt.Add(-time.Second), false},
+				{tt.wantOverdueAt.Add(time.Second), true},
+			} {
+				clock.Set(step.at)
+				if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+					t.Fatalf("Error updating overdue: %v", err)
+				}
+				got, err := s.GetTask(context.TODO(), *task.ID)
+				if err != nil {
+					t.Fatalf("Error getting task: %v", err)
+				}
+				if *got.Overdue != step.wantOverdue {
+					t.Errorf("At %s expected overdue %t, got %t", step.at, step.wantOverdue, *got.Overdue)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUpdateOverdue reconciles 100k tasks whose due dates are spread
+// over a day, flipping the clock so every pass moves half of them
+func BenchmarkUpdateOverdue(b *testing.B) {
+	const tasks = 100_000
+	s, db, clock := newServiceDB(b)
+
+	tx, err := db.Beginx()
+	if err != nil {
+		b.Fatalf("Error starting transaction: %v", err)
+	}
+	stmt, err := tx.Prepare("INSERT INTO task(title, description, due_date, overdue_at, completed) VALUES($1, '', $2, $2, $3)")
+	if err != nil {
+		b.Fatalf("Error preparing insert: %v", err)
+	}
+	for i := 0; i < tasks; i++ {
+		dueDate := now.Add(time.Duration(i) * 24 * time.Hour / tasks)
+		if _, err := stmt.Exec(fmt.Sprintf("task %d", i), dueDate, i%10 == 0); err != nil {
+			b.Fatalf("Error inserting task: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatalf("Error committing tasks: %v", err)
+	}
+
+	b.Run("transitions", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			clock.Set(now.Add(time.Duration(i%2) * 24 * time.Hour))
+			if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+				b.Fatalf("Error updating overdue: %v", err)
+			}
+		}
+	})
+	b.Run("steady", func(b *testing.B) {
+		clock.Set(now.Add(12 * time.Hour))
+		for i := 0; i < b.N; i++ {
+			if _, err := s.UpdateOverdue(context.TODO()); err != nil {
+				b.Fatalf("Error updating overdue: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrNotEntryOwner   = errors.New("only the user who logged a time entry can change it")
+	ErrInvalidInterval = errors.New("time entry must end after it starts")
+)
+
+// TimeReportQuery selects the entries of a time report
+type TimeReportQuery struct {
+	// First and last day of the report, midnight in Location
+	From     time.Time
+	To       time.Time
+	Location *time.Location
+	// One of models.GroupByTask, GroupByProject or GroupByDay
+	Group string
+	// Only this user's entries, nil reports everyone's
+	UserID *int
+}
+
+type ITimeService interface {
+	StartTimer(ctx context.Context, taskID int, userID int, note string) (*models.TimeEntry, error)
+	StopTimer(ctx context.Context, taskID int, userID int) (*models.TimeEntry, error)
+	GetEntries(ctx context.Context, taskID int) ([]models.TimeEntry, error)
+	CreateEntry(ctx context.Context, entry *models.TimeEntry) error
+	UpdateEntry(ctx context.Context, entry *models.TimeEntry, userID int) error
+	DeleteEntry(ctx context.Context, taskID int, id int, userID int) error
+	Report(ctx context.Context, q TimeReportQuery) (*models.TimeReport, error)
+}
+
+type TimeService struct {
+	Entries repository.ITimeEntryRepo
+	Tasks   repository.ITaskRepo
+	Users   repository.IUserRepo
+	Clock   clock.Clock
+}
+
+func NewTimeService(entryRepo repository.ITimeEntryRepo, taskRepo repository.ITaskRepo, userRepo repository.IUserRepo,
+	clock clock.Clock) ITimeService {
+	return TimeService{entryRepo, taskRepo, userRepo, clock}
+}
+
+// checkEntry fails for unknown tasks and users
+func (s TimeService) checkEntry(ctx context.Context, taskID int, userID int) error {
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		return err
+	}
+	return checkUser(ctx, s.Users, userID)
+}
+
+// StartTimer starts tracking a user's time on a task. Timers are stored
+// like every other entry, so they keep running across restarts.
+func (s TimeService) StartTimer(ctx context.Context, taskID int, userID int, note string) (*models.TimeEntry, error) {
+	ctx, span := tracer.Start(ctx, "TimeService.StartTimer")
+	defer span.End()
+	if err := s.checkEntry(ctx, taskID, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	entry := &models.TimeEntry{TaskID: taskID, UserID: userID, StartedAt: s.Clock.Now(), Note: note}
+	if err := s.Entries.Create(ctx, entry); err != nil {
+		if !errors.Is(err, repository.ErrTimerRunning) {
+			zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to start timer on task with id %d", taskID)
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Info().Int("task", taskID).Int("user", userID).Msg("timer started")
+	return entry, nil
+}
+
+// StopTimer ends the user's running timer on a task
+func (s TimeService) StopTimer(ctx context.Context, taskID int, userID int) (*models.TimeEntry, error) {
+	ctx, span := tracer.Start(ctx, "TimeService.StopTimer")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	entry, err := s.Entries.Stop(ctx, taskID, userID, s.Clock.Now())
+	if err != nil {
+		if !errors.Is(err, repository.ErrNoRunningTimer) {
+			zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to stop timer on task with id %d", taskID)
+		}
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	zerolog.Ctx(ctx).Info().Int("task", taskID).Int("user", userID).Int64("seconds", *entry.Duration).
+		Msg("timer stopped")
+	return entry, nil
+}
+
+func (s TimeService) GetEntries(ctx context.Context, taskID int) ([]models.TimeEntry, error) {
+	ctx, span := tracer.Start(ctx, "TimeService.GetEntries")
+	defer span.End()
+	if _, err := s.Tasks.GetByID(ctx, taskID); err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	entries, err := s.Entries.GetByTask(ctx, taskID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get time entries of task with id %d", taskID)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return entries, nil
+}
+
+// CreateEntry logs work done without a timer, the entry needs an end
+func (s TimeService) CreateEntry(ctx context.Context, entry *models.TimeEntry) error {
+	ctx, span := tracer.Start(ctx, "TimeService.CreateEntry")
+	defer span.End()
+	if entry.EndedAt == nil || !entry.EndedAt.After(entry.StartedAt) {
+		return ErrInvalidInterval
+	}
+	if err := s.checkEntry(ctx, entry.TaskID, entry.UserID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Entries.Create(ctx, entry); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to log time on task with id %d", entry.TaskID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// owned loads an entry and fails with ErrNotEntryOwner unless userID
+// logged it
+func (s TimeService) owned(ctx context.Context, taskID int, id int, userID int) (*models.TimeEntry, error) {
+	entry, err := s.Entries.GetByID(ctx, taskID, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UserID != userID {
+		return nil, ErrNotEntryOwner
+	}
+	return entry, nil
+}
+
+// UpdateEntry replaces the start, end and note of an entry logged by
+// userID. A running timer keeps running unless the update gives it an end.
+func (s TimeService) UpdateEntry(ctx context.Context, entry *models.TimeEntry, userID int) error {
+	ctx, span := tracer.Start(ctx, "TimeService.UpdateEntry")
+	defer span.End()
+	current, err := s.owned(ctx, entry.TaskID, entry.ID, userID)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if entry.EndedAt == nil && !current.Running() {
+		return ErrInvalidInterval
+	}
+	if entry.EndedAt != nil && !entry.EndedAt.After(entry.StartedAt) {
+		return ErrInvalidInterval
+	}
+	if err := s.Entries.Update(ctx, entry); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to update time entry with id %d", entry.ID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteEntry removes an entry logged by userID, running or not
+func (s TimeService) DeleteEntry(ctx context.Context, taskID int, id int, userID int) error {
+	ctx, span := tracer.Start(ctx, "TimeService.DeleteEntry")
+	defer span.End()
+	if _, err := s.owned(ctx, taskID, id, userID); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	if err := s.Entries.Delete(ctx, id); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to delete time entry with id %d", id)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// Report sums the finished entries that started between the first and the
+// end of the last day of the query, per task, project or day. Days are
+// those of the query's location, an entry counts towards the day it
+// started. Rows are ordered by key, days chronologically.
+func (s TimeService) Report(ctx context.Context, q TimeReportQuery) (*models.TimeReport, error) {
+	ctx, span := tracer.Start(ctx, "TimeService.Report")
+	defer span.End()
+	entries, err := s.Entries.GetFinished(ctx, q.From, q.To.AddDate(0, 0, 1), q.UserID)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get time entries for report")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+
+	report := &models.TimeReport{
+		From:  q.From.Format("2006-01-02"),
+		To:    q.To.Format("2006-01-02"),
+		Group: q.Group,
+		Rows:  []models.TimeReportRow{},
+	}
+	rows := map[string]*models.TimeReportRow{}
+	// Sort keys, ids compare as numbers
+	order := map[string]int{}
+	for _, entry := range entries {
+		var key, name string
+		var rank int
+		switch q.Group {
+		case models.GroupByProject:
+			key, name, rank = strconv.Itoa(entry.ProjectID), entry.ProjectName, entry.ProjectID
+		case models.GroupByDay:
+			key = entry.StartedAt.In(q.Location).Format("2006-01-02")
+		default:
+			key, name, rank = strconv.Itoa(entry.TaskID), entry.TaskTitle, entry.TaskID
+		}
+		row, ok := rows[key]
+		if !ok {
+			row = &models.TimeReportRow{Key: key, Name: name}
+			rows[key] = row
+			order[key] = rank
+		}
+		row.Seconds += *entry.Duration
+		report.Total += *entry.Duration
+	}
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i].Key, report.Rows[j].Key
+		if order[a] != order[b] {
+			return order[a] < order[b]
+		}
+		return a < b
+	})
+	return report, nil
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+)
+
+func TestTimers(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	newTimeService := func() ITimeService {
+		return NewTimeService(repository.NewTimeEntryRepo(db), repository.NewTaskRepo(db), users, clock)
+	}
+	s := newTimeService()
+	for _, username := range []string{"ada", "bob"} {
+		name, email := username, username+"@example.com"
+		if err := users.Create(context.TODO(), &models.User{Username: &username, Name: &name, Email: &email}); err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+	}
+	first := createTask(t, tasks, nil, false)
+	second := createTask(t, tasks, nil, false)
+
+	entry, err := s.StartTimer(context.TODO(), *first.ID, 1, "bugfix")
+	if err != nil {
+		t.Fatalf("Error starting timer: %v", err)
+	}
+	if !entry.Running() || entry.Duration != nil || !entry.StartedAt.Equal(now) || entry.Note != "bugfix" {
+		t.Errorf("Unexpected timer %+v", entry)
+	}
+	// One running timer per user, others are not affected
+	if _, err := s.StartTimer(context.TODO(), *second.ID, 1, ""); !errors.Is(err, repository.ErrTimerRunning) {
+		t.Errorf("Expected ErrTimerRunning, got %v", err)
+	}
+	if _, err := s.StartTimer(context.TODO(), *first.ID, 2, ""); err != nil {
+		t.Errorf("Error starting a second user's timer: %v", err)
+	}
+	if _, err := s.StartTimer(context.TODO(), *first.ID, 9, ""); !errors.Is(err, ErrUnknownUser) {
+		t.Errorf("Expected ErrUnknownUser, got %v", err)
+	}
+	if _, err := s.StopTimer(context.TODO(), *second.ID, 1); !errors.Is(err, repository.ErrNoRunningTimer) {
+		t.Errorf("Expected ErrNoRunningTimer on another task, got %v", err)
+	}
+
+	// A restarted server finds the timer still running
+	clock.Advance(90 * time.Minute)
+	s = newTimeService()
+	stopped, err := s.StopTimer(context.TODO(), *first.ID, 1)
+	if err != nil {
+		t.Fatalf("Error stopping timer: %v", err)
+	}
+	if stopped.ID != entry.ID || stopped.Running() || *stopped.Duration != 5400 {
+		t.Errorf("Unexpected stopped timer %+v", stopped)
+	}
+	if _, err := s.StopTimer(context.TODO(), *first.ID, 1); !errors.Is(err, repository.ErrNoRunningTimer) {
+		t.Errorf("Expected ErrNoRunningTimer after stopping, got %v", err)
+	}
+
+	// Manual entries need an end after the start
+	endedAt := now.Add(-time.Hour)
+	manual := &models.TimeEntry{TaskID: *first.ID, UserID: 1, StartedAt: now.Add(-2 * time.Hour), EndedAt: &endedAt}
+	if err := s.CreateEntry(context.TODO(), manual); err != nil {
+		t.Fatalf("Error logging time: %v", err)
+	}
+	backwards := &models.TimeEntry{TaskID: *first.ID, UserID: 1, StartedAt: now, EndedAt: &endedAt}
+	if err := s.CreateEntry(context.TODO(), backwards); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("Expected ErrInvalidInterval, got %v", err)
+	}
+
+	// Only the owner changes an entry
+	endedAt = now.Add(-30 * time.Minute)
+	edit := &models.TimeEntry{ID: manual.ID, TaskID: *first.ID, StartedAt: manual.StartedAt, EndedAt: &endedAt, Note: "more"}
+	if err := s.UpdateEntry(context.TODO(), edit, 2); !errors.Is(err, ErrNotEntryOwner) {
+		t.Errorf("Expected ErrNotEntryOwner, got %v", err)
+	}
+	if err := s.UpdateEntry(context.TODO(), edit, 1); err != nil {
+		t.Fatalf("Error updating entry: %v", err)
+	}
+	if *edit.Duration != 5400 || edit.Note != "more" {
+		t.Errorf("Unexpected updated entry %+v", edit)
+	}
+	if err := s.DeleteEntry(context.TODO(), *second.ID, manual.ID, 1); !errors.Is(err, repository.ErrTimeEntryNotFound) {
+		t.Errorf("Expected ErrTimeEntryNotFound on another task, got %v", err)
+	}
+
+	// Running timers do not count yet
+	task, err := tasks.GetTask(context.TODO(), *first.ID)
+	if err != nil {
+		t.Fatalf("Error getting task: %v", err)
+	}
+	if task.TimeSpent != 10800 {
+		t.Errorf("Expected 10800 seconds spent, got %d", task.TimeSpent)
+	}
+	entries, err := s.GetEntries(context.TODO(), *first.ID)
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %v %v", entries, err)
+	}
+}
+
+func TestTimeReport(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	users := repository.NewUserRepo(db)
+	s := NewTimeService(repository.NewTimeEntryRepo(db), repository.NewTaskRepo(db), users, clock)
+	for _, username := range []string{"ada", "bob"} {
+		name, email := username, username+"@example.com"
+		if err := users.Create(context.TODO(), &models.User{Username: &username, Name: &name, Email: &email}); err != nil {
+			t.Fatalf("Error creating user: %v", err)
+		}
+	}
+	first := createTask(t, tasks, nil, false)
+	second := createTask(t, tasks, nil, false)
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	log := func(taskID int, userID int, start time.Time, d time.Duration) {
+		end := start.Add(d)
+		if err := s.CreateEntry(context.TODO(), &models.TimeEntry{TaskID: taskID, UserID: userID, StartedAt: start,
+			EndedAt: &end}); err != nil {
+			t.Fatalf("Error logging time: %v", err)
+		}
+	}
+	// 23:30 UTC on the 19th is the 20th in Berlin
+	log(*first.ID, 1, time.Date(2024, 11, 19, 23, 30, 0, 0, time.UTC), time.Hour)
+	log(*first.ID, 2, time.Date(2024, 11, 20, 9, 0, 0, 0, time.UTC), 30*time.Minute)
+	log(*second.ID, 1, time.Date(2024, 11, 21, 9, 0, 0, 0, time.UTC), 2*time.Hour)
+	log(*second.ID, 1, time.Date(2024, 11, 22, 9, 0, 0, 0, time.UTC), time.Hour)
+	if _, err := s.StartTimer(context.TODO(), *second.ID, 2, ""); err != nil {
+		t.Fatalf("Error starting timer: %v", err)
+	}
+
+	ada := 1
+	tests := []struct {
+		group  string
+		userID *int
+		want   string
+		total  int64
+	}{
+		{models.GroupByTask, nil, "[{1 task 5400} {2 task 7200}]", 12600},
+		{models.GroupByProject, nil, "[{1 Default 12600}]", 12600},
+		{models.GroupByDay, nil, "[{2024-11-20  5400} {2024-11-21  7200}]", 12600},
+		{models.GroupByDay, &ada, "[{2024-11-20  3600} {2024-11-21  7200}]", 10800},
+	}
+	for _, tt := range tests {
+		report, err := s.Report(context.TODO(), TimeReportQuery{
+			From:     time.Date(2024, 11, 20, 0, 0, 0, 0, berlin),
+			To:       time.Date(2024, 11, 21, 0, 0, 0, 0, berlin),
+			Location: berlin,
+			Group:    tt.group,
+			UserID:   tt.userID,
+		})
+		if err != nil {
+			t.Fatalf("Error getting report: %v", err)
+		}
+		if fmt.Sprint(report.Rows) != tt.want || report.Total != tt.total {
+			t.Errorf("%s: expected %s with total %d, got %v with total %d", tt.group, tt.want, tt.total,
+				report.Rows, report.Total)
+		}
+		if report.From != "2024-11-20" || report.To != "2024-11-21" {
+			t.Errorf("Unexpected range %s to %s", report.From, report.To)
+		}
+	}
+}
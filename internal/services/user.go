@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+type IUserService interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, id int) (*models.User, error)
+	GetUsers(ctx context.Context) ([]models.User, error)
+	UpdateDigest(ctx context.Context, user *models.User) error
+}
+
+type UserService struct {
+	Repo repository.IUserRepo
+}
+
+func NewUserService(userRepo repository.IUserRepo) IUserService {
+	return UserService{userRepo}
+}
+
+func (s UserService) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, span := tracer.Start(ctx, "UserService.CreateUser")
+	defer span.End()
+	if err := s.Repo.Create(ctx, user); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to create user")
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s UserService) GetUser(ctx context.Context, id int) (*models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUser")
+	defer span.End()
+	user, err := s.Repo.GetByID(ctx, id)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get user with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s UserService) GetUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := tracer.Start(ctx, "UserService.GetUsers")
+	defer span.End()
+	users, err := s.Repo.GetAll(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get users")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return users, nil
+}
+
+// UpdateDigest changes the digest opt-in, send time and zone of a user
+func (s UserService) UpdateDigest(ctx context.Context, user *models.User) error {
+	ctx, span := tracer.Start(ctx, "UserService.UpdateDigest")
+	defer span.End()
+	if err := s.Repo.UpdateDigest(ctx, user); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to update digest of user with id %d", *user.ID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
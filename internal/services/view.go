@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/query"
+	"todo-api/internal/telemetry"
+
+	"github.com/rs/zerolog"
+)
+
+type IViewService interface {
+	CreateView(ctx context.Context, view *models.SavedView) error
+	UpdateView(ctx context.Context, view *models.SavedView) error
+	GetView(ctx context.Context, id int) (*models.SavedView, error)
+	GetViews(ctx context.Context) ([]models.SavedView, error)
+	DeleteView(ctx context.Context, id int) error
+	GetViewTasks(ctx context.Context, id int, loc *time.Location, callerID *int) ([]models.Task, error)
+}
+
+type ViewService struct {
+	Views repository.IViewRepo
+	Tasks repository.ITaskRepo
+	Clock clock.Clock
+}
+
+func NewViewService(viewRepo repository.IViewRepo, taskRepo repository.ITaskRepo, clock clock.Clock) IViewService {
+	return ViewService{viewRepo, taskRepo, clock}
+}
+
+// CreateView saves a named task query, a query with errors is rejected
+// with a *query.Error
+func (s ViewService) CreateView(ctx context.Context, view *models.SavedView) error {
+	ctx, span := tracer.Start(ctx, "ViewService.CreateView")
+	defer span.End()
+	if err := query.Validate(view.Query); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	view.CreatedAt = s.Clock.Now()
+	if err := s.Views.Create(ctx, view); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to create saved view")
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// UpdateView renames a view and replaces its query
+func (s ViewService) UpdateView(ctx context.Context, view *models.SavedView) error {
+	ctx, span := tracer.Start(ctx, "ViewService.UpdateView")
+	defer span.End()
+	if err := query.Validate(view.Query); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	view.UpdatedAt = s.Clock.Now()
+	if err := s.Views.Update(ctx, view); err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to update saved view with id %d", view.ID)
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+func (s ViewService) GetView(ctx context.Context, id int) (*models.SavedView, error) {
+	ctx, span := tracer.Start(ctx, "ViewService.GetView")
+	defer span.End()
+	view, err := s.Views.GetByID(ctx, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return view, nil
+}
+
+func (s ViewService) GetViews(ctx context.Context) ([]models.SavedView, error) {
+	ctx, span := tracer.Start(ctx, "ViewService.GetViews")
+	defer span.End()
+	views, err := s.Views.GetAll(ctx)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msg("failed to get saved views")
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return views, nil
+}
+
+func (s ViewService) DeleteView(ctx context.Context, id int) error {
+	ctx, span := tracer.Start(ctx, "ViewService.DeleteView")
+	defer span.End()
+	if err := s.Views.Delete(ctx, id); err != nil {
+		telemetry.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// GetViewTasks runs a saved view for a caller: dates are days in loc and
+// assignee:me is callerID, which may be nil when the view does not use it
+func (s ViewService) GetViewTasks(ctx context.Context, id int, loc *time.Location, callerID *int) ([]models.Task, error) {
+	ctx, span := tracer.Start(ctx, "ViewService.GetViewTasks")
+	defer span.End()
+	view, err := s.Views.GetByID(ctx, id)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	parsed, err := query.Parse(view.Query)
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	filter, err := query.Compile(parsed, query.Env{Now: s.Clock.Now(), Location: loc, Caller: callerID})
+	if err != nil {
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	q := repository.TaskQuery{Filter: filter}
+	for _, key := range filter.Sort {
+		q.Sort = append(q.Sort, repository.SortKey{Column: key.Column, Desc: key.Desc})
+	}
+	tasks, err := s.Tasks.GetAll(ctx, q)
+	if err != nil {
+		zerolog.Ctx(ctx).Error().Err(err).Msgf("failed to get tasks of saved view with id %d", id)
+		telemetry.RecordError(span, err)
+		return nil, err
+	}
+	return tasks, nil
+}
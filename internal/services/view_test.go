@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	"todo-api/internal/db/models"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/query"
+)
+
+func TestSavedViews(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	s := NewViewService(repository.NewViewRepo(db), repository.NewTaskRepo(db), clock)
+
+	invalid := &models.SavedView{Name: "broken", Query: "status:open label:bug"}
+	var queryErr *query.Error
+	if err := s.CreateView(context.TODO(), invalid); !errors.As(err, &queryErr) || queryErr.Pos != 13 {
+		t.Errorf("Expected a query error at 13, got %v", err)
+	}
+
+	soon := createTask(t, tasks, due(24*time.Hour), false)
+	later := createTask(t, tasks, due(3*24*time.Hour), false)
+	createTask(t, tasks, due(10*24*time.Hour), false)
+	createTask(t, tasks, due(24*time.Hour), true)
+	view := &models.SavedView{Name: "due this week", Query: "is:open due<7d sort:-due"}
+	if err := s.CreateView(context.TODO(), view); err != nil {
+		t.Fatalf("Error creating view: %v", err)
+	}
+	if !view.CreatedAt.Equal(now) {
+		t.Errorf("Expected view created at %v, got %v", now, view.CreatedAt)
+	}
+
+	got, err := s.GetViewTasks(context.TODO(), view.ID, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("Error running view: %v", err)
+	}
+	if len(got) != 2 || *got[0].ID != *later.ID || *got[1].ID != *soon.ID {
+		t.Errorf("Expected tasks %d and %d, got %+v", *later.ID, *soon.ID, got)
+	}
+
+	// Relative times count from when the view runs
+	clock.Advance(4 * 24 * time.Hour)
+	if got, _ := s.GetViewTasks(context.TODO(), view.ID, time.UTC, nil); len(got) != 3 {
+		t.Errorf("Expected 3 tasks four days later, got %d", len(got))
+	}
+
+	view.Query = "assignee:me"
+	if err := s.UpdateView(context.TODO(), view); err != nil {
+		t.Fatalf("Error updating view: %v", err)
+	}
+	if _, err := s.GetViewTasks(context.TODO(), view.ID, time.UTC, nil); !errors.As(err, &queryErr) {
+		t.Errorf("Expected assignee:me to need a caller, got %v", err)
+	}
+	if err := s.DeleteView(context.TODO(), view.ID); err != nil {
+		t.Fatalf("Error deleting view: %v", err)
+	}
+	if _, err := s.GetView(context.TODO(), view.ID); !errors.Is(err, repository.ErrViewNotFound) {
+		t.Errorf("Expected ErrViewNotFound, got %v", err)
+	}
+}
+
+func TestTaggedView(t *testing.T) {
+	tasks, db, clock := newServiceDB(t)
+	s := NewViewService(repository.NewViewRepo(db), repository.NewTaskRepo(db), clock)
+
+	tagged := func(title string, tags ...string) *models.Task {
+		task := &models.Task{Title: &title, DueDate: due(24 * time.Hour)}
+		if err := tasks.CreateTask(context.TODO(), task); err != nil {
+			t.Fatalf("Error creating task: %v", err)
+		}
+		if _, err := tasks.SetTags(context.TODO(), *task.ID, tags); err != nil {
+			t.Fatalf("Error tagging task: %v", err)
+		}
+		return task
+	}
+	bug := tagged("login page crashes", "Bug", "bug", "ui")
+	tagged("login page typo", "bug", "wontfix")
+	tagged("login page colors", "ui")
+	tagged("signup crashes", "bug")
+
+	got, err := tasks.GetTask(context.TODO(), *bug.ID)
+	if err != nil {
+		t.Fatalf("Error getting task: %v", err)
+	}
+	if !reflect.DeepEqual(got.Tags, []string{"bug", "ui"}) {
+		t.Errorf("Expected tags [bug ui], got %v", got.Tags)
+	}
+	if _, err := tasks.SetTags(context.TODO(), *bug.ID, []string{"no spaces"}); !errors.Is(err, models.ErrInvalidTag) {
+		t.Errorf("Expected ErrInvalidTag, got %v", err)
+	}
+	if _, err := tasks.SetTags(context.TODO(), 1000, nil); !errors.Is(err, repository.ErrTaskNotFound) {
+		t.Errorf("Expected ErrTaskNotFound, got %v", err)
+	}
+
+	view := &models.SavedView{Name: "bugs", Query: `status:open due<7d tag:bug -tag:wontfix "login page" sort:due`}
+	if err := s.CreateView(context.TODO(), view); err != nil {
+		t.Fatalf("Error creating view: %v", err)
+	}
+	found, err := s.GetViewTasks(context.TODO(), view.ID, time.UTC, nil)
+	if err != nil {
+		t.Fatalf("Error running view: %v", err)
+	}
+	if len(found) != 1 || *found[0].ID != *bug.ID {
+		t.Errorf("Expected only task %d, got %+v", *bug.ID, found)
+	}
+}
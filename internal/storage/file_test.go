@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func key(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "blobs"))
+	if err != nil {
+		t.Fatalf("Error creating store: %v", err)
+	}
+	ctx := context.TODO()
+	k := key("hello")
+
+	if _, err := store.Open(ctx, k); !errors.Is(err, ErrBlobNotFound) {
+		t.Fatalf("Expected ErrBlobNotFound, got %v", err)
+	}
+	if err := store.Put(ctx, k, strings.NewReader("hello")); err != nil {
+		t.Fatalf("Error putting blob: %v", err)
+	}
+	// An existing blob is kept as is
+	if err := store.Put(ctx, k, strings.NewReader("other")); err != nil {
+		t.Fatalf("Error putting blob again: %v", err)
+	}
+	blob, err := store.Open(ctx, k)
+	if err != nil {
+		t.Fatalf("Error opening blob: %v", err)
+	}
+	content, _ := io.ReadAll(blob)
+	blob.Close()
+	if string(content) != "hello" {
+		t.Errorf("Expected hello, got %q", content)
+	}
+	entries, _ := os.ReadDir(filepath.Join(store.Dir, k[:2]))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %d entries", len(entries))
+	}
+
+	if err := store.Delete(ctx, k); err != nil {
+		t.Fatalf("Error deleting blob: %v", err)
+	}
+	if err := store.Delete(ctx, k); err != nil {
+		t.Errorf("Expected deleting a missing blob to succeed, got %v", err)
+	}
+	if _, err := store.Open(ctx, k); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Expected ErrBlobNotFound after delete, got %v", err)
+	}
+
+	for _, bad := range []string{"", "../../etc/passwd", strings.ToUpper(k), k[:63] + "/"} {
+		if err := store.Put(ctx, bad, strings.NewReader("x")); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Expected ErrInvalidKey for %q, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Package storage keeps file contents outside the database
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrBlobNotFound = errors.New("blob not found")
+	ErrInvalidKey   = errors.New("blob key must be a lowercase hex SHA-256")
+)
+
+// BlobStore keeps immutable blobs under the hex SHA-256 of their content,
+// so equal contents are stored once
+type BlobStore interface {
+	// Put stores the content of r under key unless a blob with that key
+	// already exists. The caller is responsible for key matching the content.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Open returns the content of a blob, ErrBlobNotFound if there is none
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Delete removes a blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that are not a hex SHA-256, which also keeps them
+// from escaping the store's directory
+func checkKey(key string) error {
+	if len(key) != 64 {
+		return ErrInvalidKey
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return ErrInvalidKey
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package telemetry_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"todo-api/internal/clock"
+	"todo-api/internal/db/drivers"
+	"todo-api/internal/db/repository"
+	"todo-api/internal/handlers"
+	"todo-api/internal/jobs"
+	"todo-api/internal/services"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var recorder = tracetest.NewSpanRecorder()
+
+func init() {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+func newService(t *testing.T) services.ITaskService {
+	db, err := drivers.Connect(filepath.Join(t.TempDir(), "trace.db"), "../db/migrations")
+	if err != nil {
+		t.Fatalf("Error connecting to database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return services.NewTaskService(repository.NewTaskRepo(db), repository.NewProjectRepo(db), nil, nil, clock.New())
+}
+
+func findSpan(spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestRequestSpanTree(t *testing.T) {
+	taskController := handlers.NewTaskController(newService(t), time.Second)
+	e := echo.New()
+	e.Use(otelecho.Middleware("todo-api"))
+	e.GET("/tasks", taskController.GetTasks)
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/tasks", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	spans := recorder.Ended()
+	httpSpan := findSpan(spans, "/tasks")
+	serviceSpan := findSpan(spans, "TaskService.GetTasks")
+	repoSpan := findSpan(spans, "TaskRepo.GetAll")
+	if httpSpan == nil || serviceSpan == nil || repoSpan == nil {
+		t.Fatalf("Missing spans, got %d spans", len(spans))
+	}
+	if got := httpSpan.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("Expected trace id %s from traceparent, got %s", traceID, got)
+	}
+	if serviceSpan.Parent().SpanID() != httpSpan.SpanContext().SpanID() {
+		t.Errorf("Service span is not a child of the request span")
+	}
+	if repoSpan.Parent().SpanID() != serviceSpan.SpanContext().SpanID() {
+		t.Errorf("Repository span is not a child of the service span")
+	}
+	var statement string
+	for _, attr := range repoSpan.Attributes() {
+		if attr.Key == "db.statement" {
+			statement = attr.Value.AsString()
+		}
+	}
+	if !strings.HasPrefix(statement, "SELECT * FROM task ") {
+		t.Errorf("Unexpected db.statement %q", statement)
+	}
+}
+
+func TestJobRunIsRootSpan(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	scheduler := jobs.NewScheduler(clock.New())
+	scheduler.Register(jobs.NewOverdueJob(newService(t), 10*time.Millisecond, 0, 0))
+	scheduler.Start(ctx)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	scheduler.Wait()
+
+	spans := recorder.Ended()
+	runSpan := findSpan(spans, "Scheduler.run")
+	if runSpan == nil {
+		t.Fatalf("Missing job run span")
+	}
+	if runSpan.Parent().IsValid() {
+		t.Errorf("Job run span should not have a parent")
+	}
+	serviceSpan := findSpan(spans, "TaskService.UpdateOverdue")
+	if serviceSpan == nil || serviceSpan.Parent().SpanID() != runSpan.SpanContext().SpanID() {
+		t.Errorf("UpdateOverdue span is not a child of the job run span")
+	}
+}
@@ -0,0 +1,34 @@
+package timezone
+
+import (
+	"context"
+	"time"
+	// Zone data is embedded so images without tzdata still resolve zones
+	_ "time/tzdata"
+)
+
+// Header carries the caller's IANA time zone, e.g. Asia/Tokyo
+const Header = "X-Timezone"
+
+type contextKey struct{}
+
+// WithLocation returns a context that carries the caller's time zone
+func WithLocation(ctx context.Context, loc *time.Location) context.Context {
+	return context.WithValue(ctx, contextKey{}, loc)
+}
+
+// FromContext returns the caller's time zone, UTC if none was set
+func FromContext(ctx context.Context) *time.Location {
+	if loc, ok := ctx.Value(contextKey{}).(*time.Location); ok {
+		return loc
+	}
+	return time.UTC
+}
+
+// Load returns the named location, UTC for an empty name
+func Load(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(name)
+}
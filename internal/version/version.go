@@ -0,0 +1,24 @@
+package version
+
+import "runtime"
+
+// Set at build time with
+// -ldflags "-X todo-api/internal/version.Version=... -X todo-api/internal/version.Commit=..."
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		GoVersion: runtime.Version(),
+	}
+}